@@ -91,15 +91,343 @@ func (d *Database) Migrate() error {
 		`CREATE TABLE IF NOT EXISTS order_items (
 			id SERIAL PRIMARY KEY,
 			order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
-			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
 			jumlah INTEGER NOT NULL DEFAULT 1,
 			harga INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS book_files (
+			id SERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			format VARCHAR(10) NOT NULL,
+			file_name TEXT NOT NULL,
+			original_name TEXT NOT NULL,
+			content_type VARCHAR(100) NOT NULL,
+			size BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS downloads (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			order_item_id INTEGER NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			book_file_id INTEGER REFERENCES book_files(id) ON DELETE SET NULL,
+			ip_address VARCHAR(45),
+			user_agent TEXT,
+			bytes BIGINT NOT NULL DEFAULT 0,
+			counted BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS reading_progress (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			position TEXT NOT NULL,
+			position_type VARCHAR(10) NOT NULL,
+			percent NUMERIC(5,2) NOT NULL DEFAULT 0,
+			device VARCHAR(100) NOT NULL DEFAULT '',
+			updated_at TIMESTAMP NOT NULL,
+			UNIQUE(user_id, book_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS annotations (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL,
+			cfi TEXT NOT NULL,
+			color VARCHAR(20),
+			text TEXT,
+			note TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS categories (
+			id SERIAL PRIMARY KEY,
+			parent_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT,
+			nama VARCHAR(100) NOT NULL,
+			slug VARCHAR(100) UNIQUE NOT NULL,
+			keterangan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS book_categories (
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+			PRIMARY KEY (book_id, category_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS authors (
+			id SERIAL PRIMARY KEY,
+			nama VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) UNIQUE NOT NULL,
+			biografi TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS publishers (
+			id SERIAL PRIMARY KEY,
+			nama VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) UNIQUE NOT NULL,
+			keterangan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS series (
+			id SERIAL PRIMARY KEY,
+			nama VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) UNIQUE NOT NULL,
+			keterangan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS book_contributors (
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+			role VARCHAR(20) NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (book_id, author_id, role)
+		)`,
+		`CREATE TABLE IF NOT EXISTS import_jobs (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			format VARCHAR(10) NOT NULL,
+			file_name TEXT NOT NULL,
+			original_name TEXT NOT NULL,
+			mode VARCHAR(20) NOT NULL,
+			chunk_size INTEGER NOT NULL,
+			dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+			status VARCHAR(20) NOT NULL,
+			total_rows INTEGER NOT NULL DEFAULT 0,
+			processed_rows INTEGER NOT NULL DEFAULT 0,
+			created_count INTEGER NOT NULL DEFAULT 0,
+			updated_count INTEGER NOT NULL DEFAULT 0,
+			error_count INTEGER NOT NULL DEFAULT 0,
+			errors JSONB,
+			message TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS book_versions (
+			id SERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			reverted_to INTEGER,
+			snapshot JSONB NOT NULL,
+			changes JSONB,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(book_id, version)
+		)`,
+		`CREATE TABLE IF NOT EXISTS reviews (
+			id SERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			title VARCHAR(200) NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'approved',
+			helpful_count INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, book_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS review_votes (
+			review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (review_id, user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS wishlists (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			harga INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, book_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			book_id INTEGER REFERENCES books(id) ON DELETE CASCADE,
+			type VARCHAR(30) NOT NULL,
+			message TEXT NOT NULL,
+			read_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Precomputed by the recommendation job, see RecommendationRepository.Rebuild
+		`CREATE TABLE IF NOT EXISTS book_recommendations (
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			related_book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			score INTEGER NOT NULL,
+			rank INTEGER NOT NULL,
+			PRIMARY KEY (book_id, rank)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_recommendations (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			score INTEGER NOT NULL,
+			rank INTEGER NOT NULL,
+			PRIMARY KEY (user_id, rank)
+		)`,
+		`CREATE TABLE IF NOT EXISTS book_previews (
+			id SERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			format VARCHAR(10) NOT NULL,
+			file_name TEXT NOT NULL,
+			content_type VARCHAR(100) NOT NULL,
+			size BIGINT NOT NULL DEFAULT 0,
+			source VARCHAR(10) NOT NULL,
+			source_file_id INTEGER REFERENCES book_files(id) ON DELETE SET NULL,
+			length INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (book_id, format)
+		)`,
+		`CREATE TABLE IF NOT EXISTS book_variants (
+			id SERIAL PRIMARY KEY,
+			book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+			format VARCHAR(20) NOT NULL,
+			harga INTEGER NOT NULL DEFAULT 0,
+			stok INTEGER,
+			terjual INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP
+		)`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS penulis TEXT`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS penerbit TEXT`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS bahasa VARCHAR(35)`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS subjek TEXT`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn_10 VARCHAR(10)`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn_13 VARCHAR(13)`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher_id INTEGER REFERENCES publishers(id) ON DELETE SET NULL`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS series_id INTEGER REFERENCES series(id) ON DELETE SET NULL`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS series_position INTEGER`,
+		// Aggregated from approved reviews by the review repository
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_average NUMERIC(3, 2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0`,
+		// Books are soft deleted; a hard delete must never take order
+		// history with it
+		`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_constraint
+				WHERE conname = 'order_items_book_id_fkey' AND confdeltype = 'c') THEN
+				ALTER TABLE order_items DROP CONSTRAINT order_items_book_id_fkey;
+				ALTER TABLE order_items ADD CONSTRAINT order_items_book_id_fkey
+					FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE RESTRICT;
+			END IF;
+		END $$`,
+		// Carts and order items point at the format being bought. Books from
+		// before formats existed get one variant carrying their price and
+		// stock, named after their first ebook file. Order items from before
+		// formats existed keep a NULL variant_id, which gives access to every
+		// file of the book.
+		`ALTER TABLE carts ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES book_variants(id) ON DELETE CASCADE`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES book_variants(id) ON DELETE RESTRICT`,
+		`INSERT INTO book_variants (book_id, format, harga, stok, terjual)
+			SELECT b.id,
+				COALESCE((SELECT f.format FROM book_files f
+					WHERE f.book_id = b.id AND f.format IN ('epub', 'pdf')
+					ORDER BY f.id LIMIT 1), 'epub'),
+				b.harga, b.stok, b.terjual
+			FROM books b
+			WHERE NOT EXISTS (SELECT 1 FROM book_variants v WHERE v.book_id = b.id)`,
+		`UPDATE carts c
+			SET variant_id = (SELECT MIN(v.id) FROM book_variants v WHERE v.book_id = c.book_id)
+			WHERE c.variant_id IS NULL`,
+		// Earlier versions pointed those order items at the first variant;
+		// no item bought before the first variant existed chose a format
+		`UPDATE order_items
+			SET variant_id = NULL
+			WHERE variant_id IS NOT NULL
+				AND created_at < (SELECT MIN(created_at) FROM book_variants)`,
+		`ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_user_id_book_id_key`,
+		// Range responses that do not complete a file on their own are
+		// partial; the one that brings them up to the file size is counted
+		`ALTER TABLE downloads ADD COLUMN IF NOT EXISTS partial BOOLEAN NOT NULL DEFAULT FALSE`,
+		// Every annotation write takes the next change_seq so sync cursors
+		// follow write order instead of transaction start timestamps
+		`ALTER TABLE annotations ADD COLUMN IF NOT EXISTS change_seq BIGSERIAL`,
+		// Backfill authors and publishers from the penulis/penerbit text of
+		// existing books. contributor_slug mirrors service.contributorSlug.
+		`CREATE OR REPLACE FUNCTION contributor_slug(prefix TEXT, name TEXT) RETURNS TEXT AS $$
+			SELECT COALESCE(
+				NULLIF(trim(BOTH '-' FROM regexp_replace(lower(trim(name)), '[^a-z0-9]+', '-', 'g')), ''),
+				prefix || '-' || left(md5(trim(name)), 8)
+			)
+		$$ LANGUAGE SQL IMMUTABLE`,
+		`INSERT INTO authors (nama, slug)
+			SELECT DISTINCT ON (slug) nama, slug
+			FROM (
+				SELECT trim(n) AS nama, contributor_slug('author', n) AS slug
+				FROM books, unnest(string_to_array(penulis, ',')) AS n
+				WHERE trim(n) <> ''
+			) names
+			ORDER BY slug, nama
+			ON CONFLICT (slug) DO NOTHING`,
+		`INSERT INTO book_contributors (book_id, author_id, role, position)
+			SELECT b.id, a.id, 'author', MIN(n.position) - 1
+			FROM books b
+			CROSS JOIN LATERAL unnest(string_to_array(b.penulis, ',')) WITH ORDINALITY AS n(nama, position)
+			JOIN authors a ON a.slug = contributor_slug('author', n.nama)
+			WHERE trim(n.nama) <> ''
+				AND NOT EXISTS (SELECT 1 FROM book_contributors bc WHERE bc.book_id = b.id)
+			GROUP BY b.id, a.id
+			ON CONFLICT DO NOTHING`,
+		`INSERT INTO publishers (nama, slug)
+			SELECT DISTINCT ON (slug) trim(penerbit), contributor_slug('publisher', penerbit) AS slug
+			FROM books
+			WHERE trim(COALESCE(penerbit, '')) <> ''
+			ORDER BY slug, trim(penerbit)
+			ON CONFLICT (slug) DO NOTHING`,
+		`UPDATE books b
+			SET publisher_id = p.id
+			FROM publishers p
+			WHERE b.publisher_id IS NULL
+				AND trim(COALESCE(b.penerbit, '')) <> ''
+				AND p.slug = contributor_slug('publisher', b.penerbit)`,
+		// Full-text search vectors, one per dictionary (see entity.SearchDictionary*)
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS search_indonesian tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('indonesian', COALESCE(nama_barang, '')), 'A') ||
+			setweight(to_tsvector('indonesian', COALESCE(penulis, '')), 'A') ||
+			setweight(to_tsvector('indonesian', COALESCE(subjek, '')), 'B') ||
+			setweight(to_tsvector('indonesian', COALESCE(penerbit, '')), 'C') ||
+			setweight(to_tsvector('indonesian', COALESCE(keterangan, '')), 'D')
+		) STORED`,
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS search_simple tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', COALESCE(nama_barang, '')), 'A') ||
+			setweight(to_tsvector('simple', COALESCE(penulis, '')), 'A') ||
+			setweight(to_tsvector('simple', COALESCE(subjek, '')), 'B') ||
+			setweight(to_tsvector('simple', COALESCE(penerbit, '')), 'C') ||
+			setweight(to_tsvector('simple', COALESCE(keterangan, '')), 'D')
+		) STORED`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_carts_user_id ON carts(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_items_book_id ON order_items(book_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_book_files_book_id ON book_files(book_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_book_categories_category_id ON book_categories(category_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_book_contributors_author_id ON book_contributors(author_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_publisher_id ON books(publisher_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books(deleted_at) WHERE deleted_at IS NOT NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn_10 ON books(isbn_10)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn_13 ON books(isbn_13)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_search_indonesian ON books USING GIN (search_indonesian)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_search_simple ON books USING GIN (search_simple)`,
+		`CREATE INDEX IF NOT EXISTS idx_downloads_user_id ON downloads(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_downloads_order_item_id ON downloads(order_item_id)`,
+		`DROP INDEX IF EXISTS idx_annotations_user_updated`,
+		`CREATE INDEX IF NOT EXISTS idx_annotations_user_change_seq ON annotations(user_id, change_seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_book_status ON reviews(book_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_wishlists_book_id ON wishlists(book_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_books_series_position ON books(series_id, series_position) WHERE series_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_book_variants_book_format ON book_variants(book_id, format) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_user_variant ON carts(user_id, variant_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_items_variant_id ON order_items(variant_id)`,
 	}
 
 	for _, migration := range migrations {
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type AnnotationController struct {
+	annotationService service.AnnotationService
+}
+
+func NewAnnotationController(annotationService service.AnnotationService) *AnnotationController {
+	return &AnnotationController{annotationService: annotationService}
+}
+
+// GetAnnotations lists annotations, optionally filtered by book_id. Passing
+// since (the cursor of a previous sync) returns only changes after it,
+// including deleted annotations.
+func (c *AnnotationController) GetAnnotations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookID, err := optionalBookID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var since *int64
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			respondError(w, http.StatusBadRequest, "since must be a cursor from a previous sync")
+			return
+		}
+		since = &parsed
+	}
+
+	sync, err := c.annotationService.SyncAnnotations(user.ID, bookID, since)
+	if err != nil {
+		respondAnnotationError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Annotations retrieved successfully", sync)
+}
+
+func (c *AnnotationController) CreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.CreateAnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	annotation, err := c.annotationService.CreateAnnotation(user.ID, req)
+	if err != nil {
+		respondAnnotationError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Annotation created successfully", annotation)
+}
+
+func (c *AnnotationController) UpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid annotation ID")
+		return
+	}
+
+	var req model.UpdateAnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	annotation, err := c.annotationService.UpdateAnnotation(user.ID, id, req)
+	if err != nil {
+		respondAnnotationError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Annotation updated successfully", annotation)
+}
+
+func (c *AnnotationController) DeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid annotation ID")
+		return
+	}
+
+	if err := c.annotationService.DeleteAnnotation(user.ID, id); err != nil {
+		respondAnnotationError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Annotation deleted successfully", nil)
+}
+
+// ExportAnnotations downloads the annotations as Markdown (default) or JSON
+func (c *AnnotationController) ExportAnnotations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookID, err := optionalBookID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	data, contentType, err := c.annotationService.ExportAnnotations(user.ID, bookID, format)
+	if err != nil {
+		respondAnnotationError(w, err)
+		return
+	}
+
+	ext := "md"
+	if format == service.AnnotationExportJSON {
+		ext = "json"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="annotations.%s"`, ext))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func optionalBookID(r *http.Request) (int, error) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(bookIDStr)
+}
+
+func respondAnnotationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBookNotPurchased):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrAnnotationNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type AuthorController struct {
+	authorService service.AuthorService
+	uploadService service.UploadService
+}
+
+func NewAuthorController(authorService service.AuthorService, uploadService service.UploadService) *AuthorController {
+	return &AuthorController{
+		authorService: authorService,
+		uploadService: uploadService,
+	}
+}
+
+// GetAuthors lists authors with their book counts, filtered by ?q= on name
+func (c *AuthorController) GetAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := c.authorService.GetAuthors(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Authors retrieved successfully", authors)
+}
+
+func (c *AuthorController) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	var req model.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	author, err := c.authorService.CreateAuthor(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Author created successfully", author)
+}
+
+// GetAuthor serves the author page, looked up by ?id= or ?slug=, with every
+// book the author is credited on
+func (c *AuthorController) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get("slug")
+	id := 0
+	if slug == "" {
+		var ok bool
+		if id, ok = authorIDParam(w, r); !ok {
+			return
+		}
+	}
+
+	author, err := c.authorService.GetAuthor(id, slug)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range author.Books {
+		if author.Books[i].GambarBuku != "" {
+			author.Books[i].GambarBuku = c.uploadService.GetImageURL(author.Books[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Author retrieved successfully", author)
+}
+
+func (c *AuthorController) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := authorIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	author, err := c.authorService.UpdateAuthor(id, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Author updated successfully", author)
+}
+
+func (c *AuthorController) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := authorIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.authorService.DeleteAuthor(id); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Author deleted successfully", nil)
+}
+
+// SetBookContributors replaces the contributor credits of a book
+func (c *AuthorController) SetBookContributors(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var req model.SetBookContributorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	contributors, err := c.authorService.SetBookContributors(bookID, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book contributors updated successfully", contributors)
+}
+
+func authorIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Author ID or slug is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid author ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -1,27 +1,41 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/middleware"
 	"github.com/LanangDepok/ebook-store/model"
 	"github.com/LanangDepok/ebook-store/service"
 )
 
 type BookController struct {
-	bookService   service.BookService
-	uploadService service.UploadService
+	bookService     service.BookService
+	uploadService   service.UploadService
+	bookFileService service.BookFileService
 }
 
-func NewBookController(bookService service.BookService, uploadService service.UploadService) *BookController {
+func NewBookController(bookService service.BookService, uploadService service.UploadService, bookFileService service.BookFileService) *BookController {
 	return &BookController{
-		bookService:   bookService,
-		uploadService: uploadService,
+		bookService:     bookService,
+		uploadService:   uploadService,
+		bookFileService: bookFileService,
 	}
 }
 
 func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	// Parse multipart form for file upload
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
 		respondError(w, http.StatusBadRequest, "Failed to parse form data")
@@ -33,6 +47,43 @@ func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
 	stok := r.FormValue("stok")
 	harga := r.FormValue("harga")
 	keterangan := r.FormValue("keterangan")
+	penulis := r.FormValue("penulis")
+	penerbit := r.FormValue("penerbit")
+	bahasa := r.FormValue("bahasa")
+	subjek := r.FormValue("subjek")
+	isbn10 := r.FormValue("isbn_10")
+	isbn13 := r.FormValue("isbn_13")
+	format := r.FormValue("format")
+
+	// An attached EPUB fills in whatever the form left blank
+	var metadata *service.EPUBMetadata
+	ebookFile, ebookHeader, err := r.FormFile("file")
+	if err == nil {
+		defer ebookFile.Close()
+		if format == "" && strings.EqualFold(filepath.Ext(ebookHeader.Filename), ".pdf") {
+			format = entity.VariantFormatPDF
+		}
+		if strings.EqualFold(filepath.Ext(ebookHeader.Filename), ".epub") {
+			metadata, err = service.ExtractEPUBMetadata(ebookFile, ebookHeader.Size)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			namaBarang = firstNonEmpty(namaBarang, metadata.Title)
+			keterangan = firstNonEmpty(keterangan, metadata.Description)
+			penulis = firstNonEmpty(penulis, strings.Join(metadata.Creators, ", "))
+			penerbit = firstNonEmpty(penerbit, metadata.Publisher)
+			bahasa = firstNonEmpty(bahasa, metadata.Language)
+			subjek = firstNonEmpty(subjek, strings.Join(metadata.Subjects, ", "))
+			if isbn10 == "" && isbn13 == "" {
+				if len(metadata.ISBN) == 10 {
+					isbn10 = metadata.ISBN
+				} else {
+					isbn13 = metadata.ISBN
+				}
+			}
+		}
+	}
 
 	if namaBarang == "" || harga == "" {
 		respondError(w, http.StatusBadRequest, "nama_barang and harga are required")
@@ -53,7 +104,7 @@ func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle image upload
+	// Handle image upload, falling back to the EPUB cover
 	var gambarBuku string
 	file, header, err := r.FormFile("gambar_buku")
 	if err == nil {
@@ -63,6 +114,11 @@ func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+	} else if metadata != nil && len(metadata.Cover) > 0 {
+		gambarBuku, err = c.uploadService.SaveImage(metadata.Cover, metadata.CoverType)
+		if err != nil {
+			log.Printf("Skipping EPUB cover: %v", err)
+		}
 	}
 
 	req := model.CreateBookRequest{
@@ -71,18 +127,37 @@ func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
 		Harga:      hargaInt,
 		Keterangan: keterangan,
 		GambarBuku: gambarBuku,
+		Penulis:    penulis,
+		Penerbit:   penerbit,
+		Bahasa:     bahasa,
+		Subjek:     subjek,
+		ISBN10:     isbn10,
+		ISBN13:     isbn13,
+		Format:     format,
 	}
 
-	book, err := c.bookService.CreateBook(req)
+	book, err := c.bookService.CreateBook(user.ID, req)
 	if err != nil {
 		// Delete uploaded image if book creation fails
 		if gambarBuku != "" {
 			c.uploadService.DeleteImage(gambarBuku)
 		}
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondError(w, bookErrorStatus(err), err.Error())
 		return
 	}
 
+	// Store the attached ebook as the book's first file
+	if ebookFile != nil {
+		if _, err := c.bookFileService.UploadBookFile(book.ID, ebookFile, ebookHeader); err != nil {
+			c.bookService.DeleteBook(book.ID)
+			if gambarBuku != "" {
+				c.uploadService.DeleteImage(gambarBuku)
+			}
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	// Add image URL to response
 	if book.GambarBuku != "" {
 		book.GambarBuku = c.uploadService.GetImageURL(book.GambarBuku)
@@ -92,20 +167,108 @@ func (c *BookController) CreateBook(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *BookController) GetAllBooks(w http.ResponseWriter, r *http.Request) {
-	books, err := c.bookService.GetAllBooks()
+	if q := r.URL.Query().Get("q"); q != "" {
+		c.searchBooks(w, r, q)
+		return
+	}
+
+	req, err := parseBookListRequest(r)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := c.bookService.GetAllBooks(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Add image URLs to response
+	books := page.Data.([]entity.Book)
 	for i := range books {
 		if books[i].GambarBuku != "" {
 			books[i].GambarBuku = c.uploadService.GetImageURL(books[i].GambarBuku)
 		}
 	}
 
-	respondSuccess(w, http.StatusOK, "Books retrieved successfully", books)
+	respondSuccess(w, http.StatusOK, "Books retrieved successfully", page)
+}
+
+func parseBookListRequest(r *http.Request) (model.BookListRequest, error) {
+	query := r.URL.Query()
+	req := model.BookListRequest{
+		Sort:      query.Get("sort"),
+		Order:     query.Get("order"),
+		Category:  query.Get("category"),
+		Author:    query.Get("author"),
+		Role:      query.Get("role"),
+		Publisher: query.Get("publisher"),
+		Cursor:    query.Get("cursor"),
+	}
+
+	ints := []struct {
+		name string
+		dest *int
+	}{
+		{"page", &req.Page},
+		{"limit", &req.Limit},
+	}
+	for _, param := range ints {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return req, fmt.Errorf("%s must be a positive number", param.name)
+			}
+			*param.dest = n
+		}
+	}
+
+	prices := []struct {
+		name string
+		dest **int
+	}{
+		{"min_harga", &req.MinHarga},
+		{"max_harga", &req.MaxHarga},
+	}
+	for _, param := range prices {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return req, fmt.Errorf("%s must be a non-negative number", param.name)
+			}
+			*param.dest = &n
+		}
+	}
+
+	if v := query.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return req, fmt.Errorf("in_stock must be true or false")
+		}
+		req.InStock = inStock
+	}
+
+	return req, nil
+}
+
+// searchBooks serves GET /api/books?q=... ranked by relevance, with an
+// optional dict=indonesian|simple to pick the search dictionary
+func (c *BookController) searchBooks(w http.ResponseWriter, r *http.Request, q string) {
+	results, err := c.bookService.SearchBooks(q, r.URL.Query().Get("dict"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range results {
+		if results[i].GambarBuku != "" {
+			results[i].GambarBuku = c.uploadService.GetImageURL(results[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Books retrieved successfully", results)
 }
 
 func (c *BookController) GetBookByID(w http.ResponseWriter, r *http.Request) {
@@ -135,7 +298,39 @@ func (c *BookController) GetBookByID(w http.ResponseWriter, r *http.Request) {
 	respondSuccess(w, http.StatusOK, "Book retrieved successfully", book)
 }
 
+// GetBookByISBN serves GET /api/books/isbn/{isbn}
+func (c *BookController) GetBookByISBN(w http.ResponseWriter, r *http.Request) {
+	isbn := strings.TrimPrefix(r.URL.Path, "/api/books/isbn/")
+	if isbn == "" {
+		respondError(w, http.StatusBadRequest, "ISBN is required")
+		return
+	}
+
+	book, err := c.bookService.GetBookByISBN(isbn)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, service.ErrInvalidISBN) {
+			status = http.StatusBadRequest
+		}
+		respondError(w, status, err.Error())
+		return
+	}
+
+	// Add image URL to response
+	if book.GambarBuku != "" {
+		book.GambarBuku = c.uploadService.GetImageURL(book.GambarBuku)
+	}
+
+	respondSuccess(w, http.StatusOK, "Book retrieved successfully", book)
+}
+
 func (c *BookController) UpdateBook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
 		respondError(w, http.StatusBadRequest, "Book ID is required")
@@ -148,7 +343,7 @@ func (c *BookController) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get existing book to retrieve old image
+	// Get existing book for the fields that are not sent
 	existingBook, err := c.bookService.GetBookByID(id)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "Book not found")
@@ -168,6 +363,20 @@ func (c *BookController) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	harga := r.FormValue("harga")
 	keterangan := r.FormValue("keterangan")
 
+	// Metadata fields keep their current value when not sent
+	penulis := formValueOr(r, "penulis", existingBook.Penulis)
+	penerbit := formValueOr(r, "penerbit", existingBook.Penerbit)
+	bahasa := formValueOr(r, "bahasa", existingBook.Bahasa)
+	subjek := formValueOr(r, "subjek", existingBook.Subjek)
+
+	// Sending either ISBN replaces both; the one left out is derived
+	isbn10, isbn13 := existingBook.ISBN10, existingBook.ISBN13
+	_, has10 := r.Form["isbn_10"]
+	_, has13 := r.Form["isbn_13"]
+	if has10 || has13 {
+		isbn10, isbn13 = r.FormValue("isbn_10"), r.FormValue("isbn_13")
+	}
+
 	if namaBarang == "" || harga == "" {
 		respondError(w, http.StatusBadRequest, "nama_barang and harga are required")
 		return
@@ -198,18 +407,14 @@ func (c *BookController) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		defer file.Close()
 
-		// Upload new image
+		// Upload new image. The old one is kept: earlier versions in the
+		// book history still refer to it.
 		newImage, err := c.uploadService.UploadImage(file, header)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Delete old image if exists
-		if existingBook.GambarBuku != "" {
-			c.uploadService.DeleteImage(existingBook.GambarBuku)
-		}
-
 		gambarBuku = newImage
 	}
 
@@ -220,11 +425,17 @@ func (c *BookController) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		Harga:      hargaInt,
 		Keterangan: keterangan,
 		GambarBuku: gambarBuku,
+		Penulis:    penulis,
+		Penerbit:   penerbit,
+		Bahasa:     bahasa,
+		Subjek:     subjek,
+		ISBN10:     isbn10,
+		ISBN13:     isbn13,
 	}
 
-	book, err := c.bookService.UpdateBook(id, req)
+	book, err := c.bookService.UpdateBook(user.ID, id, req)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondError(w, bookErrorStatus(err), err.Error())
 		return
 	}
 
@@ -249,24 +460,175 @@ func (c *BookController) DeleteBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get book to retrieve image before deletion
-	book, err := c.bookService.GetBookByID(id)
-	if err != nil {
+	if _, err := c.bookService.GetBookByID(id); err != nil {
 		respondError(w, http.StatusNotFound, "Book not found")
 		return
 	}
 
-	// Delete book
+	// Soft delete: the image and book files are kept, since the book stays
+	// in order history and libraries and may be restored
 	err = c.bookService.DeleteBook(id)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Delete associated image
+	respondSuccess(w, http.StatusOK, "Book deleted successfully", nil)
+}
+
+// GetDeletedBooks lists soft deleted books. It accepts the same filters and
+// pagination parameters as GetAllBooks.
+func (c *BookController) GetDeletedBooks(w http.ResponseWriter, r *http.Request) {
+	req, err := parseBookListRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := c.bookService.GetDeletedBooks(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	books := page.Data.([]entity.Book)
+	for i := range books {
+		if books[i].GambarBuku != "" {
+			books[i].GambarBuku = c.uploadService.GetImageURL(books[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Deleted books retrieved successfully", page)
+}
+
+func (c *BookController) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	book, err := c.bookService.RestoreBook(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Add image URL to response
+	if book.GambarBuku != "" {
+		book.GambarBuku = c.uploadService.GetImageURL(book.GambarBuku)
+	}
+
+	respondSuccess(w, http.StatusOK, "Book restored successfully", book)
+}
+
+// GetBookHistory lists the recorded versions of a book, newest first
+func (c *BookController) GetBookHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	versions, err := c.bookService.GetBookHistory(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range versions {
+		versions[i].Snapshot.GambarBuku = c.uploadService.GetImageURL(versions[i].Snapshot.GambarBuku)
+		for j, change := range versions[i].Changes {
+			if change.Field != "gambar_buku" {
+				continue
+			}
+			before, _ := change.Before.(string)
+			after, _ := change.After.(string)
+			versions[i].Changes[j].Before = c.uploadService.GetImageURL(before)
+			versions[i].Changes[j].After = c.uploadService.GetImageURL(after)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Book history retrieved successfully", versions)
+}
+
+// RollbackBook restores the book to a version from its history
+func (c *BookController) RollbackBook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	id, err := strconv.Atoi(query.Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+	version, err := strconv.Atoi(query.Get("version"))
+	if err != nil || version < 1 {
+		respondError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+
+	book, err := c.bookService.RollbackBook(user.ID, id, version)
+	if err != nil {
+		status := bookErrorStatus(err)
+		if errors.Is(err, service.ErrVersionNotFound) || errors.Is(err, service.ErrBookNotFound) {
+			status = http.StatusNotFound
+		}
+		respondError(w, status, err.Error())
+		return
+	}
+
+	// Add image URL to response
 	if book.GambarBuku != "" {
-		c.uploadService.DeleteImage(book.GambarBuku)
+		book.GambarBuku = c.uploadService.GetImageURL(book.GambarBuku)
 	}
 
-	respondSuccess(w, http.StatusOK, "Book deleted successfully", nil)
+	respondSuccess(w, http.StatusOK, "Book rolled back successfully", book)
+}
+
+// bookErrorStatus maps book create/update errors to an HTTP status
+func bookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidISBN), errors.Is(err, service.ErrInvalidFormat):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrDuplicateISBN), errors.Is(err, service.ErrBookHasVariants):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// formValueOr returns the form value, or fallback when the field was not sent
+func formValueOr(r *http.Request, key, fallback string) string {
+	if _, ok := r.Form[key]; ok {
+		return r.FormValue(key)
+	}
+	return fallback
 }
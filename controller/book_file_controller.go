@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type BookFileController struct {
+	bookFileService service.BookFileService
+}
+
+func NewBookFileController(bookFileService service.BookFileService) *BookFileController {
+	return &BookFileController{bookFileService: bookFileService}
+}
+
+func (c *BookFileController) UploadBookFile(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	// Limit request body to the maximum file size plus form overhead
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxBookFileSize+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Book file is required")
+		return
+	}
+	defer file.Close()
+
+	bookFile, err := c.bookFileService.UploadBookFile(bookID, file, header)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Book file uploaded successfully", bookFile)
+}
+
+func (c *BookFileController) GetBookFiles(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	files, err := c.bookFileService.GetBookFiles(bookID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book files retrieved successfully", files)
+}
+
+func (c *BookFileController) DeleteBookFile(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book file ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book file ID")
+		return
+	}
+
+	err = c.bookFileService.DeleteBookFile(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book file deleted successfully", nil)
+}
+
+// DownloadBookFile streams a purchased book file to its owner
+func (c *BookFileController) DownloadBookFile(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book file ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book file ID")
+		return
+	}
+
+	download, err := c.bookFileService.OpenForDownload(user.ID, id)
+	if err != nil {
+		respondDownloadError(w, err)
+		return
+	}
+	defer download.Close()
+
+	c.serveDownload(w, r, download)
+}
+
+// CreateDownloadLink returns a signed, expiring download URL for a purchased file
+func (c *BookFileController) CreateDownloadLink(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book file ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book file ID")
+		return
+	}
+
+	link, err := c.bookFileService.CreateDownloadLink(user.ID, id)
+	if err != nil {
+		respondDownloadError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Download link created successfully", link)
+}
+
+// DownloadSignedFile serves a file from a signed link without a bearer token
+func (c *BookFileController) DownloadSignedFile(w http.ResponseWriter, r *http.Request) {
+	download, err := c.bookFileService.OpenSignedDownload(r.URL.Query())
+	if err != nil {
+		respondDownloadError(w, err)
+		return
+	}
+	defer download.Close()
+
+	c.serveDownload(w, r, download)
+}
+
+// StreamBookFile serves a purchased file inline for a web reader. Range,
+// If-Range and ETag are supported so large PDFs can be read page by page.
+// Streams are not recorded in the download ledger.
+func (c *BookFileController) StreamBookFile(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Book file ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book file ID")
+		return
+	}
+
+	stream, err := c.bookFileService.OpenForStream(user.ID, id)
+	if err != nil {
+		respondDownloadError(w, err)
+		return
+	}
+	defer stream.Close()
+
+	bookFile := stream.File
+	w.Header().Set("Content-Type", bookFile.ContentType)
+	w.Header().Set("Content-Disposition", contentDisposition(r, "inline", bookFile.OriginalName))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", fileETag(bookFile))
+
+	http.ServeContent(w, r, "", bookFile.CreatedAt, stream.Content)
+}
+
+// serveDownload streams the file and settles its reservation in the download
+// ledger. Unwatermarked files honour Range requests so interrupted downloads
+// can resume; partial responses count against the limit once they add up to
+// the whole file.
+func (c *BookFileController) serveDownload(w http.ResponseWriter, r *http.Request, download *service.BookFileDownload) {
+	bookFile := download.File
+	w.Header().Set("Content-Type", bookFile.ContentType)
+	w.Header().Set("Content-Disposition", contentDisposition(r, "attachment", bookFile.OriginalName))
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	cw := &countingResponseWriter{ResponseWriter: w}
+
+	if download.Watermark != nil {
+		// Every watermarked copy is different, so byte ranges would not line
+		// up between requests
+		w.Header().Set("Accept-Ranges", "none")
+		w.Header().Set("Content-Length", strconv.FormatInt(download.Size, 10))
+		cw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(cw, download.Content); err != nil {
+			log.Printf("Failed to stream file %d: %v", bookFile.ID, err)
+		}
+	} else {
+		w.Header().Set("ETag", fileETag(bookFile))
+		http.ServeContent(cw, r, "", bookFile.CreatedAt, download.Content)
+	}
+
+	// Conditional and unsatisfiable range requests send no content, so
+	// their reservation is released
+	if cw.status != http.StatusOK && cw.status != http.StatusPartialContent {
+		if err := c.bookFileService.CancelDownload(download); err != nil {
+			log.Printf("Failed to cancel download of file %d: %v", bookFile.ID, err)
+		}
+		return
+	}
+
+	partial := cw.status == http.StatusPartialContent
+	if err := c.bookFileService.RecordDownload(download, clientIP(r), r.UserAgent(), cw.n, partial); err != nil {
+		log.Printf("Failed to record download of file %d: %v", bookFile.ID, err)
+	}
+}
+
+// countingResponseWriter records the status and the bytes sent to the client
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (cw *countingResponseWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingResponseWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// contentDisposition builds the Content-Disposition header. Clients may ask
+// for ?disposition=inline or ?disposition=attachment to override the default.
+func contentDisposition(r *http.Request, fallback, filename string) string {
+	disposition := r.URL.Query().Get("disposition")
+	if disposition != "inline" && disposition != "attachment" {
+		disposition = fallback
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
+
+// fileETag identifies a stored file; uploads are never modified in place, so
+// the ID, size and upload time are enough to make it change with the content
+func fileETag(bookFile *entity.BookFile) string {
+	return fmt.Sprintf(`"%d-%d-%x"`, bookFile.ID, bookFile.Size, bookFile.CreatedAt.UnixNano())
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func respondDownloadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBookNotPurchased), errors.Is(err, service.ErrFormatNotPurchased),
+		errors.Is(err, service.ErrInvalidDownloadLink):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrDownloadLinkExpired):
+		respondError(w, http.StatusGone, err.Error())
+	case errors.Is(err, service.ErrDownloadLimitExceeded):
+		respondErrorCode(w, http.StatusForbidden, "DOWNLOAD_LIMIT_EXCEEDED", err.Error())
+	case errors.Is(err, service.ErrStreamNotSupported):
+		respondErrorCode(w, http.StatusBadRequest, "STREAM_NOT_SUPPORTED", err.Error())
+	case errors.Is(err, service.ErrWatermarkFailed):
+		respondError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondError(w, http.StatusNotFound, err.Error())
+	}
+}
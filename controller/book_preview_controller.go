@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type BookPreviewController struct {
+	previewService service.BookPreviewService
+}
+
+func NewBookPreviewController(previewService service.BookPreviewService) *BookPreviewController {
+	return &BookPreviewController{previewService: previewService}
+}
+
+// DownloadPreview serves the free preview of a book (?book_id=&format=) to
+// anyone, signed in or not
+func (c *BookPreviewController) DownloadPreview(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	file, err := c.previewService.OpenPreview(bookID, r.URL.Query().Get("format"))
+	if err != nil {
+		respondPreviewError(w, err)
+		return
+	}
+	defer file.Content.Close()
+
+	preview := file.Preview
+	filename := fmt.Sprintf("preview-%d.%s", preview.BookID, preview.Format)
+	w.Header().Set("Content-Type", preview.ContentType)
+	w.Header().Set("Content-Disposition", contentDisposition(r, "attachment", filename))
+	w.Header().Set("Cache-Control", "public, no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"p%d-%d-%x"`, preview.ID, preview.Size, preview.CreatedAt.UnixNano()))
+
+	http.ServeContent(w, r, "", preview.CreatedAt, file.Content)
+}
+
+func (c *BookPreviewController) GetPreviews(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	previews, err := c.previewService.GetPreviews(bookID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book previews retrieved successfully", previews)
+}
+
+// AttachPreview uploads a ready-made preview file for a book
+func (c *BookPreviewController) AttachPreview(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxBookFileSize+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Preview file is required")
+		return
+	}
+	defer file.Close()
+
+	preview, err := c.previewService.AttachPreview(bookID, file, header)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Book preview uploaded successfully", preview)
+}
+
+// GeneratePreview cuts a preview from a stored book file (?file_id=) with an
+// optional length in chapters or pages
+func (c *BookPreviewController) GeneratePreview(w http.ResponseWriter, r *http.Request) {
+	fileID, err := strconv.Atoi(r.URL.Query().Get("file_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book file ID")
+		return
+	}
+
+	length := 0
+	if v := r.URL.Query().Get("length"); v != "" {
+		length, err = strconv.Atoi(v)
+		if err != nil || length < 1 {
+			respondError(w, http.StatusBadRequest, "length must be a positive number")
+			return
+		}
+	}
+
+	preview, err := c.previewService.GeneratePreview(fileID, length)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Book preview generated successfully", preview)
+}
+
+func (c *BookPreviewController) DeletePreview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid preview ID")
+		return
+	}
+
+	if err := c.previewService.DeletePreview(id); err != nil {
+		respondPreviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book preview deleted successfully", nil)
+}
+
+func respondPreviewError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrPreviewNotFound) || err.Error() == "book not found" {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondError(w, http.StatusInternalServerError, err.Error())
+}
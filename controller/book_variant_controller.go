@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type BookVariantController struct {
+	variantService service.BookVariantService
+}
+
+func NewBookVariantController(variantService service.BookVariantService) *BookVariantController {
+	return &BookVariantController{variantService: variantService}
+}
+
+// GetVariants lists the formats a book (?book_id=) is sold in
+func (c *BookVariantController) GetVariants(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	variants, err := c.variantService.GetVariants(bookID)
+	if err != nil {
+		respondVariantError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book formats retrieved successfully", variants)
+}
+
+// CreateVariant starts selling a book (?book_id=) in another format
+func (c *BookVariantController) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var req model.BookVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	variant, err := c.variantService.CreateVariant(bookID, req)
+	if err != nil {
+		respondVariantError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Book format created successfully", variant)
+}
+
+func (c *BookVariantController) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	var req model.BookVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	variant, err := c.variantService.UpdateVariant(id, req)
+	if err != nil {
+		respondVariantError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book format updated successfully", variant)
+}
+
+func (c *BookVariantController) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	if err := c.variantService.DeleteVariant(id); err != nil {
+		respondVariantError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book format deleted successfully", nil)
+}
+
+func respondVariantError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "book not found" || err.Error() == "book variant not found":
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrDuplicateVariant):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
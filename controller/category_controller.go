@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type CategoryController struct {
+	categoryService service.CategoryService
+}
+
+func NewCategoryController(categoryService service.CategoryService) *CategoryController {
+	return &CategoryController{categoryService: categoryService}
+}
+
+// GetCategories returns the category tree with book counts
+func (c *CategoryController) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := c.categoryService.GetCategoryTree()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Categories retrieved successfully", categories)
+}
+
+func (c *CategoryController) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req model.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	category, err := c.categoryService.CreateCategory(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Category created successfully", category)
+}
+
+func (c *CategoryController) GetCategoryByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := c.categoryService.GetCategoryByID(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Category retrieved successfully", category)
+}
+
+func (c *CategoryController) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	category, err := c.categoryService.UpdateCategory(id, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Category updated successfully", category)
+}
+
+func (c *CategoryController) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.categoryService.DeleteCategory(id); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Category deleted successfully", nil)
+}
+
+// SetBookCategories replaces the categories a book is filed under
+func (c *CategoryController) SetBookCategories(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var req model.SetBookCategoriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	categories, err := c.categoryService.SetBookCategories(bookID, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book categories updated successfully", categories)
+}
+
+func categoryIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Category ID is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid category ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type DownloadController struct {
+	downloadService service.DownloadService
+}
+
+func NewDownloadController(downloadService service.DownloadService) *DownloadController {
+	return &DownloadController{downloadService: downloadService}
+}
+
+func (c *DownloadController) GetDownloadHistory(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	downloads, err := c.downloadService.GetDownloadHistory(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Download history retrieved successfully", downloads)
+}
+
+func (c *DownloadController) ResetDownloads(w http.ResponseWriter, r *http.Request) {
+	var req model.ResetDownloadsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	reset, err := c.downloadService.ResetDownloads(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data := map[string]interface{}{
+		"reset": reset,
+	}
+
+	respondSuccess(w, http.StatusOK, "Download counter reset successfully", data)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type ExportController struct {
+	exportService service.ExportService
+}
+
+func NewExportController(exportService service.ExportService) *ExportController {
+	return &ExportController{exportService: exportService}
+}
+
+// ExportCatalog downloads every book matching the book list filters as CSV
+// (default), JSON Lines or ONIX 3.0. The rows are streamed as they are read.
+func (c *ExportController) ExportCatalog(w http.ResponseWriter, r *http.Request) {
+	list, err := parseBookListRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	export, err := c.exportService.PrepareExport(model.ExportRequest{
+		BookListRequest: list,
+		Format:          r.URL.Query().Get("format"),
+	})
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.FileName))
+	w.WriteHeader(http.StatusOK)
+
+	// The status has been sent; a failure now can only cut the file short
+	if err := c.exportService.WriteExport(w, export); err != nil {
+		log.Printf("Catalog export: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type ImportController struct {
+	importService service.ImportService
+}
+
+func NewImportController(importService service.ImportService) *ImportController {
+	return &ImportController{importService: importService}
+}
+
+// StartImport accepts a CSV or ONIX file and starts an import job. The job
+// runs in the background; poll GetImportJob for progress and row errors.
+func (c *ImportController) StartImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxImportFileSize+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Import file is required")
+		return
+	}
+	defer file.Close()
+
+	req := model.ImportRequest{
+		Format: r.FormValue("format"),
+		Mode:   r.FormValue("mode"),
+	}
+	if v := r.FormValue("chunk_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "chunk_size must be a positive number")
+			return
+		}
+		req.ChunkSize = n
+	}
+	if v := r.FormValue("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+		req.DryRun = dryRun
+	}
+
+	job, err := c.importService.StartImport(user.ID, file, header, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusAccepted, "Import started", job)
+}
+
+func (c *ImportController) GetImportJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := c.importService.GetImportJobs()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Import jobs retrieved successfully", jobs)
+}
+
+func (c *ImportController) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := importJobIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := c.importService.GetImportJob(id)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Import job retrieved successfully", job)
+}
+
+func (c *ImportController) ResumeImport(w http.ResponseWriter, r *http.Request) {
+	id, ok := importJobIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := c.importService.ResumeImport(id)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusAccepted, "Import resumed", job)
+}
+
+func importJobIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Import job ID is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid import job ID")
+		return 0, false
+	}
+	return id, true
+}
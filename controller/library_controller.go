@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type LibraryController struct {
+	libraryService service.LibraryService
+	uploadService  service.UploadService
+}
+
+func NewLibraryController(libraryService service.LibraryService, uploadService service.UploadService) *LibraryController {
+	return &LibraryController{
+		libraryService: libraryService,
+		uploadService:  uploadService,
+	}
+}
+
+func (c *LibraryController) GetLibrary(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	items, err := c.libraryService.GetLibrary(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range items {
+		if items[i].GambarBuku != "" {
+			items[i].GambarBuku = c.uploadService.GetImageURL(items[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Library retrieved successfully", items)
+}
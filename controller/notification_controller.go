@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type NotificationController struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationController(notificationService service.NotificationService) *NotificationController {
+	return &NotificationController{notificationService: notificationService}
+}
+
+// GetNotifications lists the user's notifications, newest first. Pass
+// unread=true for unread ones only.
+func (c *NotificationController) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var unreadOnly bool
+	if v := r.URL.Query().Get("unread"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "unread must be true or false")
+			return
+		}
+		unreadOnly = parsed
+	}
+
+	notifications, unread, err := c.notificationService.GetNotifications(user.ID, unreadOnly)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+	}
+
+	respondSuccess(w, http.StatusOK, "Notifications retrieved successfully", data)
+}
+
+// MarkRead marks one notification (?id=) or, without an id, all of them as
+// read
+func (c *NotificationController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		if err := c.notificationService.MarkAllRead(user.ID); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondSuccess(w, http.StatusOK, "Notifications marked as read", nil)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	if err := c.notificationService.MarkRead(user.ID, id); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Notification marked as read", nil)
+}
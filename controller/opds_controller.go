@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type OPDSController struct {
+	opdsService service.OPDSService
+}
+
+func NewOPDSController(opdsService service.OPDSService) *OPDSController {
+	return &OPDSController{opdsService: opdsService}
+}
+
+// Root serves the OPDS 1.2 navigation feed
+func (c *OPDSController) Root(w http.ResponseWriter, r *http.Request) {
+	respondAtom(w, "application/atom+xml;profile=opds-catalog;kind=navigation", c.opdsService.AtomNavigation())
+}
+
+// RootV2 serves the OPDS 2.0 navigation feed
+func (c *OPDSController) RootV2(w http.ResponseWriter, r *http.Request) {
+	respondOPDS2(w, http.StatusOK, c.opdsService.JSONNavigation())
+}
+
+// Feed returns a handler for an OPDS 1.2 acquisition feed
+func (c *OPDSController) Feed(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, ok := c.getCatalog(w, r, kind, r.URL.Query().Get("q"))
+		if !ok {
+			return
+		}
+		respondAtom(w, "application/atom+xml;profile=opds-catalog;kind=acquisition", c.opdsService.AtomFeed(catalog))
+	}
+}
+
+// FeedV2 returns a handler for an OPDS 2.0 publications feed
+func (c *OPDSController) FeedV2(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, ok := c.getCatalog(w, r, kind, r.URL.Query().Get("query"))
+		if !ok {
+			return
+		}
+		respondOPDS2(w, http.StatusOK, c.opdsService.JSONFeed(catalog))
+	}
+}
+
+// OpenSearch serves the OpenSearch description referenced by OPDS 1.2 feeds
+func (c *OPDSController) OpenSearch(w http.ResponseWriter, r *http.Request) {
+	respondAtom(w, "application/opensearchdescription+xml", c.opdsService.OpenSearch())
+}
+
+func (c *OPDSController) getCatalog(w http.ResponseWriter, r *http.Request, kind, query string) (*service.OPDSCatalog, bool) {
+	userID := 0
+	if kind == service.OPDSFeedShelf {
+		user := middleware.GetUserFromContext(r.Context())
+		if user == nil {
+			respondError(w, http.StatusUnauthorized, "Unauthorized")
+			return nil, false
+		}
+		userID = user.ID
+	}
+
+	catalog, err := c.opdsService.GetCatalog(kind, userID, query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return catalog, true
+}
+
+func respondAtom(w http.ResponseWriter, contentType string, data interface{}) {
+	w.Header().Set("Content-Type", contentType+";charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(data)
+}
+
+func respondOPDS2(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/opds+json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
 	"github.com/LanangDepok/ebook-store/service"
 )
 
@@ -75,3 +77,31 @@ func (c *OrderController) GetOrderDetail(w http.ResponseWriter, r *http.Request)
 
 	respondSuccess(w, http.StatusOK, "Order detail retrieved successfully", order)
 }
+
+func (c *OrderController) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Order ID is required")
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req model.UpdateOrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err = c.orderService.UpdateOrderStatus(id, req.Status)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Order status updated successfully", nil)
+}
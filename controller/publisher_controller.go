@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type PublisherController struct {
+	publisherService service.PublisherService
+	uploadService    service.UploadService
+}
+
+func NewPublisherController(publisherService service.PublisherService, uploadService service.UploadService) *PublisherController {
+	return &PublisherController{
+		publisherService: publisherService,
+		uploadService:    uploadService,
+	}
+}
+
+// GetPublishers lists publishers with their book counts, filtered by ?q= on name
+func (c *PublisherController) GetPublishers(w http.ResponseWriter, r *http.Request) {
+	publishers, err := c.publisherService.GetPublishers(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Publishers retrieved successfully", publishers)
+}
+
+func (c *PublisherController) CreatePublisher(w http.ResponseWriter, r *http.Request) {
+	var req model.PublisherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	publisher, err := c.publisherService.CreatePublisher(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Publisher created successfully", publisher)
+}
+
+// GetPublisher serves the publisher page, looked up by ?id= or ?slug=, with
+// every book it publishes
+func (c *PublisherController) GetPublisher(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get("slug")
+	id := 0
+	if slug == "" {
+		var ok bool
+		if id, ok = publisherIDParam(w, r); !ok {
+			return
+		}
+	}
+
+	publisher, err := c.publisherService.GetPublisher(id, slug)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range publisher.Books {
+		if publisher.Books[i].GambarBuku != "" {
+			publisher.Books[i].GambarBuku = c.uploadService.GetImageURL(publisher.Books[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Publisher retrieved successfully", publisher)
+}
+
+func (c *PublisherController) UpdatePublisher(w http.ResponseWriter, r *http.Request) {
+	id, ok := publisherIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.PublisherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	publisher, err := c.publisherService.UpdatePublisher(id, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Publisher updated successfully", publisher)
+}
+
+func (c *PublisherController) DeletePublisher(w http.ResponseWriter, r *http.Request) {
+	id, ok := publisherIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.publisherService.DeletePublisher(id); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Publisher deleted successfully", nil)
+}
+
+// SetBookPublisher links a book to a publisher, or unlinks it when
+// publisher_id is null
+func (c *PublisherController) SetBookPublisher(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var req model.SetBookPublisherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	publisher, err := c.publisherService.SetBookPublisher(bookID, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book publisher updated successfully", publisher)
+}
+
+func publisherIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Publisher ID or slug is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid publisher ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type ReadingProgressController struct {
+	progressService service.ReadingProgressService
+}
+
+func NewReadingProgressController(progressService service.ReadingProgressService) *ReadingProgressController {
+	return &ReadingProgressController{progressService: progressService}
+}
+
+// GetProgress returns the position of one book when book_id is given,
+// otherwise the positions of every owned book
+func (c *ReadingProgressController) GetProgress(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		progress, err := c.progressService.GetAllProgress(user.ID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondSuccess(w, http.StatusOK, "Reading progress retrieved successfully", progress)
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	progress, err := c.progressService.GetProgress(user.ID, bookID)
+	if err != nil {
+		respondProgressError(w, err)
+		return
+	}
+	if progress == nil {
+		respondError(w, http.StatusNotFound, "Reading progress not found")
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Reading progress retrieved successfully", progress)
+}
+
+func (c *ReadingProgressController) SyncProgress(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.UpdateReadingProgressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	progress, applied, err := c.progressService.SyncProgress(user.ID, req)
+	if err != nil {
+		respondProgressError(w, err)
+		return
+	}
+
+	if !applied {
+		respondSuccess(w, http.StatusOK, "A newer reading position exists", progress)
+		return
+	}
+	respondSuccess(w, http.StatusOK, "Reading progress updated successfully", progress)
+}
+
+func respondProgressError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrBookNotPurchased) {
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type RecommendationController struct {
+	recommendationService service.RecommendationService
+	uploadService         service.UploadService
+}
+
+func NewRecommendationController(recommendationService service.RecommendationService, uploadService service.UploadService) *RecommendationController {
+	return &RecommendationController{
+		recommendationService: recommendationService,
+		uploadService:         uploadService,
+	}
+}
+
+// GetRelatedBooks serves GET /api/books/{id}/related, the "customers also
+// bought" list for a book
+func (c *RecommendationController) GetRelatedBooks(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/books/"), "/related")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	limit, err := optionalLimit(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := c.recommendationService.GetRelatedBooks(id, limit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.addImageURLs(books)
+	respondSuccess(w, http.StatusOK, "Related books retrieved successfully", books)
+}
+
+// GetRecommendations lists books recommended from the user's library
+func (c *RecommendationController) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, err := optionalLimit(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	books, err := c.recommendationService.GetRecommendations(user.ID, limit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.addImageURLs(books)
+	respondSuccess(w, http.StatusOK, "Recommendations retrieved successfully", books)
+}
+
+func (c *RecommendationController) addImageURLs(books []entity.Book) {
+	for i := range books {
+		if books[i].GambarBuku != "" {
+			books[i].GambarBuku = c.uploadService.GetImageURL(books[i].GambarBuku)
+		}
+	}
+}
+
+func optionalLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive number")
+	}
+	return limit, nil
+}
@@ -31,3 +31,18 @@ func respondError(w http.ResponseWriter, statusCode int, message string) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// respondErrorCode responds with a machine-readable error code so clients can
+// tell apart errors that share an HTTP status
+func respondErrorCode(w http.ResponseWriter, statusCode int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := model.Response{
+		Status:  "error",
+		Code:    code,
+		Message: message,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type ReviewController struct {
+	reviewService service.ReviewService
+}
+
+func NewReviewController(reviewService service.ReviewService) *ReviewController {
+	return &ReviewController{reviewService: reviewService}
+}
+
+// GetReviews lists the approved reviews of a book (?book_id=) with
+// optional sort, page and limit
+func (c *ReviewController) GetReviews(w http.ResponseWriter, r *http.Request) {
+	req, err := parseReviewListRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := c.reviewService.GetBookReviews(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Reviews retrieved successfully", page)
+}
+
+// GetModerationQueue lists reviews of every status for admins, optionally
+// filtered by book_id and status
+func (c *ReviewController) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	req, err := parseReviewListRequest(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Status = r.URL.Query().Get("status")
+
+	page, err := c.reviewService.GetReviewsForModeration(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Reviews retrieved successfully", page)
+}
+
+func (c *ReviewController) CreateReview(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.CreateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := c.reviewService.CreateReview(user.ID, req)
+	if err != nil {
+		respondReviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Review created successfully", review)
+}
+
+func (c *ReviewController) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var req model.UpdateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := c.reviewService.UpdateReview(user.ID, id, req)
+	if err != nil {
+		respondReviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Review updated successfully", review)
+}
+
+func (c *ReviewController) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	if err := c.reviewService.DeleteReview(user, id); err != nil {
+		respondReviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Review deleted successfully", nil)
+}
+
+// ModerateReview sets a review's status to approved or hidden
+func (c *ReviewController) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var req model.ModerateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := c.reviewService.ModerateReview(id, req)
+	if err != nil {
+		respondReviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Review moderated successfully", review)
+}
+
+// HelpfulVote adds (POST) or removes (DELETE) the user's helpful vote
+func (c *ReviewController) HelpfulVote(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	vote, message := c.reviewService.VoteHelpful, "Review marked as helpful"
+	if r.Method == "DELETE" {
+		vote, message = c.reviewService.RemoveHelpfulVote, "Helpful vote removed"
+	}
+
+	review, err := vote(user.ID, id)
+	if err != nil {
+		respondReviewError(w, err)
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, message, review)
+}
+
+func parseReviewListRequest(r *http.Request) (model.ReviewListRequest, error) {
+	query := r.URL.Query()
+	req := model.ReviewListRequest{Sort: query.Get("sort")}
+
+	ints := []struct {
+		name string
+		dest *int
+	}{
+		{"book_id", &req.BookID},
+		{"page", &req.Page},
+		{"limit", &req.Limit},
+	}
+	for _, param := range ints {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return req, fmt.Errorf("%s must be a positive number", param.name)
+			}
+			*param.dest = n
+		}
+	}
+	return req, nil
+}
+
+func respondReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotVerifiedBuyer), errors.Is(err, service.ErrOwnReviewVote):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrReviewNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrReviewExists):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusBadRequest, err.Error())
+	}
+}
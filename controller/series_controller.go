@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type SeriesController struct {
+	seriesService service.SeriesService
+	uploadService service.UploadService
+}
+
+func NewSeriesController(seriesService service.SeriesService, uploadService service.UploadService) *SeriesController {
+	return &SeriesController{
+		seriesService: seriesService,
+		uploadService: uploadService,
+	}
+}
+
+// GetSeriesList lists series with their volume counts, filtered by ?q= on name
+func (c *SeriesController) GetSeriesList(w http.ResponseWriter, r *http.Request) {
+	list, err := c.seriesService.GetSeriesList(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Series retrieved successfully", list)
+}
+
+func (c *SeriesController) CreateSeries(w http.ResponseWriter, r *http.Request) {
+	var req model.SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	series, err := c.seriesService.CreateSeries(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusCreated, "Series created successfully", series)
+}
+
+// GetSeries serves the series page, looked up by ?id= or ?slug=, with its
+// volumes in order
+func (c *SeriesController) GetSeries(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get("slug")
+	id := 0
+	if slug == "" {
+		var ok bool
+		if id, ok = seriesIDParam(w, r); !ok {
+			return
+		}
+	}
+
+	series, err := c.seriesService.GetSeries(id, slug)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range series.Volumes {
+		if series.Volumes[i].GambarBuku != "" {
+			series.Volumes[i].GambarBuku = c.uploadService.GetImageURL(series.Volumes[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Series retrieved successfully", series)
+}
+
+func (c *SeriesController) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	id, ok := seriesIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	series, err := c.seriesService.UpdateSeries(id, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Series updated successfully", series)
+}
+
+func (c *SeriesController) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	id, ok := seriesIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.seriesService.DeleteSeries(id); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Series deleted successfully", nil)
+}
+
+// SetBookSeries places a book at a position in a series, or takes it out
+// of its series when series_id is null
+func (c *SeriesController) SetBookSeries(w http.ResponseWriter, r *http.Request) {
+	bookIDStr := r.URL.Query().Get("book_id")
+	if bookIDStr == "" {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var req model.SetBookSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	series, err := c.seriesService.SetBookSeries(bookID, req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book series updated successfully", series)
+}
+
+// AddSeriesToCart adds every volume the user doesn't own yet to the cart,
+// in the format given by ?format=
+func (c *SeriesController) AddSeriesToCart(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, ok := seriesIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := c.seriesService.AddSeriesToCart(user.ID, id, r.URL.Query().Get("format"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Series added to cart successfully", result)
+}
+
+func seriesIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		respondError(w, http.StatusBadRequest, "Series ID or slug is required")
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid series ID")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/service"
+)
+
+type WishlistController struct {
+	wishlistService service.WishlistService
+	uploadService   service.UploadService
+}
+
+func NewWishlistController(wishlistService service.WishlistService, uploadService service.UploadService) *WishlistController {
+	return &WishlistController{
+		wishlistService: wishlistService,
+		uploadService:   uploadService,
+	}
+}
+
+func (c *WishlistController) GetWishlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	items, err := c.wishlistService.GetWishlist(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Add image URLs to response
+	for i := range items {
+		if items[i].GambarBuku != "" {
+			items[i].GambarBuku = c.uploadService.GetImageURL(items[i].GambarBuku)
+		}
+	}
+
+	respondSuccess(w, http.StatusOK, "Wishlist retrieved successfully", items)
+}
+
+func (c *WishlistController) AddToWishlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req model.AddToWishlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.BookID <= 0 {
+		respondError(w, http.StatusBadRequest, "Book ID is required")
+		return
+	}
+
+	if err := c.wishlistService.AddToWishlist(user.ID, req.BookID); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book added to wishlist successfully", nil)
+}
+
+func (c *WishlistController) RemoveFromWishlist(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		respondError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookID, err := strconv.Atoi(r.URL.Query().Get("book_id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	if err := c.wishlistService.RemoveFromWishlist(user.ID, bookID); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondSuccess(w, http.StatusOK, "Book removed from wishlist successfully", nil)
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// Annotation types
+const (
+	AnnotationHighlight = "highlight"
+	AnnotationBookmark  = "bookmark"
+	AnnotationNote      = "note"
+)
+
+// Annotation is a highlight, bookmark or note anchored to an EPUB CFI. Deleted
+// annotations are kept as tombstones so incremental sync can report them.
+type Annotation struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	BookID     int        `json:"book_id"`
+	NamaBarang string     `json:"nama_barang,omitempty"`
+	Type       string     `json:"type"`
+	CFI        string     `json:"cfi"`
+	Color      string     `json:"color,omitempty"`
+	Text       string     `json:"text,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ChangeSeq  int64      `json:"-"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// Contributor roles on a book
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+// ContributorRoles lists the valid contributor roles
+var ContributorRoles = []string{RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator}
+
+// Author is a person credited on books in any contributor role
+type Author struct {
+	ID        int          `json:"id"`
+	Nama      string       `json:"nama"`
+	Slug      string       `json:"slug"`
+	Biografi  string       `json:"biografi"`
+	BookCount int          `json:"book_count"`
+	Books     []AuthorBook `json:"books,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// AuthorBook is a book listed on an author page with the author's role
+type AuthorBook struct {
+	Book
+	Role string `json:"role"`
+}
+
+// BookContributor is a credit shown on a book
+type BookContributor struct {
+	AuthorID int    `json:"author_id"`
+	Nama     string `json:"nama"`
+	Slug     string `json:"slug"`
+	Role     string `json:"role"`
+}
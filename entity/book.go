@@ -3,13 +3,29 @@ package entity
 import "time"
 
 type Book struct {
-	ID         int       `json:"id"`
-	NamaBarang string    `json:"nama_barang"`
-	Stok       int       `json:"stok"`
-	Terjual    int       `json:"terjual"`
-	Harga      int       `json:"harga"`
-	Keterangan string    `json:"keterangan"`
-	GambarBuku string    `json:"gambar_buku"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID            int               `json:"id"`
+	NamaBarang    string            `json:"nama_barang"`
+	Stok          int               `json:"stok"`
+	Terjual       int               `json:"terjual"`
+	Harga         int               `json:"harga"`
+	Keterangan    string            `json:"keterangan"`
+	GambarBuku    string            `json:"gambar_buku"`
+	Penulis       string            `json:"penulis"`
+	Penerbit      string            `json:"penerbit"`
+	Bahasa        string            `json:"bahasa"`
+	Subjek        string            `json:"subjek"`
+	ISBN10        string            `json:"isbn_10"`
+	ISBN13        string            `json:"isbn_13"`
+	RatingAverage float64           `json:"rating_average"`
+	RatingCount   int               `json:"rating_count"`
+	InStock       bool              `json:"in_stock"`
+	Categories    []Category        `json:"categories,omitempty"`
+	Contributors  []BookContributor `json:"contributors,omitempty"`
+	Publisher     *Publisher        `json:"publisher,omitempty"`
+	Series        *BookSeries       `json:"series,omitempty"`
+	Variants      []BookVariant     `json:"variants,omitempty"`
+	Previews      []BookPreview     `json:"previews,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DeletedAt     *time.Time        `json:"deleted_at,omitempty"`
 }
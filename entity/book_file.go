@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type BookFile struct {
+	ID           int       `json:"id"`
+	BookID       int       `json:"book_id"`
+	Format       string    `json:"format"`
+	FileName     string    `json:"-"`
+	OriginalName string    `json:"original_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+}
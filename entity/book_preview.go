@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// Book preview sources
+const (
+	PreviewSourceUploaded  = "uploaded"
+	PreviewSourceGenerated = "generated"
+)
+
+// BookPreview is a free sample of a book that anyone may download. Length
+// is the number of chapters (EPUB) or pages (PDF) of a generated preview.
+type BookPreview struct {
+	ID           int       `json:"id"`
+	BookID       int       `json:"book_id"`
+	Format       string    `json:"format"`
+	FileName     string    `json:"-"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Source       string    `json:"source"`
+	SourceFileID *int      `json:"source_file_id,omitempty"`
+	Length       int       `json:"length,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
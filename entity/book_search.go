@@ -0,0 +1,21 @@
+package entity
+
+// Full-text search dictionaries. Each has its own generated tsvector column
+// on books, so switching between them does not need a reindex.
+const (
+	SearchDictionaryIndonesian = "indonesian"
+	SearchDictionarySimple     = "simple"
+)
+
+// BookSearchResult is a book matched by full-text search with its relevance
+// and highlighted snippets (matches wrapped in <mark></mark>)
+type BookSearchResult struct {
+	Book
+	Rank      float64       `json:"rank"`
+	Highlight BookHighlight `json:"highlight"`
+}
+
+type BookHighlight struct {
+	NamaBarang string `json:"nama_barang"`
+	Keterangan string `json:"keterangan"`
+}
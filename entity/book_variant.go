@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// Formats a book can be sold in
+const (
+	VariantFormatEPUB      = "epub"
+	VariantFormatPDF       = "pdf"
+	VariantFormatAudiobook = "audiobook"
+	VariantFormatPrint     = "print"
+)
+
+// BookVariant is one format of a book with its own price. Stok is nil for
+// digital formats that are not limited by stock.
+type BookVariant struct {
+	ID        int       `json:"id"`
+	BookID    int       `json:"book_id"`
+	Format    string    `json:"format"`
+	Harga     int       `json:"harga"`
+	Stok      *int      `json:"stok"`
+	Terjual   int       `json:"terjual"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
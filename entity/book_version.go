@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+// Book history actions. initial records the state of a book that existed
+// before its history was kept, just ahead of its first recorded change.
+const (
+	BookActionInitial  = "initial"
+	BookActionCreate   = "create"
+	BookActionUpdate   = "update"
+	BookActionRollback = "rollback"
+)
+
+// BookVersion is one entry of a book's change history: the book as it was
+// saved, who saved it, and the fields that changed
+type BookVersion struct {
+	ID         int               `json:"id"`
+	BookID     int               `json:"book_id"`
+	Version    int               `json:"version"`
+	Action     string            `json:"action"`
+	UserID     int               `json:"user_id"`
+	Username   string            `json:"username"`
+	RevertedTo int               `json:"reverted_to,omitempty"`
+	Snapshot   BookSnapshot      `json:"snapshot"`
+	Changes    []BookFieldChange `json:"changes"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// BookSnapshot holds the editable fields of a book. InStock is derived from
+// its formats and is not stored in the history.
+type BookSnapshot struct {
+	NamaBarang string `json:"nama_barang"`
+	Stok       int    `json:"stok"`
+	Terjual    int    `json:"terjual"`
+	Harga      int    `json:"harga"`
+	Keterangan string `json:"keterangan"`
+	GambarBuku string `json:"gambar_buku"`
+	Penulis    string `json:"penulis"`
+	Penerbit   string `json:"penerbit"`
+	Bahasa     string `json:"bahasa"`
+	Subjek     string `json:"subjek"`
+	ISBN10     string `json:"isbn_10"`
+	ISBN13     string `json:"isbn_13"`
+	InStock    bool   `json:"-"`
+}
+
+// BookFieldChange is the before and after value of one changed field
+type BookFieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// BookChange is the editable fields of a book before and after it was saved
+type BookChange struct {
+	BookID int
+	Before BookSnapshot
+	After  BookSnapshot
+}
@@ -6,6 +6,7 @@ type Cart struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
 	BookID    int       `json:"book_id"`
+	VariantID int       `json:"variant_id"`
 	Jumlah    int       `json:"jumlah"`
 	Harga     int       `json:"harga"`
 	CreatedAt time.Time `json:"created_at"`
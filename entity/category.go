@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// Category is a node of the genre taxonomy. Categories form a tree through
+// ParentID; a book may belong to any number of categories.
+type Category struct {
+	ID         int        `json:"id"`
+	ParentID   *int       `json:"parent_id"`
+	Nama       string     `json:"nama"`
+	Slug       string     `json:"slug"`
+	Keterangan string     `json:"keterangan"`
+	BookCount  int        `json:"book_count"`
+	Children   []Category `json:"children,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
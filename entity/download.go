@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type Download struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	OrderItemID int       `json:"order_item_id"`
+	BookFileID  *int      `json:"book_file_id"`
+	BookID      int       `json:"book_id"`
+	NamaBarang  string    `json:"nama_barang"`
+	Format      string    `json:"format"`
+	IPAddress   string    `json:"ip_address"`
+	UserAgent   string    `json:"user_agent"`
+	Bytes       int64     `json:"bytes"`
+	Counted     bool      `json:"counted"`
+	Partial     bool      `json:"partial"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+// Catalog import file formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatONIX = "onix"
+)
+
+// Import modes: transaction applies the whole file or nothing, chunked
+// commits every chunk on its own and can be resumed after a failure
+const (
+	ImportModeTransaction = "transaction"
+	ImportModeChunked     = "chunked"
+)
+
+// Import job statuses
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+type ImportJob struct {
+	ID            int              `json:"id"`
+	UserID        int              `json:"user_id"`
+	Format        string           `json:"format"`
+	FileName      string           `json:"-"`
+	OriginalName  string           `json:"original_name"`
+	Mode          string           `json:"mode"`
+	ChunkSize     int              `json:"chunk_size"`
+	DryRun        bool             `json:"dry_run"`
+	Status        string           `json:"status"`
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	CreatedCount  int              `json:"created_count"`
+	UpdatedCount  int              `json:"updated_count"`
+	ErrorCount    int              `json:"error_count"`
+	Errors        []ImportRowError `json:"errors,omitempty"`
+	Message       string           `json:"message,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	FinishedAt    *time.Time       `json:"finished_at"`
+}
+
+// ImportRowError is a record that could not be imported. Row is the line
+// number for CSV (the header is line 1) and the product number for ONIX.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	ISBN    string `json:"isbn,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportRecord is one parsed record of an import file. Records that failed
+// validation carry Error and are reported without touching the database.
+type ImportRecord struct {
+	Row           int
+	Book          Book
+	Stok          *int // nil keeps the current stock of an existing book
+	Contributors  []BookContributor
+	PublisherSlug string
+	Error         string
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// LibraryItem is a purchased book. PurchasedFormats are the formats it was
+// bought in; Formats and Files are the ebook files those formats include.
+type LibraryItem struct {
+	BookID           int           `json:"book_id"`
+	NamaBarang       string        `json:"nama_barang"`
+	Penulis          string        `json:"penulis"`
+	GambarBuku       string        `json:"gambar_buku"`
+	OrderID          int           `json:"order_id"`
+	PurchasedAt      time.Time     `json:"purchased_at"`
+	PurchasedFormats []string      `json:"purchased_formats"`
+	Formats          []string      `json:"formats"`
+	Files            []LibraryFile `json:"files"`
+}
+
+type LibraryFile struct {
+	ID          int    `json:"id"`
+	Format      string `json:"format"`
+	Size        int64  `json:"size"`
+	DownloadURL string `json:"download_url"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Notification types
+const (
+	NotificationPriceDrop   = "price_drop"
+	NotificationBackInStock = "back_in_stock"
+)
+
+// Notification is an in-app message for a user, such as a price drop on a
+// wishlisted book
+type Notification struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	BookID     int        `json:"book_id,omitempty"`
+	NamaBarang string     `json:"nama_barang,omitempty"`
+	Type       string     `json:"type"`
+	Message    string     `json:"message"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -2,6 +2,13 @@ package entity
 
 import "time"
 
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusCompleted = "completed"
+	OrderStatusCancelled = "cancelled"
+)
+
 type Order struct {
 	ID         int       `json:"id"`
 	UserID     int       `json:"user_id"`
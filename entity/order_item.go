@@ -3,10 +3,13 @@ package entity
 import "time"
 
 type OrderItem struct {
-	ID        int       `json:"id"`
-	OrderID   int       `json:"order_id"`
-	BookID    int       `json:"book_id"`
-	Jumlah    int       `json:"jumlah"`
-	Harga     int       `json:"harga"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int       `json:"id"`
+	OrderID    int       `json:"order_id"`
+	BookID     int       `json:"book_id"`
+	VariantID  int       `json:"variant_id"`
+	Format     string    `json:"format"`
+	NamaBarang string    `json:"nama_barang"`
+	Jumlah     int       `json:"jumlah"`
+	Harga      int       `json:"harga"`
+	CreatedAt  time.Time `json:"created_at"`
 }
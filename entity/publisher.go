@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type Publisher struct {
+	ID         int       `json:"id"`
+	Nama       string    `json:"nama"`
+	Slug       string    `json:"slug"`
+	Keterangan string    `json:"keterangan"`
+	BookCount  int       `json:"book_count"`
+	Books      []Book    `json:"books,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// Position types for ReadingProgress.Position
+const (
+	PositionTypeCFI  = "cfi"
+	PositionTypePage = "page"
+)
+
+type ReadingProgress struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	BookID       int       `json:"book_id"`
+	NamaBarang   string    `json:"nama_barang,omitempty"`
+	Position     string    `json:"position"`
+	PositionType string    `json:"position_type"`
+	Percent      float64   `json:"percent"`
+	Device       string    `json:"device"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Review moderation statuses. Reviews are published as approved; admins
+// can hide them and approve them again.
+const (
+	ReviewStatusApproved = "approved"
+	ReviewStatusHidden   = "hidden"
+)
+
+// Review is a verified buyer's star rating of a book
+type Review struct {
+	ID           int       `json:"id"`
+	BookID       int       `json:"book_id"`
+	NamaBarang   string    `json:"nama_barang,omitempty"`
+	UserID       int       `json:"user_id"`
+	Username     string    `json:"username"`
+	Rating       int       `json:"rating"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body"`
+	Status       string    `json:"status"`
+	HelpfulCount int       `json:"helpful_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+type Series struct {
+	ID           int            `json:"id"`
+	Nama         string         `json:"nama"`
+	Slug         string         `json:"slug"`
+	Keterangan   string         `json:"keterangan"`
+	TotalVolumes int            `json:"total_volumes"`
+	Volumes      []SeriesVolume `json:"volumes,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// SeriesVolume is a book listed at its position in a series
+type SeriesVolume struct {
+	Position int `json:"position"`
+	Book
+}
+
+// BookSeries places a book in its series, e.g. volume 3 of 7. TotalVolumes
+// is the highest position in the series.
+type BookSeries struct {
+	ID           int    `json:"id"`
+	Nama         string `json:"nama"`
+	Slug         string `json:"slug"`
+	Position     int    `json:"position"`
+	TotalVolumes int    `json:"total_volumes"`
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// WishlistItem is a book on a user's wishlist with its current price and
+// stock. HargaAwal is the price when the book was added.
+type WishlistItem struct {
+	ID         int       `json:"id"`
+	BookID     int       `json:"book_id"`
+	NamaBarang string    `json:"nama_barang"`
+	Harga      int       `json:"harga"`
+	HargaAwal  int       `json:"harga_awal"`
+	Stok       int       `json:"stok"`
+	GambarBuku string    `json:"gambar_buku"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/LanangDepok/ebook-store/config"
 	"github.com/LanangDepok/ebook-store/controller"
+	"github.com/LanangDepok/ebook-store/entity"
 	"github.com/LanangDepok/ebook-store/middleware"
 	"github.com/LanangDepok/ebook-store/repository"
 	"github.com/LanangDepok/ebook-store/router"
@@ -27,6 +32,25 @@ func main() {
 	}
 	log.Printf("Upload directory ready: %s", uploadDir)
 
+	// Create private storage for ebook files (never served statically)
+	bookFileDir := os.Getenv("BOOK_FILE_DIR")
+	if bookFileDir == "" {
+		bookFileDir = "storage/books"
+	}
+	if err := os.MkdirAll(bookFileDir, 0750); err != nil {
+		log.Fatalf("Failed to create book file directory: %v", err)
+	}
+	log.Printf("Book file directory ready: %s", bookFileDir)
+
+	// Uploaded catalog import files, kept so chunked imports can be resumed
+	importDir := os.Getenv("IMPORT_DIR")
+	if importDir == "" {
+		importDir = "storage/imports"
+	}
+	if err := os.MkdirAll(importDir, 0750); err != nil {
+		log.Fatalf("Failed to create import directory: %v", err)
+	}
+
 	// Initialize database
 	db := config.NewDatabase()
 	defer db.Close()
@@ -46,27 +70,149 @@ func main() {
 		baseURL = "http://localhost:" + port
 	}
 
+	// Signing key for download links; rotating it revokes every issued link
+	downloadSigningKey := os.Getenv("DOWNLOAD_SIGNING_KEY")
+	if downloadSigningKey == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		downloadSigningKey = hex.EncodeToString(b)
+		log.Println("Warning: DOWNLOAD_SIGNING_KEY not set, download links will not survive a restart")
+	}
+
+	downloadLinkTTL := 15 * time.Minute
+	if ttl := os.Getenv("DOWNLOAD_LINK_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid DOWNLOAD_LINK_TTL: %v", err)
+		}
+		downloadLinkTTL = parsed
+	}
+
+	// Maximum downloads per purchased order item (0 means unlimited)
+	maxDownloads := 5
+	if max := os.Getenv("MAX_DOWNLOADS_PER_PURCHASE"); max != "" {
+		parsed, err := strconv.Atoi(max)
+		if err != nil {
+			log.Fatalf("Invalid MAX_DOWNLOADS_PER_PURCHASE: %v", err)
+		}
+		maxDownloads = parsed
+	}
+
+	// Default length of generated previews: chapters of an EPUB, pages of a PDF
+	previewChapters := 3
+	if chapters := os.Getenv("PREVIEW_CHAPTERS"); chapters != "" {
+		parsed, err := strconv.Atoi(chapters)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid PREVIEW_CHAPTERS: %q", chapters)
+		}
+		previewChapters = parsed
+	}
+	previewPages := 10
+	if pages := os.Getenv("PREVIEW_PAGES"); pages != "" {
+		parsed, err := strconv.Atoi(pages)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid PREVIEW_PAGES: %q", pages)
+		}
+		previewPages = parsed
+	}
+
+	// How often the "customers also bought" recommendations are recomputed
+	recommendationInterval := time.Hour
+	if interval := os.Getenv("RECOMMENDATION_REFRESH_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid RECOMMENDATION_REFRESH_INTERVAL: %q", interval)
+		}
+		recommendationInterval = parsed
+	}
+
+	// Default full-text search dictionary: "indonesian" (stemming) or "simple"
+	searchDictionary := os.Getenv("SEARCH_DICTIONARY")
+	if searchDictionary == "" {
+		searchDictionary = entity.SearchDictionaryIndonesian
+	}
+	if searchDictionary != entity.SearchDictionaryIndonesian && searchDictionary != entity.SearchDictionarySimple {
+		log.Fatalf("Invalid SEARCH_DICTIONARY: %q", searchDictionary)
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db.DB)
 	sessionRepo := repository.NewSessionRepository(db.DB)
 	bookRepo := repository.NewBookRepository(db.DB)
+	categoryRepo := repository.NewCategoryRepository(db.DB)
+	authorRepo := repository.NewAuthorRepository(db.DB)
+	publisherRepo := repository.NewPublisherRepository(db.DB)
+	seriesRepo := repository.NewSeriesRepository(db.DB)
 	cartRepo := repository.NewCartRepository(db.DB)
 	orderRepo := repository.NewOrderRepository(db.DB)
+	bookFileRepo := repository.NewBookFileRepository(db.DB)
+	bookPreviewRepo := repository.NewBookPreviewRepository(db.DB)
+	bookVariantRepo := repository.NewBookVariantRepository(db.DB)
+	downloadRepo := repository.NewDownloadRepository(db.DB)
+	libraryRepo := repository.NewLibraryRepository(db.DB)
+	progressRepo := repository.NewReadingProgressRepository(db.DB)
+	annotationRepo := repository.NewAnnotationRepository(db.DB)
+	importRepo := repository.NewImportRepository(db.DB)
+	bookHistoryRepo := repository.NewBookHistoryRepository(db.DB)
+	reviewRepo := repository.NewReviewRepository(db.DB)
+	wishlistRepo := repository.NewWishlistRepository(db.DB)
+	notificationRepo := repository.NewNotificationRepository(db.DB)
+	recommendationRepo := repository.NewRecommendationRepository(db.DB)
 
 	// Initialize services
 	authService := service.NewAuthService(userRepo, sessionRepo)
 	uploadService := service.NewUploadService(uploadDir, baseURL)
-	bookService := service.NewBookService(bookRepo)
-	cartService := service.NewCartService(cartRepo, bookRepo)
-	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo, db.DB)
+	bookService := service.NewBookService(bookRepo, categoryRepo, authorRepo, publisherRepo, seriesRepo, bookPreviewRepo, bookVariantRepo, bookHistoryRepo, notificationRepo, searchDictionary)
+	downloadSigner := service.NewDownloadSigner(downloadSigningKey, downloadLinkTTL)
+	bookVariantService := service.NewBookVariantService(bookVariantRepo, bookRepo, notificationRepo)
+	bookPreviewService := service.NewBookPreviewService(bookPreviewRepo, bookFileRepo, bookRepo, bookFileDir, previewChapters, previewPages)
+	bookFileService := service.NewBookFileService(bookFileRepo, bookRepo, orderRepo, downloadRepo, userRepo, bookPreviewService, downloadSigner, bookFileDir, baseURL, maxDownloads)
+	downloadService := service.NewDownloadService(downloadRepo)
+	libraryService := service.NewLibraryService(libraryRepo, baseURL)
+	opdsService := service.NewOPDSService(bookRepo, bookService, libraryService, bookFileService, uploadService, baseURL)
+	progressService := service.NewReadingProgressService(progressRepo, orderRepo)
+	annotationService := service.NewAnnotationService(annotationRepo, orderRepo)
+	reviewService := service.NewReviewService(reviewRepo, bookRepo, orderRepo)
+	categoryService := service.NewCategoryService(categoryRepo, bookRepo)
+	authorService := service.NewAuthorService(authorRepo, bookRepo)
+	publisherService := service.NewPublisherService(publisherRepo, bookRepo)
+	importService := service.NewImportService(importRepo, notificationRepo, importDir)
+	exportService := service.NewExportService(bookRepo, uploadService)
+	cartService := service.NewCartService(cartRepo, bookRepo, bookVariantRepo)
+	seriesService := service.NewSeriesService(seriesRepo, bookRepo, orderRepo, cartRepo, cartService)
+	wishlistService := service.NewWishlistService(wishlistRepo, bookRepo)
+	notificationService := service.NewNotificationService(notificationRepo)
+	recommendationService := service.NewRecommendationService(recommendationRepo)
+	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo, bookVariantRepo, db.DB)
 
 	// Initialize controllers
 	authController := controller.NewAuthController(authService)
-	bookController := controller.NewBookController(bookService, uploadService)
+	bookController := controller.NewBookController(bookService, uploadService, bookFileService)
+	bookFileController := controller.NewBookFileController(bookFileService)
+	bookPreviewController := controller.NewBookPreviewController(bookPreviewService)
+	bookVariantController := controller.NewBookVariantController(bookVariantService)
 	cartController := controller.NewCartController(cartService, uploadService)
+	wishlistController := controller.NewWishlistController(wishlistService, uploadService)
+	notificationController := controller.NewNotificationController(notificationService)
+	recommendationController := controller.NewRecommendationController(recommendationService, uploadService)
 	orderController := controller.NewOrderController(orderService)
+	downloadController := controller.NewDownloadController(downloadService)
+	libraryController := controller.NewLibraryController(libraryService, uploadService)
+	opdsController := controller.NewOPDSController(opdsService)
+	progressController := controller.NewReadingProgressController(progressService)
+	annotationController := controller.NewAnnotationController(annotationService)
+	reviewController := controller.NewReviewController(reviewService)
+	categoryController := controller.NewCategoryController(categoryService)
+	authorController := controller.NewAuthorController(authorService, uploadService)
+	publisherController := controller.NewPublisherController(publisherService, uploadService)
+	seriesController := controller.NewSeriesController(seriesService, uploadService)
+	importController := controller.NewImportController(importService)
+	exportController := controller.NewExportController(exportService)
 	uploadController := controller.NewUploadController(uploadService, uploadDir)
 
+	// Refresh recommendations now and then periodically
+	recommendationService.StartRefreshJob(recommendationInterval)
+
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware(db.DB)
 
@@ -74,8 +220,26 @@ func main() {
 	appRouter := router.NewRouter(
 		authController,
 		bookController,
+		bookFileController,
+		bookPreviewController,
+		bookVariantController,
 		cartController,
+		wishlistController,
+		notificationController,
+		recommendationController,
 		orderController,
+		downloadController,
+		libraryController,
+		opdsController,
+		progressController,
+		annotationController,
+		reviewController,
+		categoryController,
+		authorController,
+		publisherController,
+		seriesController,
+		importController,
+		exportController,
 		uploadController,
 		authMiddleware,
 	)
@@ -105,18 +269,119 @@ func main() {
 	log.Println("    GET    /api/books")
 	log.Println("    POST   /api/books (admin only)")
 	log.Println("    GET    /api/books/detail?id=1")
+	log.Println("    GET    /api/books/isbn/{isbn}")
 	log.Println("    PUT    /api/books/detail?id=1 (admin only)")
 	log.Println("    DELETE /api/books/detail?id=1 (admin only)")
+	log.Println("    GET    /api/books/deleted (admin only)")
+	log.Println("    POST   /api/books/restore?id=1 (admin only)")
+	log.Println("    GET    /api/books/history?id=1 (admin only)")
+	log.Println("    POST   /api/books/rollback?id=1&version=2 (admin only)")
+	log.Println("    PUT    /api/books/categories?book_id=1 (admin only)")
+	log.Println("    PUT    /api/books/contributors?book_id=1 (admin only)")
+	log.Println("    PUT    /api/books/publisher?book_id=1 (admin only)")
+	log.Println("    PUT    /api/books/series?book_id=1 (admin only)")
+	log.Println("    GET    /api/books/{id}/related")
+	log.Println("  Categories:")
+	log.Println("    GET    /api/categories")
+	log.Println("    POST   /api/categories (admin only)")
+	log.Println("    GET    /api/categories/detail?id=1")
+	log.Println("    PUT    /api/categories/detail?id=1 (admin only)")
+	log.Println("    DELETE /api/categories/detail?id=1 (admin only)")
+	log.Println("  Authors:")
+	log.Println("    GET    /api/authors")
+	log.Println("    POST   /api/authors (admin only)")
+	log.Println("    GET    /api/authors/detail?id=1 or ?slug=name")
+	log.Println("    PUT    /api/authors/detail?id=1 (admin only)")
+	log.Println("    DELETE /api/authors/detail?id=1 (admin only)")
+	log.Println("  Publishers:")
+	log.Println("    GET    /api/publishers")
+	log.Println("    POST   /api/publishers (admin only)")
+	log.Println("    GET    /api/publishers/detail?id=1 or ?slug=name")
+	log.Println("    PUT    /api/publishers/detail?id=1 (admin only)")
+	log.Println("    DELETE /api/publishers/detail?id=1 (admin only)")
+	log.Println("  Series:")
+	log.Println("    GET    /api/series")
+	log.Println("    POST   /api/series (admin only)")
+	log.Println("    GET    /api/series/detail?id=1 or ?slug=name")
+	log.Println("    PUT    /api/series/detail?id=1 (admin only)")
+	log.Println("    DELETE /api/series/detail?id=1 (admin only)")
+	log.Println("    POST   /api/series/cart?id=1&format=epub")
+	log.Println("  Imports:")
+	log.Println("    GET    /api/imports (admin only)")
+	log.Println("    POST   /api/imports (admin only)")
+	log.Println("    GET    /api/imports/detail?id=1 (admin only)")
+	log.Println("    POST   /api/imports/resume?id=1 (admin only)")
+	log.Println("  Export:")
+	log.Println("    GET    /api/books/export?format=csv|jsonl|onix (admin only)")
+	log.Println("  Book Files:")
+	log.Println("    GET    /api/books/files?book_id=1")
+	log.Println("    POST   /api/books/files?book_id=1 (admin only)")
+	log.Println("    DELETE /api/books/files?id=1 (admin only)")
+	log.Println("    GET    /api/books/files/download?id=1")
+	log.Println("    GET    /api/books/files/stream?id=1 (Range supported)")
+	log.Println("    POST   /api/books/files/link?id=1")
+	log.Println("    GET    /api/books/files/signed?book_id=1&file_id=1&user_id=2&expires=...&signature=...")
+	log.Println("  Book Previews:")
+	log.Println("    GET    /api/books/preview?book_id=1&format=epub (no login required)")
+	log.Println("    GET    /api/books/previews?book_id=1")
+	log.Println("    POST   /api/books/preview?book_id=1 (admin only)")
+	log.Println("    POST   /api/books/preview/generate?file_id=1&length=3 (admin only)")
+	log.Println("    DELETE /api/books/preview?id=1 (admin only)")
+	log.Println("    GET    /api/books/variants?book_id=1")
+	log.Println("    POST   /api/books/variants?book_id=1 (admin only)")
+	log.Println("    PUT    /api/books/variants?id=1 (admin only)")
+	log.Println("    DELETE /api/books/variants?id=1 (admin only)")
 	log.Println("  Cart:")
 	log.Println("    GET    /api/cart")
 	log.Println("    POST   /api/cart")
 	log.Println("    PUT    /api/cart/item?id=1")
 	log.Println("    DELETE /api/cart/item?id=1")
 	log.Println("    DELETE /api/cart (clear cart)")
+	log.Println("  Wishlist:")
+	log.Println("    GET    /api/wishlist")
+	log.Println("    POST   /api/wishlist")
+	log.Println("    DELETE /api/wishlist?book_id=1")
+	log.Println("  Recommendations:")
+	log.Println("    GET    /api/recommendations")
+	log.Println("  Notifications:")
+	log.Println("    GET    /api/notifications?unread=true")
+	log.Println("    POST   /api/notifications/read?id=1 (omit id to mark all)")
 	log.Println("  Orders:")
 	log.Println("    GET    /api/orders")
 	log.Println("    POST   /api/orders")
 	log.Println("    GET    /api/orders/detail?id=1")
+	log.Println("    PUT    /api/orders/status?id=1 (admin only)")
+	log.Println("  Library:")
+	log.Println("    GET    /api/library")
+	log.Println("  Reading Progress:")
+	log.Println("    GET    /api/progress")
+	log.Println("    GET    /api/progress?book_id=1")
+	log.Println("    PUT    /api/progress")
+	log.Println("  Annotations:")
+	log.Println("    GET    /api/annotations?book_id=1&since=42")
+	log.Println("    POST   /api/annotations")
+	log.Println("    PUT    /api/annotations?id=1")
+	log.Println("    DELETE /api/annotations?id=1")
+	log.Println("    GET    /api/annotations/export?format=markdown")
+	log.Println("  Reviews:")
+	log.Println("    GET    /api/reviews?book_id=1&sort=helpful")
+	log.Println("    POST   /api/reviews")
+	log.Println("    PUT    /api/reviews?id=1")
+	log.Println("    DELETE /api/reviews?id=1")
+	log.Println("    POST   /api/reviews/helpful?id=1")
+	log.Println("    DELETE /api/reviews/helpful?id=1")
+	log.Println("    GET    /api/reviews/moderation?status=hidden (admin only)")
+	log.Println("    PUT    /api/reviews/moderate?id=1 (admin only)")
+	log.Println("  OPDS:")
+	log.Println("    GET    /opds (OPDS 1.2 Atom)")
+	log.Println("    GET    /opds/new | /opds/bestsellers | /opds/search?q=go")
+	log.Println("    GET    /opds/shelf (Basic or Bearer auth)")
+	log.Println("    GET    /opds/v2 (OPDS 2.0 JSON)")
+	log.Println("    GET    /opds/v2/new | /opds/v2/bestsellers | /opds/v2/search?query=go")
+	log.Println("    GET    /opds/v2/shelf (Basic or Bearer auth)")
+	log.Println("  Downloads:")
+	log.Println("    GET    /api/downloads")
+	log.Println("    POST   /api/downloads/reset (admin only)")
 	log.Println("  Upload:")
 	log.Println("    POST   /api/upload/image (admin only)")
 	log.Println("  Static:")
@@ -10,6 +10,7 @@ import (
 
 	"github.com/LanangDepok/ebook-store/entity"
 	"github.com/LanangDepok/ebook-store/model"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type contextKey string
@@ -54,6 +55,34 @@ func (m *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	})
 }
 
+// RequireReaderAuth accepts a bearer token or HTTP Basic credentials, since
+// OPDS reader apps such as KOReader and Thorium only support Basic auth
+func (m *AuthMiddleware) RequireReaderAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var user *entity.User
+		var err error
+
+		if token := m.extractToken(r); token != "" {
+			user, err = m.validateToken(token)
+		} else if username, password, ok := r.BasicAuth(); ok {
+			user, err = m.validateCredentials(username, password)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ebook-store"`)
+			m.unauthorizedResponse(w, "Missing authentication credentials")
+			return
+		}
+
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ebook-store"`)
+			m.unauthorizedResponse(w, "Invalid credentials")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
 func (m *AuthMiddleware) extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -88,6 +117,29 @@ func (m *AuthMiddleware) validateToken(token string) (*entity.User, error) {
 	return &user, nil
 }
 
+func (m *AuthMiddleware) validateCredentials(username, password string) (*entity.User, error) {
+	query := `
+		SELECT id, username, password, email, role
+		FROM users
+		WHERE username = $1
+	`
+
+	var user entity.User
+	err := m.db.QueryRow(query, username).Scan(
+		&user.ID, &user.Username, &user.Password, &user.Email, &user.Role,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, err
+	}
+	user.Password = ""
+
+	return &user, nil
+}
+
 func (m *AuthMiddleware) unauthorizedResponse(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusUnauthorized)
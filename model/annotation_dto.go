@@ -0,0 +1,27 @@
+package model
+
+import "github.com/LanangDepok/ebook-store/entity"
+
+// Annotation Requests
+type CreateAnnotationRequest struct {
+	BookID int    `json:"book_id" validate:"required"`
+	Type   string `json:"type" validate:"required,oneof=highlight bookmark note"`
+	CFI    string `json:"cfi" validate:"required"`
+	Color  string `json:"color"`
+	Text   string `json:"text"`
+	Note   string `json:"note"`
+}
+
+type UpdateAnnotationRequest struct {
+	CFI   *string `json:"cfi"`
+	Color *string `json:"color"`
+	Text  *string `json:"text"`
+	Note  *string `json:"note"`
+}
+
+// AnnotationSyncResponse carries the changes since the client's cursor. The
+// client sends Cursor back as since on its next sync.
+type AnnotationSyncResponse struct {
+	Annotations []entity.Annotation `json:"annotations"`
+	Cursor      int64               `json:"cursor"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// Author Requests
+type AuthorRequest struct {
+	Nama     string `json:"nama" validate:"required"`
+	Slug     string `json:"slug"`
+	Biografi string `json:"biografi"`
+}
+
+type PublisherRequest struct {
+	Nama       string `json:"nama" validate:"required"`
+	Slug       string `json:"slug"`
+	Keterangan string `json:"keterangan"`
+}
+
+type BookContributorRequest struct {
+	AuthorID int    `json:"author_id" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=author editor translator illustrator"`
+}
+
+// SetBookContributorsRequest replaces every credit of a book; the order of
+// Contributors is kept for display
+type SetBookContributorsRequest struct {
+	Contributors []BookContributorRequest `json:"contributors"`
+}
+
+type SetBookPublisherRequest struct {
+	PublisherID *int `json:"publisher_id"`
+}
@@ -7,6 +7,15 @@ type CreateBookRequest struct {
 	Harga      int    `json:"harga" validate:"required,min=0"`
 	Keterangan string `json:"keterangan"`
 	GambarBuku string `json:"gambar_buku"`
+	Penulis    string `json:"penulis"`
+	Penerbit   string `json:"penerbit"`
+	Bahasa     string `json:"bahasa"`
+	Subjek     string `json:"subjek"`
+	ISBN10     string `json:"isbn_10"`
+	ISBN13     string `json:"isbn_13"`
+	// Format is the format the book is first sold in, epub by default.
+	// Harga and Stok are its price and stock.
+	Format string `json:"format"`
 }
 
 type UpdateBookRequest struct {
@@ -16,4 +25,35 @@ type UpdateBookRequest struct {
 	Harga      int    `json:"harga" validate:"required,min=0"`
 	Keterangan string `json:"keterangan"`
 	GambarBuku string `json:"gambar_buku"`
+	Penulis    string `json:"penulis"`
+	Penerbit   string `json:"penerbit"`
+	Bahasa     string `json:"bahasa"`
+	Subjek     string `json:"subjek"`
+	ISBN10     string `json:"isbn_10"`
+	ISBN13     string `json:"isbn_13"`
+}
+
+// BookListRequest holds the query parameters of GET /api/books. Cursor, when
+// set, replaces Page with keyset pagination.
+type BookListRequest struct {
+	PaginationRequest
+	Sort      string `json:"sort" validate:"omitempty,oneof=harga terjual created_at nama_barang"`
+	Order     string `json:"order" validate:"omitempty,oneof=asc desc"`
+	MinHarga  *int   `json:"min_harga" validate:"omitempty,min=0"`
+	MaxHarga  *int   `json:"max_harga" validate:"omitempty,min=0"`
+	InStock   bool   `json:"in_stock"`
+	Category  string `json:"category"`
+	Author    string `json:"author"`
+	Role      string `json:"role" validate:"omitempty,oneof=author editor translator illustrator"`
+	Publisher string `json:"publisher"`
+	Cursor    string `json:"cursor"`
+}
+
+// BookVariantRequest sets a format a book is sold in. Stok is left out (or
+// null) for digital formats that are not limited by stock; print editions
+// need one.
+type BookVariantRequest struct {
+	Format string `json:"format" validate:"required"`
+	Harga  int    `json:"harga" validate:"required,min=0"`
+	Stok   *int   `json:"stok"`
 }
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// Book File Responses
+type DownloadLinkResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
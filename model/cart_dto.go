@@ -1,9 +1,13 @@
 package model
 
 // Cart Requests
+// AddToCartRequest picks the format by variant_id or by format name; both
+// may be left out for a book sold in a single format
 type AddToCartRequest struct {
-	BookID int `json:"book_id" validate:"required"`
-	Jumlah int `json:"jumlah" validate:"required,min=1"`
+	BookID    int    `json:"book_id" validate:"required"`
+	VariantID int    `json:"variant_id"`
+	Format    string `json:"format"`
+	Jumlah    int    `json:"jumlah" validate:"required,min=1"`
 }
 
 type UpdateCartRequest struct {
@@ -0,0 +1,13 @@
+package model
+
+// Category Requests
+type CategoryRequest struct {
+	ParentID   *int   `json:"parent_id"`
+	Nama       string `json:"nama" validate:"required"`
+	Slug       string `json:"slug"`
+	Keterangan string `json:"keterangan"`
+}
+
+type SetBookCategoriesRequest struct {
+	CategoryIDs []int `json:"category_ids"`
+}
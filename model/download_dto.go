@@ -0,0 +1,7 @@
+package model
+
+// Download Requests
+type ResetDownloadsRequest struct {
+	UserID      int `json:"user_id" validate:"required"`
+	OrderItemID int `json:"order_item_id"`
+}
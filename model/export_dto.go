@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+// Export Requests
+type ExportRequest struct {
+	BookListRequest
+	Format string `json:"format" validate:"omitempty,oneof=csv jsonl onix"`
+}
+
+// BookExportRow is one line of a JSON Lines catalog export
+type BookExportRow struct {
+	ID           int                      `json:"id"`
+	ISBN10       string                   `json:"isbn_10"`
+	ISBN13       string                   `json:"isbn_13"`
+	NamaBarang   string                   `json:"nama_barang"`
+	Penulis      string                   `json:"penulis"`
+	Penerbit     string                   `json:"penerbit"`
+	Bahasa       string                   `json:"bahasa"`
+	Subjek       string                   `json:"subjek"`
+	Keterangan   string                   `json:"keterangan"`
+	Harga        int                      `json:"harga"`
+	Stok         int                      `json:"stok"`
+	Terjual      int                      `json:"terjual"`
+	Categories   []string                 `json:"categories"`
+	Contributors []entity.BookContributor `json:"contributors"`
+	ImageURL     string                   `json:"image_url"`
+	CreatedAt    time.Time                `json:"created_at"`
+	UpdatedAt    time.Time                `json:"updated_at"`
+}
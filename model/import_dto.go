@@ -0,0 +1,9 @@
+package model
+
+// Import Requests
+type ImportRequest struct {
+	Format    string `json:"format" validate:"omitempty,oneof=csv onix"`
+	Mode      string `json:"mode" validate:"omitempty,oneof=transaction chunked"`
+	ChunkSize int    `json:"chunk_size" validate:"omitempty,min=1"`
+	DryRun    bool   `json:"dry_run"`
+}
@@ -0,0 +1,166 @@
+package model
+
+import "encoding/xml"
+
+// ONIX for Books 3.0 message, reference tag names. Only the composites the
+// catalog uses are mapped.
+type ONIXMessage struct {
+	XMLName  xml.Name      `xml:"ONIXMessage"`
+	Products []ONIXProduct `xml:"Product"`
+}
+
+// ONIXNamespace is the namespace of ONIX 3.0 reference tag messages
+const ONIXNamespace = "http://ns.editeur.org/onix/3.0/reference"
+
+type ONIXHeader struct {
+	Sender       ONIXSender `xml:"Sender"`
+	SentDateTime string     `xml:"SentDateTime"`
+}
+
+type ONIXSender struct {
+	SenderName string `xml:"SenderName"`
+}
+
+type ONIXProduct struct {
+	RecordReference    string                  `xml:"RecordReference"`
+	NotificationType   string                  `xml:"NotificationType"`
+	ProductIdentifiers []ONIXProductIdentifier `xml:"ProductIdentifier"`
+	DescriptiveDetail  ONIXDescriptiveDetail   `xml:"DescriptiveDetail"`
+	CollateralDetail   ONIXCollateralDetail    `xml:"CollateralDetail"`
+	PublishingDetail   ONIXPublishingDetail    `xml:"PublishingDetail"`
+	ProductSupply      []ONIXProductSupply     `xml:"ProductSupply"`
+}
+
+// ONIXProductIdentifier: ProductIDType 01 is proprietary (named by
+// IDTypeName), 02 is ISBN-10, 15 is ISBN-13 and 03 is GTIN-13
+type ONIXProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDTypeName    string `xml:"IDTypeName,omitempty"`
+	IDValue       string `xml:"IDValue"`
+}
+
+// ONIXDescriptiveDetail: ProductForm ED with ProductFormDetail E101 is an
+// EPUB download
+type ONIXDescriptiveDetail struct {
+	ProductComposition string            `xml:"ProductComposition,omitempty"`
+	ProductForm        string            `xml:"ProductForm,omitempty"`
+	ProductFormDetail  string            `xml:"ProductFormDetail,omitempty"`
+	TitleDetails       []ONIXTitleDetail `xml:"TitleDetail"`
+	Contributors       []ONIXContributor `xml:"Contributor"`
+	Languages          []ONIXLanguage    `xml:"Language"`
+	Subjects           []ONIXSubject     `xml:"Subject"`
+}
+
+// ONIXTitleDetail: TitleType 01 is the distinctive title
+type ONIXTitleDetail struct {
+	TitleType     string             `xml:"TitleType"`
+	TitleElements []ONIXTitleElement `xml:"TitleElement"`
+}
+
+type ONIXTitleElement struct {
+	TitleElementLevel  string `xml:"TitleElementLevel"`
+	TitleText          string `xml:"TitleText,omitempty"`
+	TitlePrefix        string `xml:"TitlePrefix,omitempty"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix,omitempty"`
+	Subtitle           string `xml:"Subtitle,omitempty"`
+}
+
+// ONIXContributor: ContributorRole A01 author, B01 editor, B06 translator,
+// A12 illustrator
+type ONIXContributor struct {
+	SequenceNumber  string   `xml:"SequenceNumber,omitempty"`
+	ContributorRole []string `xml:"ContributorRole"`
+	PersonName      string   `xml:"PersonName,omitempty"`
+	NamesBeforeKey  string   `xml:"NamesBeforeKey,omitempty"`
+	KeyNames        string   `xml:"KeyNames,omitempty"`
+	CorporateName   string   `xml:"CorporateName,omitempty"`
+}
+
+// ONIXLanguage: LanguageRole 01 is the language of the text, LanguageCode
+// an ISO 639-2/B code
+type ONIXLanguage struct {
+	LanguageRole string `xml:"LanguageRole"`
+	LanguageCode string `xml:"LanguageCode"`
+}
+
+// ONIXSubject: SubjectSchemeIdentifier 20 is keywords, 24 a proprietary
+// scheme named by SubjectSchemeName
+type ONIXSubject struct {
+	SubjectSchemeIdentifier string `xml:"SubjectSchemeIdentifier"`
+	SubjectSchemeName       string `xml:"SubjectSchemeName,omitempty"`
+	SubjectCode             string `xml:"SubjectCode,omitempty"`
+	SubjectHeadingText      string `xml:"SubjectHeadingText,omitempty"`
+}
+
+type ONIXCollateralDetail struct {
+	TextContents        []ONIXTextContent        `xml:"TextContent"`
+	SupportingResources []ONIXSupportingResource `xml:"SupportingResource"`
+}
+
+// ONIXTextContent: TextType 02 is a short description, 03 the description
+type ONIXTextContent struct {
+	TextType        string     `xml:"TextType"`
+	ContentAudience string     `xml:"ContentAudience"`
+	Texts           []ONIXText `xml:"Text"`
+}
+
+type ONIXText struct {
+	TextFormat string `xml:"textformat,attr,omitempty"`
+	Value      string `xml:",chardata"`
+}
+
+// ONIXSupportingResource: ResourceContentType 01 is the front cover,
+// ResourceMode 03 an image
+type ONIXSupportingResource struct {
+	ResourceContentType string                `xml:"ResourceContentType"`
+	ContentAudience     string                `xml:"ContentAudience"`
+	ResourceMode        string                `xml:"ResourceMode"`
+	ResourceVersions    []ONIXResourceVersion `xml:"ResourceVersion"`
+}
+
+// ONIXResourceVersion: ResourceForm 02 is a downloadable file
+type ONIXResourceVersion struct {
+	ResourceForm  string   `xml:"ResourceForm"`
+	ResourceLinks []string `xml:"ResourceLink"`
+}
+
+// ONIXPublishingDetail: PublishingStatus 04 is active
+type ONIXPublishingDetail struct {
+	Publishers       []ONIXPublisher `xml:"Publisher"`
+	PublishingStatus string          `xml:"PublishingStatus,omitempty"`
+}
+
+// ONIXPublisher: PublishingRole 01 is the publisher
+type ONIXPublisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+type ONIXProductSupply struct {
+	SupplyDetails []ONIXSupplyDetail `xml:"SupplyDetail"`
+}
+
+// ONIXSupplyDetail: ProductAvailability 21 is in stock, 31 out of stock
+type ONIXSupplyDetail struct {
+	Supplier            *ONIXSupplier `xml:"Supplier"`
+	ProductAvailability string        `xml:"ProductAvailability"`
+	Stock               []ONIXStock   `xml:"Stock"`
+	Prices              []ONIXPrice   `xml:"Price"`
+}
+
+// ONIXSupplier: SupplierRole 08 is a retailer
+type ONIXSupplier struct {
+	SupplierRole string `xml:"SupplierRole"`
+	SupplierName string `xml:"SupplierName"`
+}
+
+type ONIXStock struct {
+	OnHand string `xml:"OnHand"`
+}
+
+// ONIXPrice: PriceType 02 is the retail price including tax
+type ONIXPrice struct {
+	PriceType    string `xml:"PriceType"`
+	PriceAmount  string `xml:"PriceAmount"`
+	CurrencyCode string `xml:"CurrencyCode"`
+}
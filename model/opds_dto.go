@@ -0,0 +1,112 @@
+package model
+
+import "encoding/xml"
+
+// OPDS 1.2 (Atom) feed
+type OPDSFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Xmlns     string      `xml:"xmlns,attr"`
+	XmlnsDC   string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS string      `xml:"xmlns:opds,attr"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Links     []OPDSLink  `xml:"link"`
+	Entries   []OPDSEntry `xml:"entry"`
+}
+
+type OPDSEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Authors   []OPDSAuthor `xml:"author,omitempty"`
+	Language  string       `xml:"dc:language,omitempty"`
+	Publisher string       `xml:"dc:publisher,omitempty"`
+	Summary   *OPDSText    `xml:"summary,omitempty"`
+	Content   *OPDSText    `xml:"content,omitempty"`
+	Links     []OPDSLink   `xml:"link"`
+}
+
+type OPDSAuthor struct {
+	Name string `xml:"name"`
+}
+
+type OPDSText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type OPDSLink struct {
+	Rel   string     `xml:"rel,attr,omitempty"`
+	Href  string     `xml:"href,attr"`
+	Type  string     `xml:"type,attr,omitempty"`
+	Title string     `xml:"title,attr,omitempty"`
+	Price *OPDSPrice `xml:"opds:price,omitempty"`
+}
+
+type OPDSPrice struct {
+	CurrencyCode string `xml:"currencycode,attr"`
+	Value        int    `xml:",chardata"`
+}
+
+// OpenSearch description document used by OPDS 1.2 search links
+type OpenSearchDescription struct {
+	XMLName     xml.Name      `xml:"OpenSearchDescription"`
+	Xmlns       string        `xml:"xmlns,attr"`
+	ShortName   string        `xml:"ShortName"`
+	Description string        `xml:"Description"`
+	URL         OpenSearchURL `xml:"Url"`
+}
+
+type OpenSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// OPDS 2.0 (JSON) feed
+type OPDS2Feed struct {
+	Metadata     OPDS2FeedMetadata  `json:"metadata"`
+	Links        []OPDS2Link        `json:"links"`
+	Navigation   []OPDS2Link        `json:"navigation,omitempty"`
+	Publications []OPDS2Publication `json:"publications,omitempty"`
+}
+
+type OPDS2FeedMetadata struct {
+	Title         string `json:"title"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+}
+
+type OPDS2Link struct {
+	Rel        string           `json:"rel,omitempty"`
+	Href       string           `json:"href"`
+	Type       string           `json:"type,omitempty"`
+	Title      string           `json:"title,omitempty"`
+	Templated  bool             `json:"templated,omitempty"`
+	Properties *OPDS2Properties `json:"properties,omitempty"`
+}
+
+type OPDS2Properties struct {
+	Price *OPDS2Price `json:"price,omitempty"`
+}
+
+type OPDS2Price struct {
+	Currency string `json:"currency"`
+	Value    int    `json:"value"`
+}
+
+type OPDS2Publication struct {
+	Metadata OPDS2PublicationMetadata `json:"metadata"`
+	Links    []OPDS2Link              `json:"links"`
+	Images   []OPDS2Link              `json:"images,omitempty"`
+}
+
+type OPDS2PublicationMetadata struct {
+	Type        string   `json:"@type"`
+	Identifier  string   `json:"identifier"`
+	Title       string   `json:"title"`
+	Author      []string `json:"author,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Modified    string   `json:"modified"`
+}
@@ -4,3 +4,7 @@ package model
 type CheckoutRequest struct {
 	// Add shipping address, payment method, etc. if needed
 }
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending paid completed cancelled"`
+}
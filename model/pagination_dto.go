@@ -11,5 +11,6 @@ type PaginationResponse struct {
 	Limit      int         `json:"limit"`
 	Total      int         `json:"total"`
 	TotalPages int         `json:"total_pages"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 	Data       interface{} `json:"data"`
 }
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Reading Progress Requests
+type UpdateReadingProgressRequest struct {
+	BookID       int        `json:"book_id" validate:"required"`
+	Position     string     `json:"position" validate:"required"`
+	PositionType string     `json:"position_type" validate:"required,oneof=cfi page"`
+	Percent      float64    `json:"percent" validate:"min=0,max=100"`
+	Device       string     `json:"device"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// Review Requests
+type CreateReviewRequest struct {
+	BookID int    `json:"book_id" validate:"required"`
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title" validate:"max=200"`
+	Body   string `json:"body"`
+}
+
+type UpdateReviewRequest struct {
+	Rating *int    `json:"rating" validate:"omitempty,min=1,max=5"`
+	Title  *string `json:"title" validate:"omitempty,max=200"`
+	Body   *string `json:"body"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved hidden"`
+}
+
+// ReviewListRequest selects a page of reviews. Status is only honoured in
+// the admin moderation list; the public list always shows approved reviews.
+type ReviewListRequest struct {
+	BookID int    `json:"book_id"`
+	Status string `json:"status"`
+	Sort   string `json:"sort"`
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+}
@@ -0,0 +1,27 @@
+package model
+
+// Series Requests
+type SeriesRequest struct {
+	Nama       string `json:"nama" validate:"required"`
+	Slug       string `json:"slug"`
+	Keterangan string `json:"keterangan"`
+}
+
+type SetBookSeriesRequest struct {
+	SeriesID *int `json:"series_id"`
+	Position int  `json:"position"`
+}
+
+// SeriesCartResponse reports which volumes "buy the whole series" put in
+// the cart and which it skipped
+type SeriesCartResponse struct {
+	Added   []SeriesCartVolume `json:"added"`
+	Skipped []SeriesCartVolume `json:"skipped"`
+}
+
+type SeriesCartVolume struct {
+	BookID     int    `json:"book_id"`
+	NamaBarang string `json:"nama_barang"`
+	Position   int    `json:"position"`
+	Reason     string `json:"reason,omitempty"`
+}
@@ -0,0 +1,6 @@
+package model
+
+// Wishlist Requests
+type AddToWishlistRequest struct {
+	BookID int `json:"book_id" validate:"required"`
+}
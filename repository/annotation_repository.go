@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+const annotationColumns = `
+	a.id, a.user_id, a.book_id, b.nama_barang, a.type, a.cfi,
+	COALESCE(a.color, ''), COALESCE(a.text, ''), COALESCE(a.note, ''),
+	a.created_at, a.updated_at, a.deleted_at, a.change_seq
+`
+
+type AnnotationRepository interface {
+	Create(annotation *entity.Annotation) error
+	FindByID(id int) (*entity.Annotation, error)
+	FindByUserID(userID, bookID int, since *int64) ([]entity.Annotation, error)
+	Update(annotation *entity.Annotation) error
+	Delete(id int) error
+}
+
+type annotationRepository struct {
+	db *sql.DB
+}
+
+func NewAnnotationRepository(db *sql.DB) AnnotationRepository {
+	return &annotationRepository{db: db}
+}
+
+func (r *annotationRepository) Create(annotation *entity.Annotation) error {
+	query := `
+		INSERT INTO annotations (user_id, book_id, type, cfi, color, text, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at, change_seq
+	`
+	return r.db.QueryRow(query, annotation.UserID, annotation.BookID, annotation.Type,
+		annotation.CFI, annotation.Color, annotation.Text, annotation.Note).
+		Scan(&annotation.ID, &annotation.CreatedAt, &annotation.UpdatedAt, &annotation.ChangeSeq)
+}
+
+func (r *annotationRepository) FindByID(id int) (*entity.Annotation, error) {
+	query := `SELECT` + annotationColumns + `
+		FROM annotations a
+		JOIN books b ON a.book_id = b.id
+		WHERE a.id = $1 AND a.deleted_at IS NULL
+	`
+	var annotation entity.Annotation
+	if err := scanAnnotation(r.db.QueryRow(query, id), &annotation); err != nil {
+		return nil, err
+	}
+	return &annotation, nil
+}
+
+// FindByUserID lists the user's annotations, optionally for a single book.
+// With since set, only changes with a later change_seq are returned and
+// deleted annotations are included as tombstones.
+func (r *annotationRepository) FindByUserID(userID, bookID int, since *int64) ([]entity.Annotation, error) {
+	query := `SELECT` + annotationColumns + `
+		FROM annotations a
+		JOIN books b ON a.book_id = b.id
+		WHERE a.user_id = $1
+	`
+	args := []interface{}{userID}
+
+	if bookID > 0 {
+		args = append(args, bookID)
+		query += fmt.Sprintf(" AND a.book_id = $%d", len(args))
+	}
+	if since != nil {
+		args = append(args, *since)
+		query += fmt.Sprintf(" AND a.change_seq > $%d", len(args))
+	} else {
+		query += " AND a.deleted_at IS NULL"
+	}
+	query += " ORDER BY a.change_seq ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var annotations []entity.Annotation
+	for rows.Next() {
+		var annotation entity.Annotation
+		if err := scanAnnotation(rows, &annotation); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	return annotations, rows.Err()
+}
+
+func (r *annotationRepository) Update(annotation *entity.Annotation) error {
+	query := `
+		UPDATE annotations
+		SET cfi = $1, color = $2, text = $3, note = $4, updated_at = CURRENT_TIMESTAMP,
+			change_seq = nextval(pg_get_serial_sequence('annotations', 'change_seq'))
+		WHERE id = $5 AND deleted_at IS NULL
+		RETURNING updated_at, change_seq
+	`
+	return r.db.QueryRow(query, annotation.CFI, annotation.Color, annotation.Text,
+		annotation.Note, annotation.ID).Scan(&annotation.UpdatedAt, &annotation.ChangeSeq)
+}
+
+// Delete marks the annotation as deleted and bumps change_seq so the
+// tombstone is picked up by the next incremental sync
+func (r *annotationRepository) Delete(id int) error {
+	query := `
+		UPDATE annotations
+		SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP,
+			change_seq = nextval(pg_get_serial_sequence('annotations', 'change_seq'))
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func scanAnnotation(row rowScanner, annotation *entity.Annotation) error {
+	return row.Scan(
+		&annotation.ID, &annotation.UserID, &annotation.BookID, &annotation.NamaBarang,
+		&annotation.Type, &annotation.CFI, &annotation.Color, &annotation.Text,
+		&annotation.Note, &annotation.CreatedAt, &annotation.UpdatedAt, &annotation.DeletedAt,
+		&annotation.ChangeSeq,
+	)
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/lib/pq"
+)
+
+// refreshPenulis rebuilds the denormalised books.penulis text from the
+// author credits, so search and the OPDS feeds keep working off one column
+const refreshPenulis = `
+	UPDATE books b
+	SET penulis = COALESCE((
+		SELECT string_agg(a.nama, ', ' ORDER BY bc.position)
+		FROM book_contributors bc
+		JOIN authors a ON a.id = bc.author_id
+		WHERE bc.book_id = b.id AND bc.role = 'author'
+	), '')
+	WHERE b.id = ANY($1)
+`
+
+type AuthorRepository interface {
+	Create(author *entity.Author) error
+	FindAll(keyword string) ([]entity.Author, error)
+	FindByID(id int) (*entity.Author, error)
+	FindBySlug(slug string) (*entity.Author, error)
+	FindBooks(authorID int) ([]entity.AuthorBook, error)
+	FindByBookID(bookID int) ([]entity.BookContributor, error)
+	Update(author *entity.Author) error
+	Delete(id int) error
+	SetBookContributors(bookID int, contributors []entity.BookContributor) error
+	SyncBookAuthors(bookID int, authors []entity.Author) error
+}
+
+type authorRepository struct {
+	db *sql.DB
+}
+
+func NewAuthorRepository(db *sql.DB) AuthorRepository {
+	return &authorRepository{db: db}
+}
+
+func (r *authorRepository) Create(author *entity.Author) error {
+	query := `
+		INSERT INTO authors (nama, slug, biografi)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(query, author.Nama, author.Slug, author.Biografi).
+		Scan(&author.ID, &author.CreatedAt, &author.UpdatedAt)
+}
+
+// FindAll lists authors with their number of credited books, optionally
+// filtered by a name keyword
+func (r *authorRepository) FindAll(keyword string) ([]entity.Author, error) {
+	query := `
+		SELECT a.id, a.nama, a.slug, COALESCE(a.biografi, ''), a.created_at, a.updated_at,
+			COUNT(DISTINCT bc.book_id)
+		FROM authors a
+		LEFT JOIN book_contributors bc ON bc.author_id = a.id
+			AND bc.book_id IN (SELECT id FROM books WHERE deleted_at IS NULL)
+		WHERE $1 = '' OR a.nama ILIKE '%' || $1 || '%'
+		GROUP BY a.id
+		ORDER BY a.nama
+	`
+	rows, err := r.db.Query(query, keyword)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []entity.Author
+	for rows.Next() {
+		var author entity.Author
+		err := rows.Scan(
+			&author.ID, &author.Nama, &author.Slug, &author.Biografi,
+			&author.CreatedAt, &author.UpdatedAt, &author.BookCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, rows.Err()
+}
+
+func (r *authorRepository) FindByID(id int) (*entity.Author, error) {
+	return r.findOne(`a.id = $1`, id)
+}
+
+func (r *authorRepository) FindBySlug(slug string) (*entity.Author, error) {
+	return r.findOne(`a.slug = $1`, slug)
+}
+
+func (r *authorRepository) findOne(condition string, arg interface{}) (*entity.Author, error) {
+	query := `
+		SELECT a.id, a.nama, a.slug, COALESCE(a.biografi, ''), a.created_at, a.updated_at,
+			(SELECT COUNT(DISTINCT bc.book_id) FROM book_contributors bc
+				JOIN books b ON b.id = bc.book_id
+				WHERE bc.author_id = a.id AND b.deleted_at IS NULL)
+		FROM authors a
+		WHERE ` + condition
+	var author entity.Author
+	err := r.db.QueryRow(query, arg).Scan(
+		&author.ID, &author.Nama, &author.Slug, &author.Biografi,
+		&author.CreatedAt, &author.UpdatedAt, &author.BookCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("author not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &author, nil
+}
+
+// FindBooks lists the books credited to an author, one row per role
+func (r *authorRepository) FindBooks(authorID int) ([]entity.AuthorBook, error) {
+	query := `SELECT ` + bookColumns + `, bc.role
+		FROM book_contributors bc
+		JOIN books ON books.id = bc.book_id
+		WHERE bc.author_id = $1 AND books.deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.Query(query, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []entity.AuthorBook
+	for rows.Next() {
+		var book entity.AuthorBook
+		err := rows.Scan(
+			&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
+			&book.Harga, &book.Keterangan, &book.GambarBuku,
+			&book.Penulis, &book.Penerbit, &book.Bahasa, &book.Subjek,
+			&book.ISBN10, &book.ISBN13, &book.RatingAverage, &book.RatingCount,
+			&book.CreatedAt, &book.UpdatedAt, &book.DeletedAt, &book.InStock, &book.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, rows.Err()
+}
+
+func (r *authorRepository) FindByBookID(bookID int) ([]entity.BookContributor, error) {
+	query := `
+		SELECT a.id, a.nama, a.slug, bc.role
+		FROM book_contributors bc
+		JOIN authors a ON a.id = bc.author_id
+		WHERE bc.book_id = $1
+		ORDER BY bc.position, bc.role
+	`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contributors []entity.BookContributor
+	for rows.Next() {
+		var contributor entity.BookContributor
+		if err := rows.Scan(&contributor.AuthorID, &contributor.Nama, &contributor.Slug, &contributor.Role); err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, contributor)
+	}
+
+	return contributors, rows.Err()
+}
+
+func (r *authorRepository) Update(author *entity.Author) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE authors
+		SET nama = $1, slug = $2, biografi = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+	err = tx.QueryRow(query, author.Nama, author.Slug, author.Biografi, author.ID).
+		Scan(&author.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	bookIDs, err := creditedBookIDs(tx, author.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(refreshPenulis, pq.Array(bookIDs)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *authorRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bookIDs, err := creditedBookIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM authors WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("author not found")
+	}
+
+	if _, err := tx.Exec(refreshPenulis, pq.Array(bookIDs)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetBookContributors replaces every credit of a book
+func (r *authorRepository) SetBookContributors(bookID int, contributors []entity.BookContributor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM book_contributors WHERE book_id = $1`, bookID); err != nil {
+		return err
+	}
+
+	for i, contributor := range contributors {
+		if err := insertContributor(tx, bookID, contributor.AuthorID, contributor.Role, i); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(refreshPenulis, pq.Array([]int{bookID})); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SyncBookAuthors replaces the author credits of a book with the given
+// authors, creating any that do not exist yet (matched by slug). Editor,
+// translator and illustrator credits are kept.
+func (r *authorRepository) SyncBookAuthors(bookID int, authors []entity.Author) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM book_contributors WHERE book_id = $1 AND role = $2`, bookID, entity.RoleAuthor)
+	if err != nil {
+		return err
+	}
+
+	for i, author := range authors {
+		var authorID int
+		err := tx.QueryRow(`
+			INSERT INTO authors (nama, slug)
+			VALUES ($1, $2)
+			ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+			RETURNING id
+		`, author.Nama, author.Slug).Scan(&authorID)
+		if err != nil {
+			return err
+		}
+
+		if err := insertContributor(tx, bookID, authorID, entity.RoleAuthor, i); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(refreshPenulis, pq.Array([]int{bookID})); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertContributor(tx *sql.Tx, bookID, authorID int, role string, position int) error {
+	_, err := tx.Exec(`
+		INSERT INTO book_contributors (book_id, author_id, role, position)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, bookID, authorID, role, position)
+	return err
+}
+
+func creditedBookIDs(tx *sql.Tx, authorID int) ([]int, error) {
+	rows, err := tx.Query(`SELECT DISTINCT book_id FROM book_contributors WHERE author_id = $1`, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type BookFileRepository interface {
+	Create(file *entity.BookFile) error
+	FindByID(id int) (*entity.BookFile, error)
+	FindByBookID(bookID int) ([]entity.BookFile, error)
+	Delete(id int) error
+}
+
+type bookFileRepository struct {
+	db *sql.DB
+}
+
+func NewBookFileRepository(db *sql.DB) BookFileRepository {
+	return &bookFileRepository{db: db}
+}
+
+func (r *bookFileRepository) Create(file *entity.BookFile) error {
+	query := `
+		INSERT INTO book_files (book_id, format, file_name, original_name, content_type, size)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	return r.db.QueryRow(query, file.BookID, file.Format, file.FileName,
+		file.OriginalName, file.ContentType, file.Size).
+		Scan(&file.ID, &file.CreatedAt)
+}
+
+func (r *bookFileRepository) FindByID(id int) (*entity.BookFile, error) {
+	query := `
+		SELECT id, book_id, format, file_name, original_name, content_type, size, created_at
+		FROM book_files
+		WHERE id = $1
+	`
+	file := &entity.BookFile{}
+	err := r.db.QueryRow(query, id).Scan(
+		&file.ID, &file.BookID, &file.Format, &file.FileName,
+		&file.OriginalName, &file.ContentType, &file.Size, &file.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("book file not found")
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (r *bookFileRepository) FindByBookID(bookID int) ([]entity.BookFile, error) {
+	query := `
+		SELECT id, book_id, format, file_name, original_name, content_type, size, created_at
+		FROM book_files
+		WHERE book_id = $1
+		ORDER BY id
+	`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []entity.BookFile
+	for rows.Next() {
+		var file entity.BookFile
+		err := rows.Scan(
+			&file.ID, &file.BookID, &file.Format, &file.FileName,
+			&file.OriginalName, &file.ContentType, &file.Size, &file.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (r *bookFileRepository) Delete(id int) error {
+	query := `DELETE FROM book_files WHERE id = $1`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("book file not found")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import "fmt"
+
+// Sort keys accepted by BookFilter.Sort
+const (
+	BookSortHarga     = "harga"
+	BookSortTerjual   = "terjual"
+	BookSortCreatedAt = "created_at"
+	BookSortNama      = "nama_barang"
+)
+
+// bookSortColumns maps sort keys to their column and the SQL type a keyset
+// cursor value is cast to
+var bookSortColumns = map[string]struct {
+	column   string
+	castType string
+}{
+	BookSortHarga:     {"harga", "integer"},
+	BookSortTerjual:   {"terjual", "integer"},
+	BookSortCreatedAt: {"created_at", "timestamp"},
+	BookSortNama:      {"nama_barang", "text"},
+}
+
+// IsBookSort reports whether sort is a supported sort key
+func IsBookSort(sort string) bool {
+	_, ok := bookSortColumns[sort]
+	return ok
+}
+
+// BookFilter selects, orders and pages the book list
+type BookFilter struct {
+	MinHarga  *int
+	MaxHarga  *int
+	InStock   bool
+	Category  string // category slug
+	Author    string // author slug
+	Role      string // contributor role of Author, any role when empty
+	Publisher string // publisher slug
+	Deleted   bool   // soft deleted books instead of the live catalog
+
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	After  *BookCursor
+}
+
+// BookCursor is the position of the last row of a page: the value of the
+// sort column (as text) and the book ID as tie-breaker
+type BookCursor struct {
+	Value string
+	ID    int
+}
+
+func (f BookFilter) where() ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	if f.Deleted {
+		where = append(where, "deleted_at IS NOT NULL")
+	} else {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if f.MinHarga != nil {
+		args = append(args, *f.MinHarga)
+		where = append(where, fmt.Sprintf("harga >= $%d", len(args)))
+	}
+	if f.MaxHarga != nil {
+		args = append(args, *f.MaxHarga)
+		where = append(where, fmt.Sprintf("harga <= $%d", len(args)))
+	}
+	if f.InStock {
+		where = append(where, inStock)
+	}
+	if f.Category != "" {
+		// Books filed under the category or any of its subcategories
+		args = append(args, f.Category)
+		where = append(where, fmt.Sprintf(`id IN (
+			WITH RECURSIVE sub AS (
+				SELECT id FROM categories WHERE slug = $%d
+				UNION ALL
+				SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+			)
+			SELECT bc.book_id FROM book_categories bc JOIN sub ON bc.category_id = sub.id
+		)`, len(args)))
+	}
+
+	if f.Author != "" {
+		// Books the author is credited on, optionally in one role only
+		args = append(args, f.Author, f.Role)
+		where = append(where, fmt.Sprintf(`id IN (
+			SELECT bc.book_id FROM book_contributors bc JOIN authors a ON a.id = bc.author_id
+			WHERE a.slug = $%d AND ($%d = '' OR bc.role = $%d)
+		)`, len(args)-1, len(args), len(args)))
+	}
+	if f.Publisher != "" {
+		args = append(args, f.Publisher)
+		where = append(where, fmt.Sprintf(
+			`publisher_id = (SELECT id FROM publishers WHERE slug = $%d)`, len(args)))
+	}
+
+	return where, args
+}
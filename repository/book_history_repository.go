@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type BookHistoryRepository interface {
+	Create(version *entity.BookVersion) error
+	FindByBookID(bookID int) ([]entity.BookVersion, error)
+	FindVersion(bookID, version int) (*entity.BookVersion, error)
+}
+
+type bookHistoryRepository struct {
+	db *sql.DB
+}
+
+func NewBookHistoryRepository(db *sql.DB) BookHistoryRepository {
+	return &bookHistoryRepository{db: db}
+}
+
+const bookVersionColumns = `
+	v.id, v.book_id, v.version, v.action, COALESCE(v.user_id, 0), COALESCE(u.username, ''),
+	COALESCE(v.reverted_to, 0), v.snapshot, COALESCE(v.changes, '[]'), v.created_at`
+
+func scanBookVersion(row rowScanner, version *entity.BookVersion) error {
+	var snapshot, changes []byte
+	err := row.Scan(
+		&version.ID, &version.BookID, &version.Version, &version.Action,
+		&version.UserID, &version.Username, &version.RevertedTo,
+		&snapshot, &changes, &version.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(snapshot, &version.Snapshot); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(changes, &version.Changes); err != nil {
+		return err
+	}
+	if version.Changes == nil {
+		version.Changes = []entity.BookFieldChange{}
+	}
+	return nil
+}
+
+// Create appends the version to the book's history, numbering it after the
+// latest one
+func (r *bookHistoryRepository) Create(version *entity.BookVersion) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertBookVersion(tx, version, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindByBookID lists the history of a book, newest version first
+func (r *bookHistoryRepository) FindByBookID(bookID int) ([]entity.BookVersion, error) {
+	query := `SELECT ` + bookVersionColumns + `
+		FROM book_versions v
+		LEFT JOIN users u ON u.id = v.user_id
+		WHERE v.book_id = $1
+		ORDER BY v.version DESC
+	`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []entity.BookVersion
+	for rows.Next() {
+		var version entity.BookVersion
+		if err := scanBookVersion(rows, &version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// FindVersion returns one version of a book, or nil if it has no such version
+func (r *bookHistoryRepository) FindVersion(bookID, version int) (*entity.BookVersion, error) {
+	query := `SELECT ` + bookVersionColumns + `
+		FROM book_versions v
+		LEFT JOIN users u ON u.id = v.user_id
+		WHERE v.book_id = $1 AND v.version = $2
+	`
+	var found entity.BookVersion
+	err := scanBookVersion(r.db.QueryRow(query, bookID, version), &found)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &found, nil
+}
+
+// bookSnapshotColumns is the column list scanned into an entity.BookSnapshot
+const bookSnapshotColumns = `
+	nama_barang, stok, terjual, harga, COALESCE(keterangan, ''), COALESCE(gambar_buku, ''),
+	COALESCE(penulis, ''), COALESCE(penerbit, ''), COALESCE(bahasa, ''),
+	COALESCE(subjek, ''), COALESCE(isbn_10, ''), COALESCE(isbn_13, ''), ` + inStock
+
+// lockBookSnapshot reads the editable fields of a book, locking its row
+// until the transaction ends
+func lockBookSnapshot(tx *sql.Tx, bookID int) (entity.BookSnapshot, error) {
+	var snapshot entity.BookSnapshot
+	err := tx.QueryRow(`SELECT `+bookSnapshotColumns+` FROM books WHERE id = $1 FOR UPDATE`, bookID).Scan(
+		&snapshot.NamaBarang, &snapshot.Stok, &snapshot.Terjual, &snapshot.Harga,
+		&snapshot.Keterangan, &snapshot.GambarBuku, &snapshot.Penulis, &snapshot.Penerbit,
+		&snapshot.Bahasa, &snapshot.Subjek, &snapshot.ISBN10, &snapshot.ISBN13, &snapshot.InStock,
+	)
+	if err == sql.ErrNoRows {
+		return snapshot, fmt.Errorf("book not found")
+	}
+	return snapshot, err
+}
+
+// recordBookChange compares the book as saved in the transaction with
+// before and appends version with the changed fields. Nothing is recorded
+// when no field changed, unless the version is a rollback.
+func recordBookChange(tx *sql.Tx, version *entity.BookVersion, before entity.BookSnapshot) error {
+	after, err := lockBookSnapshot(tx, version.BookID)
+	if err != nil {
+		return err
+	}
+
+	version.Snapshot = after
+	version.Changes = diffBookSnapshots(before, after)
+	if len(version.Changes) == 0 && version.RevertedTo == 0 {
+		return nil
+	}
+	return insertBookVersion(tx, version, &before)
+}
+
+// insertBookVersion numbers the version after the latest one of the book.
+// The book row is locked first, so concurrent writers take turns instead of
+// colliding on the version number. When initial is set and the book has no
+// history yet, because it was created before history was kept, initial is
+// recorded first so the book can be rolled back to it.
+func insertBookVersion(tx *sql.Tx, version *entity.BookVersion, initial *entity.BookSnapshot) error {
+	var id int
+	if err := tx.QueryRow(`SELECT id FROM books WHERE id = $1 FOR UPDATE`, version.BookID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("book not found")
+		}
+		return err
+	}
+
+	if initial != nil {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM book_versions WHERE book_id = $1)`,
+			version.BookID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			first := &entity.BookVersion{
+				BookID:   version.BookID,
+				Action:   entity.BookActionInitial,
+				Snapshot: *initial,
+			}
+			if err := insertBookVersion(tx, first, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	snapshot, err := json.Marshal(version.Snapshot)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(version.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO book_versions (book_id, version, action, user_id, reverted_to, snapshot, changes)
+		VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM book_versions WHERE book_id = $1),
+		        $2, NULLIF($3, 0), NULLIF($4, 0), $5, $6)
+		RETURNING id, version, created_at
+	`
+	return tx.QueryRow(query, version.BookID, version.Action, version.UserID,
+		version.RevertedTo, snapshot, changes).
+		Scan(&version.ID, &version.Version, &version.CreatedAt)
+}
+
+// diffBookSnapshots lists the fields that differ, in a fixed order
+func diffBookSnapshots(before, after entity.BookSnapshot) []entity.BookFieldChange {
+	fields := []entity.BookFieldChange{
+		{Field: "nama_barang", Before: before.NamaBarang, After: after.NamaBarang},
+		{Field: "harga", Before: before.Harga, After: after.Harga},
+		{Field: "stok", Before: before.Stok, After: after.Stok},
+		{Field: "terjual", Before: before.Terjual, After: after.Terjual},
+		{Field: "keterangan", Before: before.Keterangan, After: after.Keterangan},
+		{Field: "gambar_buku", Before: before.GambarBuku, After: after.GambarBuku},
+		{Field: "penulis", Before: before.Penulis, After: after.Penulis},
+		{Field: "penerbit", Before: before.Penerbit, After: after.Penerbit},
+		{Field: "bahasa", Before: before.Bahasa, After: after.Bahasa},
+		{Field: "subjek", Before: before.Subjek, After: after.Subjek},
+		{Field: "isbn_10", Before: before.ISBN10, After: after.ISBN10},
+		{Field: "isbn_13", Before: before.ISBN13, After: after.ISBN13},
+	}
+
+	var changes []entity.BookFieldChange
+	for _, field := range fields {
+		if field.Before != field.After {
+			changes = append(changes, field)
+		}
+	}
+	return changes
+}
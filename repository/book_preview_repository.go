@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type BookPreviewRepository interface {
+	Save(preview *entity.BookPreview) error
+	FindByID(id int) (*entity.BookPreview, error)
+	FindByBookAndFormat(bookID int, format string) (*entity.BookPreview, error)
+	FindByBookID(bookID int) ([]entity.BookPreview, error)
+	Delete(id int) error
+}
+
+type bookPreviewRepository struct {
+	db *sql.DB
+}
+
+func NewBookPreviewRepository(db *sql.DB) BookPreviewRepository {
+	return &bookPreviewRepository{db: db}
+}
+
+const bookPreviewColumns = `
+	id, book_id, format, file_name, content_type, size, source, source_file_id, length, created_at`
+
+func scanBookPreview(row rowScanner, preview *entity.BookPreview) error {
+	var sourceFileID sql.NullInt64
+	err := row.Scan(
+		&preview.ID, &preview.BookID, &preview.Format, &preview.FileName,
+		&preview.ContentType, &preview.Size, &preview.Source, &sourceFileID,
+		&preview.Length, &preview.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if sourceFileID.Valid {
+		id := int(sourceFileID.Int64)
+		preview.SourceFileID = &id
+	}
+	return nil
+}
+
+// Save stores the preview of a book in its format, replacing the previous one
+func (r *bookPreviewRepository) Save(preview *entity.BookPreview) error {
+	query := `
+		INSERT INTO book_previews (book_id, format, file_name, content_type, size, source, source_file_id, length)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (book_id, format) DO UPDATE SET
+			file_name = EXCLUDED.file_name,
+			content_type = EXCLUDED.content_type,
+			size = EXCLUDED.size,
+			source = EXCLUDED.source,
+			source_file_id = EXCLUDED.source_file_id,
+			length = EXCLUDED.length,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at
+	`
+	return r.db.QueryRow(query, preview.BookID, preview.Format, preview.FileName,
+		preview.ContentType, preview.Size, preview.Source, preview.SourceFileID, preview.Length).
+		Scan(&preview.ID, &preview.CreatedAt)
+}
+
+func (r *bookPreviewRepository) FindByID(id int) (*entity.BookPreview, error) {
+	query := `SELECT ` + bookPreviewColumns + ` FROM book_previews WHERE id = $1`
+	preview := &entity.BookPreview{}
+	err := scanBookPreview(r.db.QueryRow(query, id), preview)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book preview not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// FindByBookAndFormat returns the book's preview in the format, or nil if
+// it has none
+func (r *bookPreviewRepository) FindByBookAndFormat(bookID int, format string) (*entity.BookPreview, error) {
+	query := `SELECT ` + bookPreviewColumns + ` FROM book_previews WHERE book_id = $1 AND format = $2`
+	preview := &entity.BookPreview{}
+	err := scanBookPreview(r.db.QueryRow(query, bookID, format), preview)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+func (r *bookPreviewRepository) FindByBookID(bookID int) ([]entity.BookPreview, error) {
+	query := `SELECT ` + bookPreviewColumns + ` FROM book_previews WHERE book_id = $1 ORDER BY id`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var previews []entity.BookPreview
+	for rows.Next() {
+		var preview entity.BookPreview
+		if err := scanBookPreview(rows, &preview); err != nil {
+			return nil, err
+		}
+		previews = append(previews, preview)
+	}
+	return previews, rows.Err()
+}
+
+func (r *bookPreviewRepository) Delete(id int) error {
+	result, err := r.db.Exec(`DELETE FROM book_previews WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("book preview not found")
+	}
+	return nil
+}
@@ -2,21 +2,59 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/lib/pq"
 )
 
 type BookRepository interface {
 	Create(book *entity.Book) error
-	FindAll() ([]entity.Book, error)
+	FindAll(filter BookFilter) ([]entity.Book, error)
+	Stream(filter BookFilter, fn func(book *entity.Book) error) error
+	Count(filter BookFilter) (int, error)
+	Search(tsquery, dictionary string, limit int) ([]entity.BookSearchResult, error)
 	FindByID(id int) (*entity.Book, error)
-	Update(id int, book *entity.Book) error
+	FindByISBN(isbn10, isbn13 string) (*entity.Book, error)
+	Update(id int, book *entity.Book, version *entity.BookVersion) error
 	Delete(id int) error
-	UpdateStock(id int, quantity int) error
+	Restore(id int) error
 	IncrementSold(id int, quantity int) error
 }
 
+// bookColumns is the column list scanned by scanBook
+const bookColumns = `
+	id, nama_barang, stok, terjual, harga, COALESCE(keterangan, '') as keterangan,
+	COALESCE(gambar_buku, '') as gambar_buku, COALESCE(penulis, '') as penulis,
+	COALESCE(penerbit, '') as penerbit, COALESCE(bahasa, '') as bahasa,
+	COALESCE(subjek, '') as subjek, COALESCE(isbn_10, '') as isbn_10,
+	COALESCE(isbn_13, '') as isbn_13, rating_average, rating_count,
+	created_at, updated_at, deleted_at, ` + inStock + ` AS in_stock`
+
+// inStock is true for books that can be bought. books.stok only totals the
+// formats that have a stock; formats without one, such as most ebooks,
+// never run out.
+const inStock = `(books.stok > 0 OR books.id IN (
+	SELECT book_id FROM book_variants WHERE stok IS NULL AND deleted_at IS NULL
+))`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBook(row rowScanner, book *entity.Book) error {
+	return row.Scan(
+		&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
+		&book.Harga, &book.Keterangan, &book.GambarBuku,
+		&book.Penulis, &book.Penerbit, &book.Bahasa, &book.Subjek,
+		&book.ISBN10, &book.ISBN13, &book.RatingAverage, &book.RatingCount,
+		&book.CreatedAt, &book.UpdatedAt, &book.DeletedAt, &book.InStock,
+	)
+}
+
 type bookRepository struct {
 	db *sql.DB
 }
@@ -25,59 +63,265 @@ func NewBookRepository(db *sql.DB) BookRepository {
 	return &bookRepository{db: db}
 }
 
+// IsDuplicateISBN reports whether err is a unique violation on one of the
+// ISBN indexes. The service checks ISBNs before saving, but two concurrent
+// writes can both pass that check.
+func IsDuplicateISBN(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return false
+	}
+	return pqErr.Constraint == "idx_books_isbn_10" || pqErr.Constraint == "idx_books_isbn_13"
+}
+
 func (r *bookRepository) Create(book *entity.Book) error {
 	query := `
-		INSERT INTO books (nama_barang, stok, harga, keterangan, gambar_buku)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO books (nama_barang, stok, harga, keterangan, gambar_buku,
+		                   penulis, penerbit, bahasa, subjek, isbn_10, isbn_13)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''))
 		RETURNING id, terjual, created_at, updated_at
 	`
 	return r.db.QueryRow(query, book.NamaBarang, book.Stok, book.Harga,
-		book.Keterangan, book.GambarBuku).
+		book.Keterangan, book.GambarBuku, book.Penulis, book.Penerbit,
+		book.Bahasa, book.Subjek, book.ISBN10, book.ISBN13).
 		Scan(&book.ID, &book.Terjual, &book.CreatedAt, &book.UpdatedAt)
 }
 
-func (r *bookRepository) FindAll() ([]entity.Book, error) {
-	query := `
-		SELECT id, nama_barang, stok, terjual, harga, keterangan,
-		       COALESCE(gambar_buku, '') as gambar_buku, created_at, updated_at
+// FindAll returns one page of books matching the filter. With an After
+// cursor the page starts after that row (keyset pagination) and Offset is
+// ignored.
+func (r *bookRepository) FindAll(filter BookFilter) ([]entity.Book, error) {
+	query, args, err := listQuery(bookColumns, filter)
+	if err != nil {
+		return nil, err
+	}
+	return r.findMany(query, args...)
+}
+
+// Stream calls fn for every book matching the filter, with its categories
+// and contributors loaded, reading the rows one at a time instead of
+// collecting them. Returning an error from fn stops the iteration.
+func (r *bookRepository) Stream(filter BookFilter, fn func(book *entity.Book) error) error {
+	query, args, err := listQuery(bookColumns+`,
+		COALESCE((
+			SELECT json_agg(json_build_object('id', c.id, 'parent_id', c.parent_id,
+				'nama', c.nama, 'slug', c.slug) ORDER BY c.nama)
+			FROM book_categories bc
+			JOIN categories c ON c.id = bc.category_id
+			WHERE bc.book_id = books.id
+		), '[]') AS categories,
+		COALESCE((
+			SELECT json_agg(json_build_object('author_id', a.id, 'nama', a.nama,
+				'slug', a.slug, 'role', bc.role) ORDER BY bc.position, bc.role)
+			FROM book_contributors bc
+			JOIN authors a ON a.id = bc.author_id
+			WHERE bc.book_id = books.id
+		), '[]') AS contributors`, filter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book entity.Book
+		var categories, contributors []byte
+		err := rows.Scan(
+			&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
+			&book.Harga, &book.Keterangan, &book.GambarBuku,
+			&book.Penulis, &book.Penerbit, &book.Bahasa, &book.Subjek,
+			&book.ISBN10, &book.ISBN13, &book.RatingAverage, &book.RatingCount,
+			&book.CreatedAt, &book.UpdatedAt, &book.DeletedAt, &book.InStock,
+			&categories, &contributors,
+		)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(categories, &book.Categories); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(contributors, &book.Contributors); err != nil {
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// listQuery builds the SELECT of the given columns for the filtered, sorted
+// and paged book list
+func listQuery(columns string, filter BookFilter) (string, []interface{}, error) {
+	sort, ok := bookSortColumns[filter.Sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+	direction := "ASC"
+	comparison := ">"
+	if filter.Desc {
+		direction = "DESC"
+		comparison = "<"
+	}
+
+	where, args := filter.where()
+	if filter.After != nil {
+		args = append(args, filter.After.Value, filter.After.ID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			sort.column, comparison, len(args)-1, sort.castType, len(args)))
+	}
+
+	query := `SELECT ` + columns + `
 		FROM books
-		ORDER BY id DESC
 	`
-	rows, err := r.db.Query(query)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", sort.column, direction)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.After == nil && filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return query, args, nil
+}
+
+// Count returns the number of books matching the filter, ignoring paging
+func (r *bookRepository) Count(filter BookFilter) (int, error) {
+	where, args := filter.where()
+	query := `SELECT COUNT(*) FROM books`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRow(query, args...).Scan(&total)
+	return total, err
+}
+
+// escapeHTML wraps a text expression so HTML special characters in it are
+// escaped. ts_headline copies its input verbatim apart from the <mark> tags
+// it adds, so the text is escaped first and the snippet is safe to render.
+func escapeHTML(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// Search runs a to_tsquery expression against the tsvector column of the
+// given dictionary, ordered by relevance. Snippets are generated in the outer
+// query so ts_headline only runs for the rows that are returned. The book
+// text in the snippets is HTML-escaped; only the <mark> tags are markup.
+func (r *bookRepository) Search(tsquery, dictionary string, limit int) ([]entity.BookSearchResult, error) {
+	var column string
+	switch dictionary {
+	case entity.SearchDictionaryIndonesian:
+		column = "search_indonesian"
+	case entity.SearchDictionarySimple:
+		column = "search_simple"
+	default:
+		return nil, fmt.Errorf("unknown search dictionary %q", dictionary)
+	}
+
+	query := fmt.Sprintf(`
+		WITH q AS (SELECT to_tsquery($1::regconfig, $2) AS query),
+		matches AS (
+			SELECT b.id, ts_rank_cd(b.%[1]s, q.query) AS rank
+			FROM books b, q
+			WHERE b.%[1]s @@ q.query AND b.deleted_at IS NULL
+			ORDER BY rank DESC, b.id DESC
+			LIMIT $3
+		)
+		SELECT `+bookColumns+`, m.rank,
+			ts_headline($1::regconfig, %[2]s, q.query,
+				'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline($1::regconfig, %[3]s, q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10')
+		FROM matches m
+		JOIN books USING (id), q
+		ORDER BY m.rank DESC, id DESC
+	`, column, escapeHTML("nama_barang"), escapeHTML("COALESCE(keterangan, '')"))
+
+	rows, err := r.db.Query(query, dictionary, tsquery, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var books []entity.Book
+	var results []entity.BookSearchResult
 	for rows.Next() {
-		var book entity.Book
+		var result entity.BookSearchResult
+		book := &result.Book
 		err := rows.Scan(
 			&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
 			&book.Harga, &book.Keterangan, &book.GambarBuku,
-			&book.CreatedAt, &book.UpdatedAt,
+			&book.Penulis, &book.Penerbit, &book.Bahasa, &book.Subjek,
+			&book.ISBN10, &book.ISBN13, &book.RatingAverage, &book.RatingCount,
+			&book.CreatedAt, &book.UpdatedAt, &book.DeletedAt, &book.InStock,
+			&result.Rank, &result.Highlight.NamaBarang, &result.Highlight.Keterangan,
 		)
 		if err != nil {
 			return nil, err
 		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *bookRepository) findMany(query string, args ...interface{}) ([]entity.Book, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []entity.Book
+	for rows.Next() {
+		var book entity.Book
+		if err := scanBook(rows, &book); err != nil {
+			return nil, err
+		}
 		books = append(books, book)
 	}
 	return books, nil
 }
 
 func (r *bookRepository) FindByID(id int) (*entity.Book, error) {
-	query := `
-		SELECT id, nama_barang, stok, terjual, harga, keterangan,
-		       COALESCE(gambar_buku, '') as gambar_buku, created_at, updated_at
+	query := `SELECT ` + bookColumns + `
 		FROM books
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	book := &entity.Book{}
-	err := r.db.QueryRow(query, id).Scan(
-		&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
-		&book.Harga, &book.Keterangan, &book.GambarBuku,
-		&book.CreatedAt, &book.UpdatedAt,
-	)
+	err := scanBook(r.db.QueryRow(query, id), book)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("book not found")
+		}
+		return nil, err
+	}
+	return book, nil
+}
+
+// FindByISBN returns the book with either ISBN. Empty arguments never match.
+// Soft deleted books are included, since they still hold their ISBNs; check
+// DeletedAt.
+func (r *bookRepository) FindByISBN(isbn10, isbn13 string) (*entity.Book, error) {
+	query := `SELECT ` + bookColumns + `
+		FROM books
+		WHERE isbn_10 = NULLIF($1, '') OR isbn_13 = NULLIF($2, '')
+		ORDER BY id
+		LIMIT 1
+	`
+	book := &entity.Book{}
+	err := scanBook(r.db.QueryRow(query, isbn10, isbn13), book)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("book not found")
@@ -87,29 +331,69 @@ func (r *bookRepository) FindByID(id int) (*entity.Book, error) {
 	return book, nil
 }
 
-func (r *bookRepository) Update(id int, book *entity.Book) error {
+// Update saves the book and passes its price and stock on to its format (see
+// syncBookVariants), reading back the values derived from it. When version
+// is set, the change is appended to the book's history in the same
+// transaction, see recordBookChange.
+func (r *bookRepository) Update(id int, book *entity.Book, version *entity.BookVersion) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockBookSnapshot(tx, id)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE books
 		SET nama_barang = $1, stok = $2, terjual = $3, harga = $4,
-		    keterangan = $5, gambar_buku = $6, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $7
+		    keterangan = $5, gambar_buku = $6, penulis = $7, penerbit = $8,
+		    bahasa = $9, subjek = $10, isbn_10 = NULLIF($11, ''), isbn_13 = NULLIF($12, ''),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $13 AND deleted_at IS NULL
 		RETURNING updated_at
 	`
-	result := r.db.QueryRow(query, book.NamaBarang, book.Stok, book.Terjual,
-		book.Harga, book.Keterangan, book.GambarBuku, id)
+	result := tx.QueryRow(query, book.NamaBarang, book.Stok, book.Terjual,
+		book.Harga, book.Keterangan, book.GambarBuku, book.Penulis,
+		book.Penerbit, book.Bahasa, book.Subjek, book.ISBN10, book.ISBN13, id)
 
-	err := result.Scan(&book.UpdatedAt)
+	err = result.Scan(&book.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("book not found")
 		}
 		return err
 	}
-	return nil
+
+	if err := syncBookVariants(tx, id, entity.VariantFormatEPUB); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`SELECT harga, stok, `+inStock+` FROM books WHERE id = $1`, id).
+		Scan(&book.Harga, &book.Stok, &book.InStock)
+	if err != nil {
+		return err
+	}
+
+	if version != nil {
+		version.BookID = id
+		if err := recordBookChange(tx, version, before); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
+// Delete soft deletes the book. It disappears from the catalog and from
+// carts but stays in order history and libraries, and can be restored.
 func (r *bookRepository) Delete(id int) error {
-	query := `DELETE FROM books WHERE id = $1`
+	query := `
+		UPDATE books
+		SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+	`
 	result, err := r.db.Exec(query, id)
 	if err != nil {
 		return err
@@ -121,19 +405,20 @@ func (r *bookRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *bookRepository) UpdateStock(id int, quantity int) error {
+// Restore brings a soft deleted book back into the catalog
+func (r *bookRepository) Restore(id int) error {
 	query := `
 		UPDATE books
-		SET stok = stok - $1, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $2 AND stok >= $1
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NOT NULL
 	`
-	result, err := r.db.Exec(query, quantity, id)
+	result, err := r.db.Exec(query, id)
 	if err != nil {
 		return err
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("insufficient stock or book not found")
+		return fmt.Errorf("deleted book not found")
 	}
 	return nil
 }
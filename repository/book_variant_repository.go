@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+// refreshBookFromVariants sets the catalog price of book $1 to its cheapest
+// format and its stock to the total stock of the formats that have one
+const refreshBookFromVariants = `
+	UPDATE books
+	SET harga = v.harga, stok = v.stok
+	FROM (
+		SELECT MIN(harga) AS harga, COALESCE(SUM(stok), 0) AS stok
+		FROM book_variants
+		WHERE book_id = $1 AND deleted_at IS NULL
+	) v
+	WHERE books.id = $1 AND v.harga IS NOT NULL`
+
+const bookVariantColumns = `id, book_id, format, harga, stok, terjual, created_at, updated_at`
+
+type BookVariantRepository interface {
+	Create(variant *entity.BookVariant) error
+	FindByID(id int) (*entity.BookVariant, error)
+	FindByBookID(bookID int) ([]entity.BookVariant, error)
+	FindByBookAndFormat(bookID int, format string) (*entity.BookVariant, error)
+	Update(variant *entity.BookVariant) error
+	Delete(id int) error
+	SyncFromBook(bookID int, format string) error
+	RecordSale(id, quantity int) error
+}
+
+type bookVariantRepository struct {
+	db *sql.DB
+}
+
+func NewBookVariantRepository(db *sql.DB) BookVariantRepository {
+	return &bookVariantRepository{db: db}
+}
+
+func scanBookVariant(row rowScanner, variant *entity.BookVariant) error {
+	var stok sql.NullInt64
+	err := row.Scan(
+		&variant.ID, &variant.BookID, &variant.Format, &variant.Harga,
+		&stok, &variant.Terjual, &variant.CreatedAt, &variant.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if stok.Valid {
+		n := int(stok.Int64)
+		variant.Stok = &n
+	}
+	return nil
+}
+
+func (r *bookVariantRepository) Create(variant *entity.BookVariant) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO book_variants (book_id, format, harga, stok)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, variant.BookID, variant.Format, variant.Harga, variant.Stok).
+		Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookFromVariants, variant.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *bookVariantRepository) FindByID(id int) (*entity.BookVariant, error) {
+	query := `SELECT ` + bookVariantColumns + ` FROM book_variants WHERE id = $1 AND deleted_at IS NULL`
+	variant := &entity.BookVariant{}
+	err := scanBookVariant(r.db.QueryRow(query, id), variant)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("book variant not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+// FindByBookID lists the formats a book is sold in, cheapest first
+func (r *bookVariantRepository) FindByBookID(bookID int) ([]entity.BookVariant, error) {
+	query := `SELECT ` + bookVariantColumns + `
+		FROM book_variants
+		WHERE book_id = $1 AND deleted_at IS NULL
+		ORDER BY harga, id
+	`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []entity.BookVariant
+	for rows.Next() {
+		var variant entity.BookVariant
+		if err := scanBookVariant(rows, &variant); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, rows.Err()
+}
+
+// FindByBookAndFormat returns the book's variant in the format, or nil if
+// the book is not sold in it
+func (r *bookVariantRepository) FindByBookAndFormat(bookID int, format string) (*entity.BookVariant, error) {
+	query := `SELECT ` + bookVariantColumns + `
+		FROM book_variants
+		WHERE book_id = $1 AND format = $2 AND deleted_at IS NULL
+	`
+	variant := &entity.BookVariant{}
+	err := scanBookVariant(r.db.QueryRow(query, bookID, format), variant)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (r *bookVariantRepository) Update(variant *entity.BookVariant) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE book_variants
+		SET format = $1, harga = $2, stok = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND deleted_at IS NULL
+		RETURNING updated_at
+	`, variant.Format, variant.Harga, variant.Stok, variant.ID).Scan(&variant.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("book variant not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookFromVariants, variant.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete stops selling a format. The variant is soft deleted so past order
+// items keep pointing at it, and it is taken out of every cart. A book's
+// last format cannot be deleted.
+func (r *bookVariantRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bookID int
+	err = tx.QueryRow(`
+		SELECT book_id FROM book_variants
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&bookID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("book variant not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM book_variants WHERE book_id = $1 AND deleted_at IS NULL`, bookID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return fmt.Errorf("a book must be sold in at least one format")
+	}
+
+	if _, err := tx.Exec(`UPDATE book_variants SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM carts WHERE variant_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(refreshBookFromVariants, bookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SyncFromBook applies a price and stock set on the book itself to its
+// formats, see syncBookVariants
+func (r *bookVariantRepository) SyncFromBook(bookID int, format string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := syncBookVariants(tx, bookID, format); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordSale takes the sold quantity off the variant's stock, when it has
+// one, and adds it to its sold count
+func (r *bookVariantRepository) RecordSale(id, quantity int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bookID int
+	err = tx.QueryRow(`
+		UPDATE book_variants
+		SET stok = stok - $1, terjual = terjual + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND deleted_at IS NULL AND (stok IS NULL OR stok >= $1)
+		RETURNING book_id
+	`, quantity, id).Scan(&bookID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("insufficient stock")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookFromVariants, bookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// syncBookVariants keeps the formats of a book in step with the price and
+// stock written to the book row by the book endpoints and imports. A book
+// without formats gets one in format, and a book sold in a single format
+// passes its price and stock on to it (a format without stock keeps
+// none). Books with several formats get their price and stock recomputed
+// from the formats instead.
+func syncBookVariants(tx *sql.Tx, bookID int, format string) error {
+	_, err := tx.Exec(`
+		INSERT INTO book_variants (book_id, format, harga, stok)
+		SELECT id, $2, harga, stok FROM books
+		WHERE id = $1 AND NOT EXISTS (
+			SELECT 1 FROM book_variants WHERE book_id = $1 AND deleted_at IS NULL
+		)
+	`, bookID, format)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE book_variants v
+		SET harga = b.harga,
+			stok = CASE WHEN v.stok IS NULL THEN NULL ELSE b.stok END,
+			updated_at = CURRENT_TIMESTAMP
+		FROM books b
+		WHERE b.id = $1 AND v.book_id = $1 AND v.deleted_at IS NULL
+			AND (SELECT COUNT(*) FROM book_variants WHERE book_id = $1 AND deleted_at IS NULL) = 1
+	`, bookID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(refreshBookFromVariants, bookID)
+	return err
+}
@@ -11,6 +11,7 @@ type CartRepository interface {
 	Create(cart *entity.Cart) error
 	FindByUserID(userID int) ([]entity.CartItem, error)
 	FindByUserAndBook(userID, bookID int) (*entity.Cart, error)
+	FindByUserAndVariant(userID, variantID int) (*entity.Cart, error)
 	UpdateQuantity(id int, quantity int) error
 	Delete(id int) error
 	DeleteByUserID(userID int) error
@@ -27,23 +28,24 @@ func NewCartRepository(db *sql.DB) CartRepository {
 
 func (r *cartRepository) Create(cart *entity.Cart) error {
 	query := `
-		INSERT INTO carts (user_id, book_id, jumlah, harga)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO carts (user_id, book_id, variant_id, jumlah, harga)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at
 	`
-	return r.db.QueryRow(query, cart.UserID, cart.BookID, cart.Jumlah, cart.Harga).
+	return r.db.QueryRow(query, cart.UserID, cart.BookID, cart.VariantID, cart.Jumlah, cart.Harga).
 		Scan(&cart.ID, &cart.CreatedAt, &cart.UpdatedAt)
 }
 
 func (r *cartRepository) FindByUserID(userID int) ([]entity.CartItem, error) {
 	query := `
 		SELECT 
-			c.id, c.book_id, c.jumlah, c.harga,
-			b.nama_barang, b.stok, b.harga as harga_satuan,
+			c.id, c.book_id, c.variant_id, v.format, c.jumlah, c.harga,
+			b.nama_barang, v.stok, v.harga as harga_satuan,
 			COALESCE(b.gambar_buku, '') as gambar_buku
 		FROM carts c
 		JOIN books b ON c.book_id = b.id
-		WHERE c.user_id = $1
+		JOIN book_variants v ON c.variant_id = v.id
+		WHERE c.user_id = $1 AND b.deleted_at IS NULL AND v.deleted_at IS NULL
 		ORDER BY c.created_at DESC
 	`
 	rows, err := r.db.Query(query, userID)
@@ -55,28 +57,42 @@ func (r *cartRepository) FindByUserID(userID int) ([]entity.CartItem, error) {
 	var items []entity.CartItem
 	for rows.Next() {
 		var item entity.CartItem
+		var stok sql.NullInt64
 		err := rows.Scan(
-			&item.ID, &item.BookID, &item.Jumlah, &item.Harga,
-			&item.NamaBarang, &item.Stok, &item.HargaSatuan, &item.GambarBuku,
+			&item.ID, &item.BookID, &item.VariantID, &item.Format, &item.Jumlah, &item.Harga,
+			&item.NamaBarang, &stok, &item.HargaSatuan, &item.GambarBuku,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if stok.Valid {
+			n := int(stok.Int64)
+			item.Stok = &n
+		}
 		item.Subtotal = item.Jumlah * item.Harga
 		items = append(items, item)
 	}
 	return items, nil
 }
 
+// FindByUserAndBook returns the first cart row holding the book in any
+// format, or nil if the book is not in the cart
 func (r *cartRepository) FindByUserAndBook(userID, bookID int) (*entity.Cart, error) {
+	return r.findOne(`user_id = $1 AND book_id = $2 ORDER BY id LIMIT 1`, userID, bookID)
+}
+
+func (r *cartRepository) FindByUserAndVariant(userID, variantID int) (*entity.Cart, error) {
+	return r.findOne(`user_id = $1 AND variant_id = $2`, userID, variantID)
+}
+
+func (r *cartRepository) findOne(condition string, args ...interface{}) (*entity.Cart, error) {
 	query := `
-		SELECT id, user_id, book_id, jumlah, harga, created_at, updated_at
+		SELECT id, user_id, book_id, variant_id, jumlah, harga, created_at, updated_at
 		FROM carts
-		WHERE user_id = $1 AND book_id = $2
-	`
+		WHERE ` + condition
 	cart := &entity.Cart{}
-	err := r.db.QueryRow(query, userID, bookID).Scan(
-		&cart.ID, &cart.UserID, &cart.BookID, &cart.Jumlah,
+	err := r.db.QueryRow(query, args...).Scan(
+		&cart.ID, &cart.UserID, &cart.BookID, &cart.VariantID, &cart.Jumlah,
 		&cart.Harga, &cart.CreatedAt, &cart.UpdatedAt,
 	)
 	if err != nil {
@@ -126,9 +142,11 @@ func (r *cartRepository) DeleteByUserID(userID int) error {
 
 func (r *cartRepository) GetTotal(userID int) (int, error) {
 	query := `
-		SELECT COALESCE(SUM(jumlah * harga), 0)
-		FROM carts
-		WHERE user_id = $1
+		SELECT COALESCE(SUM(c.jumlah * c.harga), 0)
+		FROM carts c
+		JOIN books b ON c.book_id = b.id
+		JOIN book_variants v ON c.variant_id = v.id
+		WHERE c.user_id = $1 AND b.deleted_at IS NULL AND v.deleted_at IS NULL
 	`
 	var total int
 	err := r.db.QueryRow(query, userID).Scan(&total)
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type CategoryRepository interface {
+	Create(category *entity.Category) error
+	FindAll() ([]entity.Category, error)
+	FindByID(id int) (*entity.Category, error)
+	FindByBookID(bookID int) ([]entity.Category, error)
+	IsDescendant(id, ancestorID int) (bool, error)
+	HasChildren(id int) (bool, error)
+	Update(category *entity.Category) error
+	Delete(id int) error
+	SetBookCategories(bookID int, categoryIDs []int) error
+}
+
+type categoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) Create(category *entity.Category) error {
+	query := `
+		INSERT INTO categories (parent_id, nama, slug, keterangan)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(query, category.ParentID, category.Nama, category.Slug, category.Keterangan).
+		Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+}
+
+// FindAll returns every category with the number of distinct books filed
+// under it or any of its descendants
+func (r *categoryRepository) FindAll() ([]entity.Category, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id AS root_id, id FROM categories
+			UNION ALL
+			SELECT tree.root_id, c.id
+			FROM categories c
+			JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT c.id, c.parent_id, c.nama, c.slug, COALESCE(c.keterangan, ''),
+			c.created_at, c.updated_at, COUNT(DISTINCT bc.book_id)
+		FROM categories c
+		JOIN tree ON tree.root_id = c.id
+		LEFT JOIN book_categories bc ON bc.category_id = tree.id
+			AND bc.book_id IN (SELECT id FROM books WHERE deleted_at IS NULL)
+		GROUP BY c.id
+		ORDER BY c.nama
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []entity.Category
+	for rows.Next() {
+		var category entity.Category
+		err := rows.Scan(
+			&category.ID, &category.ParentID, &category.Nama, &category.Slug,
+			&category.Keterangan, &category.CreatedAt, &category.UpdatedAt, &category.BookCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+func (r *categoryRepository) FindByID(id int) (*entity.Category, error) {
+	query := `
+		SELECT id, parent_id, nama, slug, COALESCE(keterangan, ''), created_at, updated_at
+		FROM categories
+		WHERE id = $1
+	`
+	var category entity.Category
+	err := r.db.QueryRow(query, id).Scan(
+		&category.ID, &category.ParentID, &category.Nama, &category.Slug,
+		&category.Keterangan, &category.CreatedAt, &category.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (r *categoryRepository) FindByBookID(bookID int) ([]entity.Category, error) {
+	query := `
+		SELECT c.id, c.parent_id, c.nama, c.slug, COALESCE(c.keterangan, ''), c.created_at, c.updated_at
+		FROM categories c
+		JOIN book_categories bc ON bc.category_id = c.id
+		WHERE bc.book_id = $1
+		ORDER BY c.nama
+	`
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []entity.Category
+	for rows.Next() {
+		var category entity.Category
+		err := rows.Scan(
+			&category.ID, &category.ParentID, &category.Nama, &category.Slug,
+			&category.Keterangan, &category.CreatedAt, &category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// IsDescendant reports whether id is ancestorID itself or lies below it
+func (r *categoryRepository) IsDescendant(id, ancestorID int) (bool, error) {
+	query := `
+		WITH RECURSIVE sub AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+		)
+		SELECT EXISTS (SELECT 1 FROM sub WHERE id = $2)
+	`
+	var exists bool
+	err := r.db.QueryRow(query, ancestorID, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *categoryRepository) HasChildren(id int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *categoryRepository) Update(category *entity.Category) error {
+	query := `
+		UPDATE categories
+		SET parent_id = $1, nama = $2, slug = $3, keterangan = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING updated_at
+	`
+	return r.db.QueryRow(query, category.ParentID, category.Nama, category.Slug,
+		category.Keterangan, category.ID).Scan(&category.UpdatedAt)
+}
+
+func (r *categoryRepository) Delete(id int) error {
+	query := `DELETE FROM categories WHERE id = $1`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("category not found")
+	}
+	return nil
+}
+
+// SetBookCategories replaces the categories of a book
+func (r *categoryRepository) SetBookCategories(bookID int, categoryIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM book_categories WHERE book_id = $1`, bookID); err != nil {
+		return err
+	}
+
+	for _, categoryID := range categoryIDs {
+		_, err := tx.Exec(`
+			INSERT INTO book_categories (book_id, category_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, bookID, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
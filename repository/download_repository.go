@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type DownloadRepository interface {
+	Reserve(download *entity.Download, limit int) (bool, error)
+	Finish(download *entity.Download, fileSize int64) error
+	Cancel(id int) error
+	FindByUserID(userID int) ([]entity.Download, error)
+	CountByOrderItem(orderItemID int) (int, error)
+	Reset(userID int, orderItemID int) (int64, error)
+}
+
+type downloadRepository struct {
+	db *sql.DB
+}
+
+func NewDownloadRepository(db *sql.DB) DownloadRepository {
+	return &downloadRepository{db: db}
+}
+
+// Reserve records a download before it is sent, so concurrent requests
+// cannot exceed the limit. The order item is locked while its downloads are
+// counted; a limit of 0 or less means unlimited. The row starts out counted
+// and partial with no bytes, and Finish or Cancel settles it.
+func (r *downloadRepository) Reserve(download *entity.Download, limit int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOrderItem(tx, download.OrderItemID); err != nil {
+		return false, err
+	}
+
+	if limit > 0 {
+		var used int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM downloads WHERE order_item_id = $1 AND counted`,
+			download.OrderItemID).Scan(&used)
+		if err != nil {
+			return false, err
+		}
+		if used >= limit {
+			return false, nil
+		}
+	}
+
+	download.Counted = true
+	download.Partial = true
+	download.Bytes = 0
+	err = tx.QueryRow(`
+		INSERT INTO downloads (user_id, order_item_id, book_file_id, ip_address, user_agent, bytes, counted, partial)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, download.UserID, download.OrderItemID, download.BookFileID, download.IPAddress,
+		download.UserAgent, download.Bytes, download.Counted, download.Partial).
+		Scan(&download.ID, &download.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Finish stores what was sent for a reserved download. A partial response
+// stays uncounted unless the bytes sent for the file since it was last
+// completed reach fileSize, in which case it completes the file and is
+// counted.
+func (r *downloadRepository) Finish(download *entity.Download, fileSize int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOrderItem(tx, download.OrderItemID); err != nil {
+		return err
+	}
+
+	if download.Partial {
+		var pending int64
+		err := tx.QueryRow(`
+			SELECT COALESCE(SUM(bytes), 0)
+			FROM downloads
+			WHERE order_item_id = $1 AND book_file_id = $2 AND partial AND id <> $3
+				AND id > COALESCE((
+					SELECT MAX(id) FROM downloads
+					WHERE order_item_id = $1 AND book_file_id = $2 AND NOT partial
+				), 0)
+		`, download.OrderItemID, download.BookFileID, download.ID).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		download.Partial = pending+download.Bytes < fileSize
+	}
+	download.Counted = !download.Partial
+
+	_, err = tx.Exec(`
+		UPDATE downloads
+		SET ip_address = $1, user_agent = $2, bytes = $3, counted = $4, partial = $5
+		WHERE id = $6
+	`, download.IPAddress, download.UserAgent, download.Bytes, download.Counted, download.Partial, download.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Cancel drops a reserved download that sent no content
+func (r *downloadRepository) Cancel(id int) error {
+	_, err := r.db.Exec(`DELETE FROM downloads WHERE id = $1`, id)
+	return err
+}
+
+// lockOrderItem serialises ledger writes for one purchase
+func lockOrderItem(tx *sql.Tx, orderItemID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM order_items WHERE id = $1 FOR UPDATE`, orderItemID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("order item not found")
+	}
+	return err
+}
+
+func (r *downloadRepository) FindByUserID(userID int) ([]entity.Download, error) {
+	query := `
+		SELECT
+			d.id, d.user_id, d.order_item_id, d.book_file_id, oi.book_id,
+			b.nama_barang, COALESCE(bf.format, ''), COALESCE(d.ip_address, ''),
+			COALESCE(d.user_agent, ''), d.bytes, d.counted, d.partial, d.created_at
+		FROM downloads d
+		JOIN order_items oi ON d.order_item_id = oi.id
+		JOIN books b ON oi.book_id = b.id
+		LEFT JOIN book_files bf ON d.book_file_id = bf.id
+		WHERE d.user_id = $1
+		ORDER BY d.created_at DESC
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var downloads []entity.Download
+	for rows.Next() {
+		var download entity.Download
+		err := rows.Scan(
+			&download.ID, &download.UserID, &download.OrderItemID, &download.BookFileID,
+			&download.BookID, &download.NamaBarang, &download.Format, &download.IPAddress,
+			&download.UserAgent, &download.Bytes, &download.Counted, &download.Partial, &download.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, download)
+	}
+	return downloads, nil
+}
+
+func (r *downloadRepository) CountByOrderItem(orderItemID int) (int, error) {
+	query := `SELECT COUNT(*) FROM downloads WHERE order_item_id = $1 AND counted`
+	var count int
+	err := r.db.QueryRow(query, orderItemID).Scan(&count)
+	return count, err
+}
+
+// Reset stops counting past downloads against the limit while keeping them
+// in the history. An orderItemID of 0 resets every purchase of the user.
+func (r *downloadRepository) Reset(userID int, orderItemID int) (int64, error) {
+	query := `
+		UPDATE downloads
+		SET counted = FALSE
+		WHERE user_id = $1 AND counted AND ($2 = 0 OR order_item_id = $2)
+	`
+	result, err := r.db.Exec(query, userID, orderItemID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
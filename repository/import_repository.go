@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/lib/pq"
+)
+
+type ImportRepository interface {
+	CreateJob(job *entity.ImportJob) error
+	FindJobs() ([]entity.ImportJob, error)
+	FindJobByID(id int) (*entity.ImportJob, error)
+	UpdateJob(job *entity.ImportJob) error
+	ApplyBatch(job *entity.ImportJob, records []entity.ImportRecord, commit, allOrNothing bool) ([]entity.BookChange, bool, error)
+}
+
+type importRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository(db *sql.DB) ImportRepository {
+	return &importRepository{db: db}
+}
+
+const importJobColumns = `
+	id, COALESCE(user_id, 0), format, file_name, original_name, mode, chunk_size, dry_run,
+	status, total_rows, processed_rows, created_count, updated_count,
+	error_count, COALESCE(errors, '[]'), COALESCE(message, ''),
+	created_at, updated_at, finished_at`
+
+func scanImportJob(row rowScanner, job *entity.ImportJob) error {
+	var errs []byte
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.Format, &job.FileName, &job.OriginalName,
+		&job.Mode, &job.ChunkSize, &job.DryRun, &job.Status, &job.TotalRows,
+		&job.ProcessedRows, &job.CreatedCount, &job.UpdatedCount,
+		&job.ErrorCount, &errs, &job.Message,
+		&job.CreatedAt, &job.UpdatedAt, &job.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(errs, &job.Errors)
+}
+
+func (r *importRepository) CreateJob(job *entity.ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (user_id, format, file_name, original_name, mode,
+		                         chunk_size, dry_run, status, total_rows)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(query, job.UserID, job.Format, job.FileName, job.OriginalName,
+		job.Mode, job.ChunkSize, job.DryRun, job.Status, job.TotalRows).
+		Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+}
+
+// FindJobs lists import jobs, newest first, without their row errors
+func (r *importRepository) FindJobs() ([]entity.ImportJob, error) {
+	query := `SELECT ` + importJobColumns + `
+		FROM import_jobs
+		ORDER BY created_at DESC, id DESC
+		LIMIT 100
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []entity.ImportJob
+	for rows.Next() {
+		var job entity.ImportJob
+		if err := scanImportJob(rows, &job); err != nil {
+			return nil, err
+		}
+		job.Errors = nil
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r *importRepository) FindJobByID(id int) (*entity.ImportJob, error) {
+	query := `SELECT ` + importJobColumns + `
+		FROM import_jobs
+		WHERE id = $1
+	`
+	job := &entity.ImportJob{}
+	err := scanImportJob(r.db.QueryRow(query, id), job)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import job not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *importRepository) UpdateJob(job *entity.ImportJob) error {
+	return updateImportJob(r.db, job)
+}
+
+// maxImportErrors caps the row errors stored on a job; ErrorCount keeps
+// counting past it
+const maxImportErrors = 1000
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func addImportError(job *entity.ImportJob, record entity.ImportRecord) {
+	job.ErrorCount++
+	if len(job.Errors) < maxImportErrors {
+		job.Errors = append(job.Errors, entity.ImportRowError{
+			Row:     record.Row,
+			ISBN:    record.Book.ISBN13,
+			Message: record.Error,
+		})
+	}
+}
+
+func updateImportJob(db queryRower, job *entity.ImportJob) error {
+	errs, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE import_jobs
+		SET status = $1, processed_rows = $2, created_count = $3, updated_count = $4,
+		    error_count = $5, errors = $6, message = $7, finished_at = $8,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9
+		RETURNING updated_at
+	`
+	return db.QueryRow(query, job.Status, job.ProcessedRows, job.CreatedCount,
+		job.UpdatedCount, job.ErrorCount, errs, job.Message, job.FinishedAt, job.ID).
+		Scan(&job.UpdatedAt)
+}
+
+// ApplyBatch upserts the records in one transaction, counting created and
+// updated books and appending row errors to the job. Every record runs in
+// its own savepoint so a failing row does not abort the others. The
+// transaction is committed, together with the job progress, only when
+// commit is set and, with allOrNothing, no record failed. It reports
+// whether the batch was committed and, if so, the books whose price or
+// stock it changed.
+func (r *importRepository) ApplyBatch(job *entity.ImportJob, records []entity.ImportRecord, commit, allOrNothing bool) ([]entity.BookChange, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var changes []entity.BookChange
+	failed := false
+	for _, record := range records {
+		job.ProcessedRows++
+		if record.Error != "" {
+			addImportError(job, record)
+			failed = true
+			continue
+		}
+
+		if _, err := tx.Exec(`SAVEPOINT import_record`); err != nil {
+			return nil, false, err
+		}
+		change, err := upsertImportRecord(tx, record, job.UserID)
+		if err != nil {
+			if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_record`); rbErr != nil {
+				return nil, false, rbErr
+			}
+			record.Error = err.Error()
+			addImportError(job, record)
+			failed = true
+			continue
+		}
+		if _, err := tx.Exec(`RELEASE SAVEPOINT import_record`); err != nil {
+			return nil, false, err
+		}
+
+		if change == nil {
+			job.CreatedCount++
+			continue
+		}
+		job.UpdatedCount++
+		if change.Before.Harga != change.After.Harga || change.Before.InStock != change.After.InStock {
+			changes = append(changes, *change)
+		}
+	}
+
+	if !commit || (allOrNothing && failed) {
+		return nil, false, nil
+	}
+	if err := updateImportJob(tx, job); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return changes, true, nil
+}
+
+// upsertImportRecord creates or updates the book with the record's ISBN-13.
+// Empty text fields and a nil Stok keep the current value of an existing
+// book. Contributor credits are replaced for the roles the record lists.
+// Soft deleted books are not touched. The creation or the changed fields are
+// recorded in the book's history under userID. An updated book is returned
+// as it was before and after the record; a created book returns nil.
+func upsertImportRecord(tx *sql.Tx, record entity.ImportRecord, userID int) (*entity.BookChange, error) {
+	book := record.Book
+
+	var existingID int
+	var before entity.BookSnapshot
+	err := tx.QueryRow(`SELECT id FROM books WHERE isbn_13 = $1`, book.ISBN13).Scan(&existingID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		if before, err = lockBookSnapshot(tx, existingID); err != nil {
+			return nil, err
+		}
+	}
+
+	var bookID int
+	var created bool
+	err = tx.QueryRow(`
+		INSERT INTO books (nama_barang, stok, harga, keterangan, penulis, penerbit,
+		                   bahasa, subjek, isbn_10, isbn_13)
+		VALUES ($1, COALESCE($2, 0), $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10)
+		ON CONFLICT (isbn_13) DO UPDATE SET
+			nama_barang = EXCLUDED.nama_barang,
+			stok = COALESCE($2, books.stok),
+			harga = EXCLUDED.harga,
+			keterangan = COALESCE(NULLIF(EXCLUDED.keterangan, ''), books.keterangan),
+			penulis = COALESCE(NULLIF(EXCLUDED.penulis, ''), books.penulis),
+			penerbit = COALESCE(NULLIF(EXCLUDED.penerbit, ''), books.penerbit),
+			bahasa = COALESCE(NULLIF(EXCLUDED.bahasa, ''), books.bahasa),
+			subjek = COALESCE(NULLIF(EXCLUDED.subjek, ''), books.subjek),
+			isbn_10 = COALESCE(EXCLUDED.isbn_10, books.isbn_10),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE books.deleted_at IS NULL
+		RETURNING id, xmax = 0
+	`, book.NamaBarang, record.Stok, book.Harga, book.Keterangan, book.Penulis,
+		book.Penerbit, book.Bahasa, book.Subjek, book.ISBN10, book.ISBN13).
+		Scan(&bookID, &created)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("the book with ISBN %s is deleted, restore it before importing", book.ISBN13)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syncBookVariants(tx, bookID, entity.VariantFormatEPUB); err != nil {
+		return nil, err
+	}
+
+	if len(record.Contributors) > 0 {
+		var roles []string
+		for _, contributor := range record.Contributors {
+			roles = append(roles, contributor.Role)
+		}
+		_, err := tx.Exec(`DELETE FROM book_contributors WHERE book_id = $1 AND role = ANY($2)`,
+			bookID, pq.Array(roles))
+		if err != nil {
+			return nil, err
+		}
+
+		for i, contributor := range record.Contributors {
+			var authorID int
+			err := tx.QueryRow(`
+				INSERT INTO authors (nama, slug)
+				VALUES ($1, $2)
+				ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+				RETURNING id
+			`, contributor.Nama, contributor.Slug).Scan(&authorID)
+			if err != nil {
+				return nil, err
+			}
+			if err := insertContributor(tx, bookID, authorID, contributor.Role, i); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := tx.Exec(refreshPenulis, pq.Array([]int{bookID})); err != nil {
+			return nil, err
+		}
+	}
+
+	if record.PublisherSlug != "" {
+		_, err := tx.Exec(`
+			WITH p AS (
+				INSERT INTO publishers (nama, slug)
+				VALUES ($1, $2)
+				ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+				RETURNING id, nama
+			)
+			UPDATE books SET publisher_id = p.id, penerbit = p.nama
+			FROM p
+			WHERE books.id = $3
+		`, book.Penerbit, record.PublisherSlug, bookID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	version := &entity.BookVersion{
+		BookID: bookID,
+		Action: entity.BookActionUpdate,
+		UserID: userID,
+	}
+	if created {
+		version.Action = entity.BookActionCreate
+		if version.Snapshot, err = lockBookSnapshot(tx, bookID); err != nil {
+			return nil, err
+		}
+		return nil, insertBookVersion(tx, version, nil)
+	}
+
+	if err := recordBookChange(tx, version, before); err != nil {
+		return nil, err
+	}
+	return &entity.BookChange{BookID: bookID, Before: before, After: version.Snapshot}, nil
+}
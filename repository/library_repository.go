@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type LibraryRepository interface {
+	FindByUserID(userID int) ([]entity.LibraryItem, error)
+}
+
+type libraryRepository struct {
+	db *sql.DB
+}
+
+func NewLibraryRepository(db *sql.DB) LibraryRepository {
+	return &libraryRepository{db: db}
+}
+
+// FindByUserID returns every book the user owns through a paid or completed
+// order, once per book, with the earliest purchase, the formats bought and
+// the book's files
+func (r *libraryRepository) FindByUserID(userID int) ([]entity.LibraryItem, error) {
+	query := `
+		WITH owned AS (
+			SELECT DISTINCT ON (oi.book_id) oi.book_id, o.id AS order_id, o.created_at
+			FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.user_id = $1 AND o.status IN ('paid', 'completed')
+			ORDER BY oi.book_id, o.created_at
+		),
+		purchased AS (
+			SELECT oi.book_id, string_agg(DISTINCT COALESCE(v.format, ''), ',') AS formats
+			FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			LEFT JOIN book_variants v ON oi.variant_id = v.id
+			WHERE o.user_id = $1 AND o.status IN ('paid', 'completed')
+			GROUP BY oi.book_id
+		)
+		SELECT
+			b.id, b.nama_barang, COALESCE(b.penulis, ''), COALESCE(b.gambar_buku, ''),
+			owned.order_id, owned.created_at, purchased.formats, bf.id, bf.format, bf.size
+		FROM owned
+		JOIN purchased ON purchased.book_id = owned.book_id
+		JOIN books b ON owned.book_id = b.id
+		LEFT JOIN book_files bf ON bf.book_id = b.id
+		ORDER BY owned.created_at DESC, b.id, bf.id
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []entity.LibraryItem
+	for rows.Next() {
+		var item entity.LibraryItem
+		var purchased string
+		var fileID sql.NullInt64
+		var format sql.NullString
+		var size sql.NullInt64
+		err := rows.Scan(
+			&item.BookID, &item.NamaBarang, &item.Penulis, &item.GambarBuku,
+			&item.OrderID, &item.PurchasedAt, &purchased, &fileID, &format, &size,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.PurchasedFormats = strings.Split(purchased, ",")
+
+		// Rows are ordered by book, so files of the same book are adjacent
+		if len(items) == 0 || items[len(items)-1].BookID != item.BookID {
+			item.Formats = []string{}
+			item.Files = []entity.LibraryFile{}
+			items = append(items, item)
+		}
+
+		if fileID.Valid {
+			last := &items[len(items)-1]
+			last.Files = append(last.Files, entity.LibraryFile{
+				ID:     int(fileID.Int64),
+				Format: format.String,
+				Size:   size.Int64,
+			})
+		}
+	}
+	return items, nil
+}
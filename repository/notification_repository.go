@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type NotificationRepository interface {
+	NotifyWishlisters(bookID int, notificationType, message string) (int, error)
+	FindByUserID(userID int, unreadOnly bool, limit int) ([]entity.Notification, error)
+	CountUnread(userID int) (int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) error
+}
+
+type notificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// NotifyWishlisters records the notification for every user with the book
+// on their wishlist and returns how many were notified
+func (r *notificationRepository) NotifyWishlisters(bookID int, notificationType, message string) (int, error) {
+	query := `
+		INSERT INTO notifications (user_id, book_id, type, message)
+		SELECT user_id, book_id, $2, $3
+		FROM wishlists
+		WHERE book_id = $1
+	`
+	result, err := r.db.Exec(query, bookID, notificationType, message)
+	if err != nil {
+		return 0, err
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+// FindByUserID lists the user's newest notifications first
+func (r *notificationRepository) FindByUserID(userID int, unreadOnly bool, limit int) ([]entity.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, COALESCE(n.book_id, 0), COALESCE(b.nama_barang, ''),
+			n.type, n.message, n.read_at, n.created_at
+		FROM notifications n
+		LEFT JOIN books b ON n.book_id = b.id
+		WHERE n.user_id = $1 AND ($2 = false OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $3
+	`
+	rows, err := r.db.Query(query, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []entity.Notification
+	for rows.Next() {
+		var notification entity.Notification
+		err := rows.Scan(
+			&notification.ID, &notification.UserID, &notification.BookID, &notification.NamaBarang,
+			&notification.Type, &notification.Message, &notification.ReadAt, &notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *notificationRepository) CountUnread(userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+func (r *notificationRepository) MarkRead(userID, id int) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2
+	`
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("notification not found")
+	}
+	return nil
+}
+
+func (r *notificationRepository) MarkAllRead(userID int) error {
+	query := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
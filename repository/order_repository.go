@@ -13,6 +13,9 @@ type OrderRepository interface {
 	FindByUserID(userID int) ([]entity.Order, error)
 	FindByID(id int) (*entity.OrderDetail, error)
 	UpdateStatus(id int, status string) error
+	HasPurchasedBook(userID, bookID int) (bool, error)
+	HasCompletedOrder(userID, bookID int) (bool, error)
+	FindPurchasedItems(userID, bookID int) ([]entity.OrderItem, error)
 }
 
 type orderRepository struct {
@@ -35,11 +38,11 @@ func (r *orderRepository) Create(order *entity.Order) error {
 
 func (r *orderRepository) CreateItem(item *entity.OrderItem) error {
 	query := `
-		INSERT INTO order_items (order_id, book_id, jumlah, harga)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO order_items (order_id, book_id, variant_id, jumlah, harga)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at
 	`
-	return r.db.QueryRow(query, item.OrderID, item.BookID, item.Jumlah, item.Harga).
+	return r.db.QueryRow(query, item.OrderID, item.BookID, item.VariantID, item.Jumlah, item.Harga).
 		Scan(&item.ID, &item.CreatedAt)
 }
 
@@ -93,8 +96,11 @@ func (r *orderRepository) FindByID(id int) (*entity.OrderDetail, error) {
 
 	// Get order items
 	itemsQuery := `
-		SELECT oi.id, oi.order_id, oi.book_id, oi.jumlah, oi.harga, oi.created_at
+		SELECT oi.id, oi.order_id, oi.book_id, COALESCE(oi.variant_id, 0), COALESCE(v.format, ''),
+			b.nama_barang, oi.jumlah, oi.harga, oi.created_at
 		FROM order_items oi
+		JOIN books b ON oi.book_id = b.id
+		LEFT JOIN book_variants v ON oi.variant_id = v.id
 		WHERE oi.order_id = $1
 	`
 	rows, err := r.db.Query(itemsQuery, id)
@@ -107,8 +113,8 @@ func (r *orderRepository) FindByID(id int) (*entity.OrderDetail, error) {
 	for rows.Next() {
 		var item entity.OrderItem
 		err := rows.Scan(
-			&item.ID, &item.OrderID, &item.BookID,
-			&item.Jumlah, &item.Harga, &item.CreatedAt,
+			&item.ID, &item.OrderID, &item.BookID, &item.VariantID, &item.Format,
+			&item.NamaBarang, &item.Jumlah, &item.Harga, &item.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -136,3 +142,67 @@ func (r *orderRepository) UpdateStatus(id int, status string) error {
 	}
 	return nil
 }
+
+func (r *orderRepository) HasPurchasedBook(userID, bookID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.user_id = $1 AND oi.book_id = $2
+			  AND o.status IN ('paid', 'completed')
+		)
+	`
+	var exists bool
+	err := r.db.QueryRow(query, userID, bookID).Scan(&exists)
+	return exists, err
+}
+
+// HasCompletedOrder reports whether the user has a completed order
+// containing the book. Paid orders that are not completed yet don't count.
+func (r *orderRepository) HasCompletedOrder(userID, bookID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.user_id = $1 AND oi.book_id = $2
+			  AND o.status = 'completed'
+		)
+	`
+	var exists bool
+	err := r.db.QueryRow(query, userID, bookID).Scan(&exists)
+	return exists, err
+}
+
+func (r *orderRepository) FindPurchasedItems(userID, bookID int) ([]entity.OrderItem, error) {
+	query := `
+		SELECT oi.id, oi.order_id, oi.book_id, COALESCE(oi.variant_id, 0), COALESCE(v.format, ''),
+			oi.jumlah, oi.harga, oi.created_at
+		FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		LEFT JOIN book_variants v ON oi.variant_id = v.id
+		WHERE o.user_id = $1 AND oi.book_id = $2
+		  AND o.status IN ('paid', 'completed')
+		ORDER BY oi.created_at
+	`
+	rows, err := r.db.Query(query, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []entity.OrderItem
+	for rows.Next() {
+		var item entity.OrderItem
+		err := rows.Scan(
+			&item.ID, &item.OrderID, &item.BookID, &item.VariantID, &item.Format,
+			&item.Jumlah, &item.Harga, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type PublisherRepository interface {
+	Create(publisher *entity.Publisher) error
+	FindAll(keyword string) ([]entity.Publisher, error)
+	FindByID(id int) (*entity.Publisher, error)
+	FindBySlug(slug string) (*entity.Publisher, error)
+	FindByBookID(bookID int) (*entity.Publisher, error)
+	FindOrCreate(publisher *entity.Publisher) error
+	Update(publisher *entity.Publisher) error
+	Delete(id int) error
+	SetBookPublisher(bookID int, publisherID *int) error
+}
+
+type publisherRepository struct {
+	db *sql.DB
+}
+
+func NewPublisherRepository(db *sql.DB) PublisherRepository {
+	return &publisherRepository{db: db}
+}
+
+func (r *publisherRepository) Create(publisher *entity.Publisher) error {
+	query := `
+		INSERT INTO publishers (nama, slug, keterangan)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(query, publisher.Nama, publisher.Slug, publisher.Keterangan).
+		Scan(&publisher.ID, &publisher.CreatedAt, &publisher.UpdatedAt)
+}
+
+func (r *publisherRepository) FindAll(keyword string) ([]entity.Publisher, error) {
+	query := `
+		SELECT p.id, p.nama, p.slug, COALESCE(p.keterangan, ''), p.created_at, p.updated_at,
+			COUNT(b.id)
+		FROM publishers p
+		LEFT JOIN books b ON b.publisher_id = p.id AND b.deleted_at IS NULL
+		WHERE $1 = '' OR p.nama ILIKE '%' || $1 || '%'
+		GROUP BY p.id
+		ORDER BY p.nama
+	`
+	rows, err := r.db.Query(query, keyword)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publishers []entity.Publisher
+	for rows.Next() {
+		var publisher entity.Publisher
+		err := rows.Scan(
+			&publisher.ID, &publisher.Nama, &publisher.Slug, &publisher.Keterangan,
+			&publisher.CreatedAt, &publisher.UpdatedAt, &publisher.BookCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	return publishers, rows.Err()
+}
+
+func (r *publisherRepository) FindByID(id int) (*entity.Publisher, error) {
+	return r.findOne(`p.id = $1`, id)
+}
+
+func (r *publisherRepository) FindBySlug(slug string) (*entity.Publisher, error) {
+	return r.findOne(`p.slug = $1`, slug)
+}
+
+// FindByBookID returns the publisher of a book, or nil if it has none
+func (r *publisherRepository) FindByBookID(bookID int) (*entity.Publisher, error) {
+	var publisherID *int
+	err := r.db.QueryRow(`SELECT publisher_id FROM books WHERE id = $1`, bookID).Scan(&publisherID)
+	if err != nil || publisherID == nil {
+		return nil, err
+	}
+	return r.FindByID(*publisherID)
+}
+
+func (r *publisherRepository) findOne(condition string, arg interface{}) (*entity.Publisher, error) {
+	query := `
+		SELECT p.id, p.nama, p.slug, COALESCE(p.keterangan, ''), p.created_at, p.updated_at,
+			(SELECT COUNT(*) FROM books WHERE publisher_id = p.id AND deleted_at IS NULL)
+		FROM publishers p
+		WHERE ` + condition
+	var publisher entity.Publisher
+	err := r.db.QueryRow(query, arg).Scan(
+		&publisher.ID, &publisher.Nama, &publisher.Slug, &publisher.Keterangan,
+		&publisher.CreatedAt, &publisher.UpdatedAt, &publisher.BookCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("publisher not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &publisher, nil
+}
+
+// FindOrCreate fills in the ID of the publisher with the same slug, creating
+// the publisher if there is none
+func (r *publisherRepository) FindOrCreate(publisher *entity.Publisher) error {
+	query := `
+		INSERT INTO publishers (nama, slug)
+		VALUES ($1, $2)
+		ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		RETURNING id, nama, created_at, updated_at
+	`
+	return r.db.QueryRow(query, publisher.Nama, publisher.Slug).
+		Scan(&publisher.ID, &publisher.Nama, &publisher.CreatedAt, &publisher.UpdatedAt)
+}
+
+// Update renames the publisher and the denormalised books.penerbit text
+func (r *publisherRepository) Update(publisher *entity.Publisher) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE publishers
+		SET nama = $1, slug = $2, keterangan = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+	err = tx.QueryRow(query, publisher.Nama, publisher.Slug, publisher.Keterangan, publisher.ID).
+		Scan(&publisher.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE books SET penerbit = $1 WHERE publisher_id = $2`, publisher.Nama, publisher.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the publisher and clears it from its books
+func (r *publisherRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE books SET penerbit = '', publisher_id = NULL WHERE publisher_id = $1`, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM publishers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("publisher not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetBookPublisher links a book to a publisher (nil to unlink) and keeps
+// books.penerbit in step with the publisher name
+func (r *publisherRepository) SetBookPublisher(bookID int, publisherID *int) error {
+	query := `
+		UPDATE books
+		SET publisher_id = $1,
+		    penerbit = COALESCE((SELECT nama FROM publishers WHERE id = $1), '')
+		WHERE id = $2
+	`
+	_, err := r.db.Exec(query, publisherID, bookID)
+	return err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type ReadingProgressRepository interface {
+	FindByUserID(userID int) ([]entity.ReadingProgress, error)
+	FindByUserAndBook(userID, bookID int) (*entity.ReadingProgress, error)
+	Upsert(progress *entity.ReadingProgress) (bool, error)
+}
+
+type readingProgressRepository struct {
+	db *sql.DB
+}
+
+func NewReadingProgressRepository(db *sql.DB) ReadingProgressRepository {
+	return &readingProgressRepository{db: db}
+}
+
+// FindByUserID lists progress for books the user still owns
+func (r *readingProgressRepository) FindByUserID(userID int) ([]entity.ReadingProgress, error) {
+	query := `
+		SELECT rp.id, rp.user_id, rp.book_id, b.nama_barang, rp.position,
+			rp.position_type, rp.percent, rp.device, rp.updated_at
+		FROM reading_progress rp
+		JOIN books b ON rp.book_id = b.id
+		WHERE rp.user_id = $1
+		AND EXISTS (
+			SELECT 1 FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.user_id = rp.user_id AND oi.book_id = rp.book_id
+			AND o.status IN ('paid', 'completed')
+		)
+		ORDER BY rp.updated_at DESC
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progress []entity.ReadingProgress
+	for rows.Next() {
+		var p entity.ReadingProgress
+		err := rows.Scan(
+			&p.ID, &p.UserID, &p.BookID, &p.NamaBarang, &p.Position,
+			&p.PositionType, &p.Percent, &p.Device, &p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+
+	return progress, rows.Err()
+}
+
+func (r *readingProgressRepository) FindByUserAndBook(userID, bookID int) (*entity.ReadingProgress, error) {
+	query := `
+		SELECT rp.id, rp.user_id, rp.book_id, b.nama_barang, rp.position,
+			rp.position_type, rp.percent, rp.device, rp.updated_at
+		FROM reading_progress rp
+		JOIN books b ON rp.book_id = b.id
+		WHERE rp.user_id = $1 AND rp.book_id = $2
+	`
+	var p entity.ReadingProgress
+	err := r.db.QueryRow(query, userID, bookID).Scan(
+		&p.ID, &p.UserID, &p.BookID, &p.NamaBarang, &p.Position,
+		&p.PositionType, &p.Percent, &p.Device, &p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Upsert stores the progress unless a newer position is already saved for
+// the same book (latest timestamp wins). It reports whether the row was written.
+func (r *readingProgressRepository) Upsert(progress *entity.ReadingProgress) (bool, error) {
+	query := `
+		INSERT INTO reading_progress (user_id, book_id, position, position_type, percent, device, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, book_id) DO UPDATE SET
+			position = EXCLUDED.position,
+			position_type = EXCLUDED.position_type,
+			percent = EXCLUDED.percent,
+			device = EXCLUDED.device,
+			updated_at = EXCLUDED.updated_at
+		WHERE reading_progress.updated_at < EXCLUDED.updated_at
+		RETURNING id
+	`
+	err := r.db.QueryRow(query, progress.UserID, progress.BookID, progress.Position,
+		progress.PositionType, progress.Percent, progress.Device, progress.UpdatedAt).
+		Scan(&progress.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+// recommendationLock is the advisory lock key that keeps two rebuilds, for
+// example from two app instances, from running at the same time
+const recommendationLock = 724011
+
+type RecommendationRepository interface {
+	Rebuild(perItem int) (pairs, users int, err error)
+	FindRelated(bookID, limit int) ([]entity.Book, error)
+	FindForUser(userID, limit int) ([]entity.Book, error)
+}
+
+type recommendationRepository struct {
+	db *sql.DB
+}
+
+func NewRecommendationRepository(db *sql.DB) RecommendationRepository {
+	return &recommendationRepository{db: db}
+}
+
+// Rebuild recomputes both recommendation tables from paid and completed
+// orders, keeping the top perItem entries per book and per user. Readers
+// keep seeing the previous tables until the rebuild commits.
+func (r *recommendationRepository) Rebuild(perItem int) (int, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, recommendationLock); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM book_recommendations`); err != nil {
+		return 0, 0, err
+	}
+
+	// Two books score one point for every order that contains both
+	result, err := tx.Exec(`
+		WITH pairs AS (
+			SELECT a.book_id, b.book_id AS related_book_id, COUNT(DISTINCT a.order_id) AS score
+			FROM order_items a
+			JOIN order_items b ON b.order_id = a.order_id AND b.book_id <> a.book_id
+			JOIN orders o ON o.id = a.order_id
+			JOIN books rb ON rb.id = b.book_id
+			WHERE o.status IN ('paid', 'completed') AND rb.deleted_at IS NULL
+			GROUP BY a.book_id, b.book_id
+		), ranked AS (
+			SELECT book_id, related_book_id, score,
+				ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY score DESC, related_book_id) AS rank
+			FROM pairs
+		)
+		INSERT INTO book_recommendations (book_id, related_book_id, score, rank)
+		SELECT book_id, related_book_id, score, rank FROM ranked WHERE rank <= $1
+	`, perItem)
+	if err != nil {
+		return 0, 0, err
+	}
+	pairs, _ := result.RowsAffected()
+
+	if _, err := tx.Exec(`DELETE FROM user_recommendations`); err != nil {
+		return 0, 0, err
+	}
+
+	// A user's candidates are the books bought together with the books in
+	// their library, scored by the sum of those co-purchase scores
+	result, err = tx.Exec(`
+		WITH owned AS (
+			SELECT DISTINCT o.user_id, oi.book_id
+			FROM order_items oi
+			JOIN orders o ON oi.order_id = o.id
+			WHERE o.status IN ('paid', 'completed')
+		), scores AS (
+			SELECT owned.user_id, br.related_book_id AS book_id, SUM(br.score) AS score
+			FROM owned
+			JOIN book_recommendations br ON br.book_id = owned.book_id
+			WHERE NOT EXISTS (
+				SELECT 1 FROM owned mine
+				WHERE mine.user_id = owned.user_id AND mine.book_id = br.related_book_id
+			)
+			GROUP BY owned.user_id, br.related_book_id
+		), ranked AS (
+			SELECT user_id, book_id, score,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY score DESC, book_id) AS rank
+			FROM scores
+		)
+		INSERT INTO user_recommendations (user_id, book_id, score, rank)
+		SELECT user_id, book_id, score, rank FROM ranked WHERE rank <= $1
+	`, perItem)
+	if err != nil {
+		return 0, 0, err
+	}
+	users, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return int(pairs), int(users), nil
+}
+
+// FindRelated returns the books most often bought together with the book
+func (r *recommendationRepository) FindRelated(bookID, limit int) ([]entity.Book, error) {
+	query := `SELECT ` + bookColumns + `
+		FROM book_recommendations r
+		JOIN books ON books.id = r.related_book_id
+		WHERE r.book_id = $1 AND r.rank <= $2 AND books.deleted_at IS NULL
+		ORDER BY r.rank
+	`
+	return r.findBooks(query, bookID, limit)
+}
+
+// FindForUser returns the precomputed recommendations for the user
+func (r *recommendationRepository) FindForUser(userID, limit int) ([]entity.Book, error) {
+	query := `SELECT ` + bookColumns + `
+		FROM user_recommendations r
+		JOIN books ON books.id = r.book_id
+		WHERE r.user_id = $1 AND r.rank <= $2 AND books.deleted_at IS NULL
+		ORDER BY r.rank
+	`
+	return r.findBooks(query, userID, limit)
+}
+
+func (r *recommendationRepository) findBooks(query string, args ...interface{}) ([]entity.Book, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []entity.Book
+	for rows.Next() {
+		var book entity.Book
+		if err := scanBook(rows, &book); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/lib/pq"
+)
+
+// Sort keys accepted by ReviewFilter.Sort
+const (
+	ReviewSortNewest     = "newest"
+	ReviewSortHelpful    = "helpful"
+	ReviewSortRatingHigh = "rating_high"
+	ReviewSortRatingLow  = "rating_low"
+)
+
+var reviewSortOrders = map[string]string{
+	ReviewSortNewest:     "r.created_at DESC, r.id DESC",
+	ReviewSortHelpful:    "r.helpful_count DESC, r.created_at DESC, r.id DESC",
+	ReviewSortRatingHigh: "r.rating DESC, r.created_at DESC, r.id DESC",
+	ReviewSortRatingLow:  "r.rating ASC, r.created_at DESC, r.id DESC",
+}
+
+// IsReviewSort reports whether sort is a supported sort key
+func IsReviewSort(sort string) bool {
+	_, ok := reviewSortOrders[sort]
+	return ok
+}
+
+// ReviewFilter selects, orders and pages reviews
+type ReviewFilter struct {
+	BookID int    // 0 for every book
+	Status string // empty for every status
+
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+func (f ReviewFilter) where() ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	if f.BookID != 0 {
+		args = append(args, f.BookID)
+		where = append(where, fmt.Sprintf("r.book_id = $%d", len(args)))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		where = append(where, fmt.Sprintf("r.status = $%d", len(args)))
+	}
+	return where, args
+}
+
+// refreshBookRating recomputes the rating aggregates of book $1 from its
+// approved reviews
+const refreshBookRating = `
+	UPDATE books
+	SET rating_average = COALESCE((
+			SELECT ROUND(AVG(rating), 2) FROM reviews
+			WHERE book_id = $1 AND status = 'approved'
+		), 0),
+		rating_count = (
+			SELECT COUNT(*) FROM reviews
+			WHERE book_id = $1 AND status = 'approved'
+		)
+	WHERE id = $1`
+
+type ReviewRepository interface {
+	Create(review *entity.Review) error
+	FindAll(filter ReviewFilter) ([]entity.Review, error)
+	Count(filter ReviewFilter) (int, error)
+	FindByID(id int) (*entity.Review, error)
+	FindByUserAndBook(userID, bookID int) (*entity.Review, error)
+	Update(review *entity.Review) error
+	UpdateStatus(id int, status string) error
+	Delete(id int) error
+	AddVote(reviewID, userID int) error
+	RemoveVote(reviewID, userID int) error
+}
+
+type reviewRepository struct {
+	db *sql.DB
+}
+
+func NewReviewRepository(db *sql.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+const reviewColumns = `
+	r.id, r.book_id, b.nama_barang, r.user_id, u.username, r.rating, r.title, r.body,
+	r.status, r.helpful_count, r.created_at, r.updated_at`
+
+func scanReview(row rowScanner, review *entity.Review) error {
+	return row.Scan(
+		&review.ID, &review.BookID, &review.NamaBarang, &review.UserID, &review.Username,
+		&review.Rating, &review.Title, &review.Body, &review.Status,
+		&review.HelpfulCount, &review.CreatedAt, &review.UpdatedAt,
+	)
+}
+
+// IsDuplicateReview reports whether err is a unique violation on the one
+// review per user and book. The service checks for an existing review
+// first, but two concurrent submissions can both pass that check.
+func IsDuplicateReview(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return false
+	}
+	return pqErr.Constraint == "reviews_user_id_book_id_key"
+}
+
+func (r *reviewRepository) Create(review *entity.Review) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO reviews (book_id, user_id, rating, title, body, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, review.BookID, review.UserID, review.Rating,
+		review.Title, review.Body, review.Status).
+		Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookRating, review.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindAll returns one page of reviews matching the filter
+func (r *reviewRepository) FindAll(filter ReviewFilter) ([]entity.Review, error) {
+	order, ok := reviewSortOrders[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	where, args := filter.where()
+	query := `SELECT ` + reviewColumns + `
+		FROM reviews r
+		JOIN books b ON b.id = r.book_id
+		JOIN users u ON u.id = r.user_id
+	`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + order
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []entity.Review
+	for rows.Next() {
+		var review entity.Review
+		if err := scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// Count returns the number of reviews matching the filter, ignoring paging
+func (r *reviewRepository) Count(filter ReviewFilter) (int, error) {
+	where, args := filter.where()
+	query := `SELECT COUNT(*) FROM reviews r`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRow(query, args...).Scan(&total)
+	return total, err
+}
+
+func (r *reviewRepository) FindByID(id int) (*entity.Review, error) {
+	return r.findOne(`r.id = $1`, id)
+}
+
+// FindByUserAndBook returns the user's review of the book, or nil if there
+// is none
+func (r *reviewRepository) FindByUserAndBook(userID, bookID int) (*entity.Review, error) {
+	review, err := r.findOne(`r.user_id = $1 AND r.book_id = $2`, userID, bookID)
+	if err != nil && err.Error() == "review not found" {
+		return nil, nil
+	}
+	return review, err
+}
+
+func (r *reviewRepository) findOne(condition string, args ...interface{}) (*entity.Review, error) {
+	query := `SELECT ` + reviewColumns + `
+		FROM reviews r
+		JOIN books b ON b.id = r.book_id
+		JOIN users u ON u.id = r.user_id
+		WHERE ` + condition
+	var review entity.Review
+	if err := scanReview(r.db.QueryRow(query, args...), &review); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("review not found")
+		}
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) Update(review *entity.Review) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE reviews
+		SET rating = $1, title = $2, body = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+	err = tx.QueryRow(query, review.Rating, review.Title, review.Body, review.ID).
+		Scan(&review.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("review not found")
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookRating, review.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateStatus approves or hides a review. Only approved reviews count
+// towards the book's rating.
+func (r *reviewRepository) UpdateStatus(id int, status string) error {
+	return r.changeAndRefresh(`UPDATE reviews SET status = $2 WHERE id = $1 RETURNING book_id`, id, status)
+}
+
+func (r *reviewRepository) Delete(id int) error {
+	return r.changeAndRefresh(`DELETE FROM reviews WHERE id = $1 RETURNING book_id`, id)
+}
+
+// changeAndRefresh runs a statement on review $1 that returns its book_id,
+// then refreshes that book's rating in the same transaction
+func (r *reviewRepository) changeAndRefresh(query string, args ...interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bookID int
+	if err := tx.QueryRow(query, args...).Scan(&bookID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("review not found")
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(refreshBookRating, bookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddVote marks the review as helpful for the user. Voting twice is a no-op.
+func (r *reviewRepository) AddVote(reviewID, userID int) error {
+	return r.changeVote(`
+		INSERT INTO review_votes (review_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, reviewID, userID)
+}
+
+func (r *reviewRepository) RemoveVote(reviewID, userID int) error {
+	return r.changeVote(`DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2`, reviewID, userID)
+}
+
+func (r *reviewRepository) changeVote(query string, reviewID, userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query, reviewID, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reviews
+		SET helpful_count = (SELECT COUNT(*) FROM review_votes WHERE review_id = $1)
+		WHERE id = $1
+	`, reviewID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type SeriesRepository interface {
+	Create(series *entity.Series) error
+	FindAll(keyword string) ([]entity.Series, error)
+	FindByID(id int) (*entity.Series, error)
+	FindBySlug(slug string) (*entity.Series, error)
+	FindByBookID(bookID int) (*entity.BookSeries, error)
+	FindVolumes(seriesID int) ([]entity.SeriesVolume, error)
+	Update(series *entity.Series) error
+	Delete(id int) error
+	SetBookSeries(bookID int, seriesID *int, position int) error
+}
+
+type seriesRepository struct {
+	db *sql.DB
+}
+
+func NewSeriesRepository(db *sql.DB) SeriesRepository {
+	return &seriesRepository{db: db}
+}
+
+// seriesTotalVolumes is the highest position among the live books of series s
+const seriesTotalVolumes = `
+	(SELECT COALESCE(MAX(series_position), 0) FROM books
+	 WHERE series_id = s.id AND deleted_at IS NULL)`
+
+func (r *seriesRepository) Create(series *entity.Series) error {
+	query := `
+		INSERT INTO series (nama, slug, keterangan)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+	return r.db.QueryRow(query, series.Nama, series.Slug, series.Keterangan).
+		Scan(&series.ID, &series.CreatedAt, &series.UpdatedAt)
+}
+
+func (r *seriesRepository) FindAll(keyword string) ([]entity.Series, error) {
+	query := `
+		SELECT s.id, s.nama, s.slug, COALESCE(s.keterangan, ''), s.created_at, s.updated_at,
+			` + seriesTotalVolumes + `
+		FROM series s
+		WHERE $1 = '' OR s.nama ILIKE '%' || $1 || '%'
+		ORDER BY s.nama
+	`
+	rows, err := r.db.Query(query, keyword)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []entity.Series
+	for rows.Next() {
+		var series entity.Series
+		err := rows.Scan(
+			&series.ID, &series.Nama, &series.Slug, &series.Keterangan,
+			&series.CreatedAt, &series.UpdatedAt, &series.TotalVolumes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, series)
+	}
+
+	return list, rows.Err()
+}
+
+func (r *seriesRepository) FindByID(id int) (*entity.Series, error) {
+	return r.findOne(`s.id = $1`, id)
+}
+
+func (r *seriesRepository) FindBySlug(slug string) (*entity.Series, error) {
+	return r.findOne(`s.slug = $1`, slug)
+}
+
+func (r *seriesRepository) findOne(condition string, arg interface{}) (*entity.Series, error) {
+	query := `
+		SELECT s.id, s.nama, s.slug, COALESCE(s.keterangan, ''), s.created_at, s.updated_at,
+			` + seriesTotalVolumes + `
+		FROM series s
+		WHERE ` + condition
+	var series entity.Series
+	err := r.db.QueryRow(query, arg).Scan(
+		&series.ID, &series.Nama, &series.Slug, &series.Keterangan,
+		&series.CreatedAt, &series.UpdatedAt, &series.TotalVolumes,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("series not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+// FindByBookID returns the series of a book with the book's position, or
+// nil if it is not part of a series
+func (r *seriesRepository) FindByBookID(bookID int) (*entity.BookSeries, error) {
+	query := `
+		SELECT s.id, s.nama, s.slug, b.series_position, ` + seriesTotalVolumes + `
+		FROM books b
+		JOIN series s ON s.id = b.series_id
+		WHERE b.id = $1
+	`
+	var series entity.BookSeries
+	err := r.db.QueryRow(query, bookID).Scan(
+		&series.ID, &series.Nama, &series.Slug, &series.Position, &series.TotalVolumes,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// FindVolumes lists the live books of a series in volume order
+func (r *seriesRepository) FindVolumes(seriesID int) ([]entity.SeriesVolume, error) {
+	query := `SELECT series_position, ` + bookColumns + `
+		FROM books
+		WHERE series_id = $1 AND deleted_at IS NULL
+		ORDER BY series_position
+	`
+	rows, err := r.db.Query(query, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var volumes []entity.SeriesVolume
+	for rows.Next() {
+		var volume entity.SeriesVolume
+		book := &volume.Book
+		err := rows.Scan(
+			&volume.Position,
+			&book.ID, &book.NamaBarang, &book.Stok, &book.Terjual,
+			&book.Harga, &book.Keterangan, &book.GambarBuku,
+			&book.Penulis, &book.Penerbit, &book.Bahasa, &book.Subjek,
+			&book.ISBN10, &book.ISBN13, &book.RatingAverage, &book.RatingCount,
+			&book.CreatedAt, &book.UpdatedAt, &book.DeletedAt, &book.InStock,
+		)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, rows.Err()
+}
+
+func (r *seriesRepository) Update(series *entity.Series) error {
+	query := `
+		UPDATE series
+		SET nama = $1, slug = $2, keterangan = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+		RETURNING updated_at
+	`
+	return r.db.QueryRow(query, series.Nama, series.Slug, series.Keterangan, series.ID).
+		Scan(&series.UpdatedAt)
+}
+
+// Delete removes the series; its books stay in the catalog without one
+func (r *seriesRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE books SET series_id = NULL, series_position = NULL WHERE series_id = $1`, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM series WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("series not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetBookSeries places a book at a position in a series, or takes it out of
+// its series when seriesID is nil. A position held by another book, deleted
+// or not, is refused.
+func (r *seriesRepository) SetBookSeries(bookID int, seriesID *int, position int) error {
+	if seriesID == nil {
+		_, err := r.db.Exec(`UPDATE books SET series_id = NULL, series_position = NULL WHERE id = $1`, bookID)
+		return err
+	}
+
+	var other int
+	err := r.db.QueryRow(`
+		SELECT id FROM books
+		WHERE series_id = $1 AND series_position = $2 AND id <> $3
+	`, *seriesID, position, bookID).Scan(&other)
+	if err == nil {
+		return fmt.Errorf("volume %d of this series is already book %d", position, other)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = r.db.Exec(`UPDATE books SET series_id = $1, series_position = $2 WHERE id = $3`, *seriesID, position, bookID)
+	return err
+}
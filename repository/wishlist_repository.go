@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+type WishlistRepository interface {
+	Add(userID, bookID, harga int) error
+	Remove(userID, bookID int) error
+	FindByUserID(userID int) ([]entity.WishlistItem, error)
+}
+
+type wishlistRepository struct {
+	db *sql.DB
+}
+
+func NewWishlistRepository(db *sql.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+// Add puts the book on the user's wishlist, remembering its current price.
+// Adding a book that is already there is a no-op.
+func (r *wishlistRepository) Add(userID, bookID, harga int) error {
+	query := `
+		INSERT INTO wishlists (user_id, book_id, harga)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, book_id) DO NOTHING
+	`
+	_, err := r.db.Exec(query, userID, bookID, harga)
+	return err
+}
+
+func (r *wishlistRepository) Remove(userID, bookID int) error {
+	query := `DELETE FROM wishlists WHERE user_id = $1 AND book_id = $2`
+	result, err := r.db.Exec(query, userID, bookID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("book is not on the wishlist")
+	}
+	return nil
+}
+
+func (r *wishlistRepository) FindByUserID(userID int) ([]entity.WishlistItem, error) {
+	query := `
+		SELECT
+			w.id, w.book_id, b.nama_barang, b.harga, w.harga, b.stok,
+			COALESCE(b.gambar_buku, '') as gambar_buku, w.created_at
+		FROM wishlists w
+		JOIN books b ON w.book_id = b.id
+		WHERE w.user_id = $1 AND b.deleted_at IS NULL
+		ORDER BY w.created_at DESC
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []entity.WishlistItem
+	for rows.Next() {
+		var item entity.WishlistItem
+		err := rows.Scan(
+			&item.ID, &item.BookID, &item.NamaBarang, &item.Harga, &item.HargaAwal,
+			&item.Stok, &item.GambarBuku, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
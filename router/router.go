@@ -5,32 +5,87 @@ import (
 
 	"github.com/LanangDepok/ebook-store/controller"
 	"github.com/LanangDepok/ebook-store/middleware"
+	"github.com/LanangDepok/ebook-store/service"
 )
 
 type Router struct {
-	authController   *controller.AuthController
-	bookController   *controller.BookController
-	cartController   *controller.CartController
-	orderController  *controller.OrderController
-	uploadController *controller.UploadController
-	authMiddleware   *middleware.AuthMiddleware
+	authController           *controller.AuthController
+	bookController           *controller.BookController
+	bookFileController       *controller.BookFileController
+	previewController        *controller.BookPreviewController
+	variantController        *controller.BookVariantController
+	cartController           *controller.CartController
+	wishlistController       *controller.WishlistController
+	notificationController   *controller.NotificationController
+	recommendationController *controller.RecommendationController
+	orderController          *controller.OrderController
+	downloadController       *controller.DownloadController
+	libraryController        *controller.LibraryController
+	opdsController           *controller.OPDSController
+	progressController       *controller.ReadingProgressController
+	annotationController     *controller.AnnotationController
+	reviewController         *controller.ReviewController
+	categoryController       *controller.CategoryController
+	authorController         *controller.AuthorController
+	publisherController      *controller.PublisherController
+	seriesController         *controller.SeriesController
+	importController         *controller.ImportController
+	exportController         *controller.ExportController
+	uploadController         *controller.UploadController
+	authMiddleware           *middleware.AuthMiddleware
 }
 
 func NewRouter(
 	authController *controller.AuthController,
 	bookController *controller.BookController,
+	bookFileController *controller.BookFileController,
+	previewController *controller.BookPreviewController,
+	variantController *controller.BookVariantController,
 	cartController *controller.CartController,
+	wishlistController *controller.WishlistController,
+	notificationController *controller.NotificationController,
+	recommendationController *controller.RecommendationController,
 	orderController *controller.OrderController,
+	downloadController *controller.DownloadController,
+	libraryController *controller.LibraryController,
+	opdsController *controller.OPDSController,
+	progressController *controller.ReadingProgressController,
+	annotationController *controller.AnnotationController,
+	reviewController *controller.ReviewController,
+	categoryController *controller.CategoryController,
+	authorController *controller.AuthorController,
+	publisherController *controller.PublisherController,
+	seriesController *controller.SeriesController,
+	importController *controller.ImportController,
+	exportController *controller.ExportController,
 	uploadController *controller.UploadController,
 	authMiddleware *middleware.AuthMiddleware,
 ) *Router {
 	return &Router{
-		authController:   authController,
-		bookController:   bookController,
-		cartController:   cartController,
-		orderController:  orderController,
-		uploadController: uploadController,
-		authMiddleware:   authMiddleware,
+		authController:           authController,
+		bookController:           bookController,
+		bookFileController:       bookFileController,
+		previewController:        previewController,
+		variantController:        variantController,
+		cartController:           cartController,
+		wishlistController:       wishlistController,
+		notificationController:   notificationController,
+		recommendationController: recommendationController,
+		orderController:          orderController,
+		downloadController:       downloadController,
+		libraryController:        libraryController,
+		opdsController:           opdsController,
+		progressController:       progressController,
+		annotationController:     annotationController,
+		reviewController:         reviewController,
+		categoryController:       categoryController,
+		authorController:         authorController,
+		publisherController:      publisherController,
+		seriesController:         seriesController,
+		importController:         importController,
+		exportController:         exportController,
+		uploadController:         uploadController,
+		authMiddleware:           authMiddleware,
 	}
 }
 
@@ -67,6 +122,190 @@ func (router *Router) Setup() *http.ServeMux {
 		}
 	})
 
+	mux.HandleFunc("/api/books/isbn/", methodHandler("GET", router.bookController.GetBookByISBN))
+	mux.HandleFunc("/api/books/deleted", methodHandler("GET", router.authMiddleware.RequireAdmin(router.bookController.GetDeletedBooks)))
+	mux.HandleFunc("/api/books/restore", methodHandler("POST", router.authMiddleware.RequireAdmin(router.bookController.RestoreBook)))
+	mux.HandleFunc("/api/books/history", methodHandler("GET", router.authMiddleware.RequireAdmin(router.bookController.GetBookHistory)))
+	mux.HandleFunc("/api/books/rollback", methodHandler("POST", router.authMiddleware.RequireAdmin(router.bookController.RollbackBook)))
+	mux.HandleFunc("/api/books/categories", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.categoryController.SetBookCategories)))
+	mux.HandleFunc("/api/books/contributors", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.authorController.SetBookContributors)))
+	mux.HandleFunc("/api/books/publisher", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.publisherController.SetBookPublisher)))
+	mux.HandleFunc("/api/books/series", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.seriesController.SetBookSeries)))
+
+	// "Customers also bought", served from the precomputed recommendations
+	mux.HandleFunc("/api/books/", methodHandler("GET", router.recommendationController.GetRelatedBooks))
+	mux.HandleFunc("/api/recommendations", methodHandler("GET", router.authMiddleware.RequireAuth(router.recommendationController.GetRecommendations)))
+
+	// Category routes
+	mux.HandleFunc("/api/categories", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.categoryController.GetCategories(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.categoryController.CreateCategory)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/categories/detail", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.categoryController.GetCategoryByID(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAdmin(router.categoryController.UpdateCategory)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.categoryController.DeleteCategory)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Author routes
+	mux.HandleFunc("/api/authors", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authorController.GetAuthors(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.authorController.CreateAuthor)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/authors/detail", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authorController.GetAuthor(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAdmin(router.authorController.UpdateAuthor)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.authorController.DeleteAuthor)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Publisher routes
+	mux.HandleFunc("/api/publishers", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.publisherController.GetPublishers(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.publisherController.CreatePublisher)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/publishers/detail", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.publisherController.GetPublisher(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAdmin(router.publisherController.UpdatePublisher)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.publisherController.DeletePublisher)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Series routes
+	mux.HandleFunc("/api/series", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.seriesController.GetSeriesList(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.seriesController.CreateSeries)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/series/detail", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.seriesController.GetSeries(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAdmin(router.seriesController.UpdateSeries)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.seriesController.DeleteSeries)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/series/cart", methodHandler("POST", router.authMiddleware.RequireAuth(router.seriesController.AddSeriesToCart)))
+
+	// Catalog import routes
+	mux.HandleFunc("/api/imports", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authMiddleware.RequireAdmin(router.importController.GetImportJobs)(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.importController.StartImport)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/imports/detail", methodHandler("GET", router.authMiddleware.RequireAdmin(router.importController.GetImportJob)))
+	mux.HandleFunc("/api/imports/resume", methodHandler("POST", router.authMiddleware.RequireAdmin(router.importController.ResumeImport)))
+
+	// Catalog export route
+	mux.HandleFunc("/api/books/export", methodHandler("GET", router.authMiddleware.RequireAdmin(router.exportController.ExportCatalog)))
+
+	// Book file routes
+	mux.HandleFunc("/api/books/files", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.bookFileController.GetBookFiles(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.bookFileController.UploadBookFile)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.bookFileController.DeleteBookFile)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/books/files/download", methodHandler("GET", router.authMiddleware.RequireAuth(router.bookFileController.DownloadBookFile)))
+	mux.HandleFunc("/api/books/files/stream", methodHandler("GET", router.authMiddleware.RequireAuth(router.bookFileController.StreamBookFile)))
+	mux.HandleFunc("/api/books/files/link", methodHandler("POST", router.authMiddleware.RequireAuth(router.bookFileController.CreateDownloadLink)))
+	mux.HandleFunc("/api/books/files/signed", methodHandler("GET", router.bookFileController.DownloadSignedFile))
+
+	// Book preview routes; previews are free, so downloading needs no login
+	mux.HandleFunc("/api/books/preview", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.previewController.DownloadPreview(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.previewController.AttachPreview)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.previewController.DeletePreview)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/books/previews", methodHandler("GET", router.previewController.GetPreviews))
+	mux.HandleFunc("/api/books/preview/generate", methodHandler("POST", router.authMiddleware.RequireAdmin(router.previewController.GeneratePreview)))
+
+	// Book format routes
+	mux.HandleFunc("/api/books/variants", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.variantController.GetVariants(w, r)
+		case "POST":
+			router.authMiddleware.RequireAdmin(router.variantController.CreateVariant)(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAdmin(router.variantController.UpdateVariant)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAdmin(router.variantController.DeleteVariant)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	// Cart routes
 	mux.HandleFunc("/api/cart", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -92,6 +331,24 @@ func (router *Router) Setup() *http.ServeMux {
 		}
 	})
 
+	// Wishlist routes
+	mux.HandleFunc("/api/wishlist", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authMiddleware.RequireAuth(router.wishlistController.GetWishlist)(w, r)
+		case "POST":
+			router.authMiddleware.RequireAuth(router.wishlistController.AddToWishlist)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAuth(router.wishlistController.RemoveFromWishlist)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Notification routes
+	mux.HandleFunc("/api/notifications", methodHandler("GET", router.authMiddleware.RequireAuth(router.notificationController.GetNotifications)))
+	mux.HandleFunc("/api/notifications/read", methodHandler("POST", router.authMiddleware.RequireAuth(router.notificationController.MarkRead)))
+
 	// Order routes
 	mux.HandleFunc("/api/orders", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -105,6 +362,85 @@ func (router *Router) Setup() *http.ServeMux {
 	})
 
 	mux.HandleFunc("/api/orders/detail", methodHandler("GET", router.authMiddleware.RequireAuth(router.orderController.GetOrderDetail)))
+	mux.HandleFunc("/api/orders/status", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.orderController.UpdateOrderStatus)))
+
+	// Library routes
+	mux.HandleFunc("/api/library", methodHandler("GET", router.authMiddleware.RequireAuth(router.libraryController.GetLibrary)))
+
+	// Reading progress routes
+	mux.HandleFunc("/api/progress", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authMiddleware.RequireAuth(router.progressController.GetProgress)(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAuth(router.progressController.SyncProgress)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Annotation routes
+	mux.HandleFunc("/api/annotations", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.authMiddleware.RequireAuth(router.annotationController.GetAnnotations)(w, r)
+		case "POST":
+			router.authMiddleware.RequireAuth(router.annotationController.CreateAnnotation)(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAuth(router.annotationController.UpdateAnnotation)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAuth(router.annotationController.DeleteAnnotation)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/annotations/export", methodHandler("GET", router.authMiddleware.RequireAuth(router.annotationController.ExportAnnotations)))
+
+	// Review routes
+	mux.HandleFunc("/api/reviews", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			router.reviewController.GetReviews(w, r)
+		case "POST":
+			router.authMiddleware.RequireAuth(router.reviewController.CreateReview)(w, r)
+		case "PUT":
+			router.authMiddleware.RequireAuth(router.reviewController.UpdateReview)(w, r)
+		case "DELETE":
+			router.authMiddleware.RequireAuth(router.reviewController.DeleteReview)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/reviews/helpful", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST", "DELETE":
+			router.authMiddleware.RequireAuth(router.reviewController.HelpfulVote)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/api/reviews/moderation", methodHandler("GET", router.authMiddleware.RequireAdmin(router.reviewController.GetModerationQueue)))
+	mux.HandleFunc("/api/reviews/moderate", methodHandler("PUT", router.authMiddleware.RequireAdmin(router.reviewController.ModerateReview)))
+
+	// OPDS catalog routes (1.2 Atom and 2.0 JSON)
+	mux.HandleFunc("/opds", methodHandler("GET", router.opdsController.Root))
+	mux.HandleFunc("/opds/opensearch.xml", methodHandler("GET", router.opdsController.OpenSearch))
+	mux.HandleFunc("/opds/new", methodHandler("GET", router.opdsController.Feed(service.OPDSFeedNew)))
+	mux.HandleFunc("/opds/bestsellers", methodHandler("GET", router.opdsController.Feed(service.OPDSFeedBestsellers)))
+	mux.HandleFunc("/opds/search", methodHandler("GET", router.opdsController.Feed(service.OPDSFeedSearch)))
+	mux.HandleFunc("/opds/shelf", methodHandler("GET", router.authMiddleware.RequireReaderAuth(router.opdsController.Feed(service.OPDSFeedShelf))))
+	mux.HandleFunc("/opds/v2", methodHandler("GET", router.opdsController.RootV2))
+	mux.HandleFunc("/opds/v2/new", methodHandler("GET", router.opdsController.FeedV2(service.OPDSFeedNew)))
+	mux.HandleFunc("/opds/v2/bestsellers", methodHandler("GET", router.opdsController.FeedV2(service.OPDSFeedBestsellers)))
+	mux.HandleFunc("/opds/v2/search", methodHandler("GET", router.opdsController.FeedV2(service.OPDSFeedSearch)))
+	mux.HandleFunc("/opds/v2/shelf", methodHandler("GET", router.authMiddleware.RequireReaderAuth(router.opdsController.FeedV2(service.OPDSFeedShelf))))
+
+	// Download routes
+	mux.HandleFunc("/api/downloads", methodHandler("GET", router.authMiddleware.RequireAuth(router.downloadController.GetDownloadHistory)))
+	mux.HandleFunc("/api/downloads/reset", methodHandler("POST", router.authMiddleware.RequireAdmin(router.downloadController.ResetDownloads)))
 
 	// Health check
 	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// Export formats for annotations
+const (
+	AnnotationExportMarkdown = "markdown"
+	AnnotationExportJSON     = "json"
+)
+
+const defaultHighlightColor = "yellow"
+
+var annotationColors = map[string]bool{
+	"yellow": true,
+	"green":  true,
+	"blue":   true,
+	"pink":   true,
+	"purple": true,
+}
+
+type AnnotationService interface {
+	SyncAnnotations(userID, bookID int, since *int64) (*model.AnnotationSyncResponse, error)
+	CreateAnnotation(userID int, req model.CreateAnnotationRequest) (*entity.Annotation, error)
+	UpdateAnnotation(userID, id int, req model.UpdateAnnotationRequest) (*entity.Annotation, error)
+	DeleteAnnotation(userID, id int) error
+	ExportAnnotations(userID, bookID int, format string) ([]byte, string, error)
+}
+
+type annotationService struct {
+	annotationRepo repository.AnnotationRepository
+	orderRepo      repository.OrderRepository
+}
+
+func NewAnnotationService(annotationRepo repository.AnnotationRepository, orderRepo repository.OrderRepository) AnnotationService {
+	return &annotationService{
+		annotationRepo: annotationRepo,
+		orderRepo:      orderRepo,
+	}
+}
+
+// SyncAnnotations returns annotations changed after the since cursor, or
+// every live annotation when it is nil. The cursor is the highest change_seq
+// returned. Every write takes the next value of one sequence, so the cursor
+// does not depend on timestamps taken when the writing transaction started.
+func (s *annotationService) SyncAnnotations(userID, bookID int, since *int64) (*model.AnnotationSyncResponse, error) {
+	if bookID > 0 {
+		if err := s.checkOwnership(userID, bookID); err != nil {
+			return nil, err
+		}
+	}
+
+	annotations, err := s.annotationRepo.FindByUserID(userID, bookID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get annotations: %v", err)
+	}
+
+	resp := &model.AnnotationSyncResponse{Annotations: annotations}
+	if since != nil {
+		resp.Cursor = *since
+	}
+	for _, annotation := range annotations {
+		if annotation.ChangeSeq > resp.Cursor {
+			resp.Cursor = annotation.ChangeSeq
+		}
+	}
+
+	if resp.Annotations == nil {
+		resp.Annotations = []entity.Annotation{}
+	}
+	return resp, nil
+}
+
+func (s *annotationService) CreateAnnotation(userID int, req model.CreateAnnotationRequest) (*entity.Annotation, error) {
+	if req.BookID <= 0 {
+		return nil, fmt.Errorf("book_id is required")
+	}
+
+	annotation := &entity.Annotation{
+		UserID: userID,
+		BookID: req.BookID,
+		Type:   req.Type,
+		CFI:    strings.TrimSpace(req.CFI),
+		Color:  strings.ToLower(strings.TrimSpace(req.Color)),
+		Text:   req.Text,
+		Note:   strings.TrimSpace(req.Note),
+	}
+	if err := validateAnnotation(annotation); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwnership(userID, req.BookID); err != nil {
+		return nil, err
+	}
+
+	if err := s.annotationRepo.Create(annotation); err != nil {
+		return nil, fmt.Errorf("failed to create annotation: %v", err)
+	}
+	return annotation, nil
+}
+
+func (s *annotationService) UpdateAnnotation(userID, id int, req model.UpdateAnnotationRequest) (*entity.Annotation, error) {
+	annotation, err := s.findOwnAnnotation(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CFI != nil {
+		annotation.CFI = strings.TrimSpace(*req.CFI)
+	}
+	if req.Color != nil {
+		annotation.Color = strings.ToLower(strings.TrimSpace(*req.Color))
+	}
+	if req.Text != nil {
+		annotation.Text = *req.Text
+	}
+	if req.Note != nil {
+		annotation.Note = strings.TrimSpace(*req.Note)
+	}
+	if err := validateAnnotation(annotation); err != nil {
+		return nil, err
+	}
+
+	if err := s.annotationRepo.Update(annotation); err != nil {
+		return nil, fmt.Errorf("failed to update annotation: %v", err)
+	}
+	return annotation, nil
+}
+
+func (s *annotationService) DeleteAnnotation(userID, id int) error {
+	if _, err := s.findOwnAnnotation(userID, id); err != nil {
+		return err
+	}
+
+	if err := s.annotationRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete annotation: %v", err)
+	}
+	return nil
+}
+
+// ExportAnnotations renders the user's live annotations, optionally for one
+// book, and returns the document with its content type
+func (s *annotationService) ExportAnnotations(userID, bookID int, format string) ([]byte, string, error) {
+	if format == "" {
+		format = AnnotationExportMarkdown
+	}
+	if format != AnnotationExportMarkdown && format != AnnotationExportJSON {
+		return nil, "", fmt.Errorf("format must be one of: %s, %s", AnnotationExportMarkdown, AnnotationExportJSON)
+	}
+
+	sync, err := s.SyncAnnotations(userID, bookID, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == AnnotationExportJSON {
+		data, err := json.MarshalIndent(sync.Annotations, "", "  ")
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to export annotations: %v", err)
+		}
+		return data, "application/json", nil
+	}
+	return annotationsMarkdown(sync.Annotations), "text/markdown; charset=utf-8", nil
+}
+
+func (s *annotationService) findOwnAnnotation(userID, id int) (*entity.Annotation, error) {
+	annotation, err := s.annotationRepo.FindByID(id)
+	if err != nil || annotation.UserID != userID {
+		return nil, ErrAnnotationNotFound
+	}
+	return annotation, nil
+}
+
+func (s *annotationService) checkOwnership(userID, bookID int) error {
+	owned, err := s.orderRepo.HasPurchasedBook(userID, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to check purchase: %v", err)
+	}
+	if !owned {
+		return ErrBookNotPurchased
+	}
+	return nil
+}
+
+func validateAnnotation(annotation *entity.Annotation) error {
+	if !isEPUBCFI(annotation.CFI) {
+		return fmt.Errorf("cfi must be an EPUB CFI, e.g. epubcfi(/6/4!/4/2,/1:0,/1:24)")
+	}
+
+	switch annotation.Type {
+	case entity.AnnotationHighlight:
+		if annotation.Color == "" {
+			annotation.Color = defaultHighlightColor
+		}
+		if !annotationColors[annotation.Color] {
+			return fmt.Errorf("color must be one of: yellow, green, blue, pink, purple")
+		}
+	case entity.AnnotationNote:
+		if annotation.Note == "" {
+			return fmt.Errorf("note is required for note annotations")
+		}
+	case entity.AnnotationBookmark:
+	default:
+		return fmt.Errorf("type must be one of: %s, %s, %s",
+			entity.AnnotationHighlight, entity.AnnotationBookmark, entity.AnnotationNote)
+	}
+	return nil
+}
+
+// annotationsMarkdown renders the annotations as a Markdown document, one section per book
+func annotationsMarkdown(annotations []entity.Annotation) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Annotations\n")
+
+	currentBook := 0
+	for _, annotation := range sortedByBook(annotations) {
+		if annotation.BookID != currentBook {
+			currentBook = annotation.BookID
+			fmt.Fprintf(&buf, "\n## %s\n\n", annotation.NamaBarang)
+		}
+
+		date := annotation.CreatedAt.Format("2006-01-02")
+		switch annotation.Type {
+		case entity.AnnotationBookmark:
+			fmt.Fprintf(&buf, "- Bookmark (%s) `%s`\n", date, annotation.CFI)
+		default:
+			if annotation.Text != "" {
+				fmt.Fprintf(&buf, "> %s\n", strings.ReplaceAll(strings.TrimSpace(annotation.Text), "\n", "\n> "))
+				buf.WriteString(">\n")
+			}
+			fmt.Fprintf(&buf, "> — %s (%s) `%s`\n", annotation.Type, date, annotation.CFI)
+			if annotation.Note != "" {
+				fmt.Fprintf(&buf, "\n%s\n", annotation.Note)
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// sortedByBook keeps each book's annotations together, preserving the
+// updated_at order within a book
+func sortedByBook(annotations []entity.Annotation) []entity.Annotation {
+	var order []int
+	groups := map[int][]entity.Annotation{}
+	for _, annotation := range annotations {
+		if _, ok := groups[annotation.BookID]; !ok {
+			order = append(order, annotation.BookID)
+		}
+		groups[annotation.BookID] = append(groups[annotation.BookID], annotation)
+	}
+
+	sorted := make([]entity.Annotation, 0, len(annotations))
+	for _, bookID := range order {
+		sorted = append(sorted, groups[bookID]...)
+	}
+	return sorted
+}
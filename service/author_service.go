@@ -0,0 +1,194 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type AuthorService interface {
+	CreateAuthor(req model.AuthorRequest) (*entity.Author, error)
+	GetAuthors(keyword string) ([]entity.Author, error)
+	GetAuthor(id int, slug string) (*entity.Author, error)
+	UpdateAuthor(id int, req model.AuthorRequest) (*entity.Author, error)
+	DeleteAuthor(id int) error
+	SetBookContributors(bookID int, req model.SetBookContributorsRequest) ([]entity.BookContributor, error)
+}
+
+type authorService struct {
+	authorRepo repository.AuthorRepository
+	bookRepo   repository.BookRepository
+}
+
+func NewAuthorService(authorRepo repository.AuthorRepository, bookRepo repository.BookRepository) AuthorService {
+	return &authorService{
+		authorRepo: authorRepo,
+		bookRepo:   bookRepo,
+	}
+}
+
+func (s *authorService) CreateAuthor(req model.AuthorRequest) (*entity.Author, error) {
+	author := &entity.Author{}
+	if err := applyAuthorRequest(author, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorRepo.Create(author); err != nil {
+		return nil, fmt.Errorf("failed to create author: %v", err)
+	}
+	return author, nil
+}
+
+func (s *authorService) GetAuthors(keyword string) ([]entity.Author, error) {
+	authors, err := s.authorRepo.FindAll(strings.TrimSpace(keyword))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authors: %v", err)
+	}
+
+	if authors == nil {
+		authors = []entity.Author{}
+	}
+	return authors, nil
+}
+
+// GetAuthor returns the author page: the author, looked up by ID or slug,
+// with every book they are credited on
+func (s *authorService) GetAuthor(id int, slug string) (*entity.Author, error) {
+	var author *entity.Author
+	var err error
+	if slug != "" {
+		author, err = s.authorRepo.FindBySlug(slug)
+	} else {
+		author, err = s.authorRepo.FindByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	author.Books, err = s.authorRepo.FindBooks(author.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author books: %v", err)
+	}
+	if author.Books == nil {
+		author.Books = []entity.AuthorBook{}
+	}
+	return author, nil
+}
+
+func (s *authorService) UpdateAuthor(id int, req model.AuthorRequest) (*entity.Author, error) {
+	author, err := s.authorRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyAuthorRequest(author, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorRepo.Update(author); err != nil {
+		return nil, fmt.Errorf("failed to update author: %v", err)
+	}
+	return author, nil
+}
+
+func (s *authorService) DeleteAuthor(id int) error {
+	return s.authorRepo.Delete(id)
+}
+
+// SetBookContributors replaces the credits of a book. The author credits
+// also become the book's penulis text.
+func (s *authorService) SetBookContributors(bookID int, req model.SetBookContributorsRequest) ([]entity.BookContributor, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	contributors := make([]entity.BookContributor, 0, len(req.Contributors))
+	for _, c := range req.Contributors {
+		if !isContributorRole(c.Role) {
+			return nil, fmt.Errorf("role must be one of: %s", strings.Join(entity.ContributorRoles, ", "))
+		}
+		if _, err := s.authorRepo.FindByID(c.AuthorID); err != nil {
+			return nil, fmt.Errorf("author %d not found", c.AuthorID)
+		}
+		contributors = append(contributors, entity.BookContributor{AuthorID: c.AuthorID, Role: c.Role})
+	}
+
+	if err := s.authorRepo.SetBookContributors(bookID, contributors); err != nil {
+		return nil, fmt.Errorf("failed to set book contributors: %v", err)
+	}
+
+	credits, err := s.authorRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book contributors: %v", err)
+	}
+	if credits == nil {
+		credits = []entity.BookContributor{}
+	}
+	return credits, nil
+}
+
+func applyAuthorRequest(author *entity.Author, req model.AuthorRequest) error {
+	req.Nama = strings.TrimSpace(req.Nama)
+	if req.Nama == "" {
+		return fmt.Errorf("nama is required")
+	}
+
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = contributorSlug("author", req.Nama)
+	}
+	if !validSlug.MatchString(slug) {
+		return fmt.Errorf("slug may only contain lowercase letters, digits and dashes")
+	}
+
+	author.Nama = req.Nama
+	author.Slug = slug
+	author.Biografi = strings.TrimSpace(req.Biografi)
+	return nil
+}
+
+func isContributorRole(role string) bool {
+	for _, r := range entity.ContributorRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// contributorSlug derives the slug used to match authors and publishers by
+// name. Names without any latin letters or digits get a hash based slug.
+// The same rule is used by the backfill migration in config/database.go.
+func contributorSlug(prefix, name string) string {
+	name = strings.TrimSpace(name)
+	if slug := slugify(name); slug != "" {
+		return slug
+	}
+	sum := md5.Sum([]byte(name))
+	return prefix + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+// authorsFromText turns a comma separated penulis value into authors
+func authorsFromText(penulis string) []entity.Author {
+	var authors []entity.Author
+	for _, name := range splitAuthors(penulis) {
+		authors = append(authors, entity.Author{Nama: name, Slug: contributorSlug("author", name)})
+	}
+	return authors
+}
+
+// joinContributors returns the names credited in role, comma separated
+func joinContributors(contributors []entity.BookContributor, role string) string {
+	var names []string
+	for _, c := range contributors {
+		if c.Role == role {
+			names = append(names, c.Nama)
+		}
+	}
+	return strings.Join(names, ", ")
+}
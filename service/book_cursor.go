@@ -0,0 +1,60 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// bookCursor is the decoded form of the opaque next_cursor token. The sort
+// it was issued for is included so a cursor cannot be replayed against a
+// different ordering.
+type bookCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeBookCursor(filter repository.BookFilter, last entity.Book) string {
+	cursor := bookCursor{Sort: filter.Sort, Desc: filter.Desc, ID: last.ID}
+	switch filter.Sort {
+	case repository.BookSortHarga:
+		cursor.Value = strconv.Itoa(last.Harga)
+	case repository.BookSortTerjual:
+		cursor.Value = strconv.Itoa(last.Terjual)
+	case repository.BookSortCreatedAt:
+		cursor.Value = last.CreatedAt.Format("2006-01-02 15:04:05.999999")
+	case repository.BookSortNama:
+		cursor.Value = last.NamaBarang
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBookCursor(token string, filter repository.BookFilter) (*repository.BookCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor bookCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+		return nil, fmt.Errorf("cursor was issued for a different sort order")
+	}
+
+	return &repository.BookCursor{Value: cursor.Value, ID: cursor.ID}, nil
+}
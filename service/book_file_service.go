@@ -0,0 +1,477 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// MaxBookFileSize is the largest ebook file an admin may upload (200MB)
+const MaxBookFileSize = 200 << 20
+
+var bookFileContentTypes = map[string]string{
+	"epub": "application/epub+zip",
+	"pdf":  "application/pdf",
+	"mobi": "application/x-mobipocket-ebook",
+}
+
+// variantFileFormats lists the book files each format a book is sold in
+// gives access to. MOBI is the Kindle edition of the EPUB. Audiobook and
+// print purchases come with no ebook files.
+var variantFileFormats = map[string][]string{
+	entity.VariantFormatEPUB: {"epub", "mobi"},
+	entity.VariantFormatPDF:  {"pdf"},
+}
+
+// unlocksBookFile reports whether buying a book in variantFormat gives access
+// to its files in fileFormat. Order items from before books had formats
+// carry no format and keep access to every file.
+func unlocksBookFile(variantFormat, fileFormat string) bool {
+	if variantFormat == "" {
+		return true
+	}
+	for _, format := range variantFileFormats[variantFormat] {
+		if format == fileFormat {
+			return true
+		}
+	}
+	return false
+}
+
+type BookFileService interface {
+	UploadBookFile(bookID int, file multipart.File, header *multipart.FileHeader) (*entity.BookFile, error)
+	GetBookFiles(bookID int) ([]entity.BookFile, error)
+	DeleteBookFile(id int) error
+	OpenForDownload(userID, fileID int) (*BookFileDownload, error)
+	CreateDownloadLink(userID, fileID int) (*model.DownloadLinkResponse, error)
+	OpenSignedDownload(values url.Values) (*BookFileDownload, error)
+	OpenForStream(userID, fileID int) (*BookFileDownload, error)
+	RecordDownload(download *BookFileDownload, ipAddress, userAgent string, bytes int64, partial bool) error
+	CancelDownload(download *BookFileDownload) error
+}
+
+type bookFileService struct {
+	fileRepo       repository.BookFileRepository
+	bookRepo       repository.BookRepository
+	orderRepo      repository.OrderRepository
+	downloadRepo   repository.DownloadRepository
+	userRepo       repository.UserRepository
+	previewService BookPreviewService
+	signer         DownloadSigner
+	storeDir       string
+	baseURL        string
+	maxDownloads   int
+}
+
+func NewBookFileService(fileRepo repository.BookFileRepository, bookRepo repository.BookRepository, orderRepo repository.OrderRepository, downloadRepo repository.DownloadRepository, userRepo repository.UserRepository, previewService BookPreviewService, signer DownloadSigner, storeDir, baseURL string, maxDownloads int) BookFileService {
+	return &bookFileService{
+		fileRepo:       fileRepo,
+		bookRepo:       bookRepo,
+		orderRepo:      orderRepo,
+		downloadRepo:   downloadRepo,
+		userRepo:       userRepo,
+		previewService: previewService,
+		signer:         signer,
+		storeDir:       storeDir,
+		baseURL:        baseURL,
+		maxDownloads:   maxDownloads,
+	}
+}
+
+func (s *bookFileService) UploadBookFile(bookID int, file multipart.File, header *multipart.FileHeader) (*entity.BookFile, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	// Validate file size
+	if header.Size > MaxBookFileSize {
+		return nil, fmt.Errorf("file size exceeds 200MB limit")
+	}
+
+	// Validate file type by extension
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	contentType, ok := bookFileContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid file type. Only EPUB, PDF, and MOBI are allowed")
+	}
+
+	if err := os.MkdirAll(s.storeDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	// Generate unique filename
+	filename := fmt.Sprintf("%d_%d_%s.%s", bookID, time.Now().UnixNano(), generateRandomString(8), format)
+	filePath := filepath.Join(s.storeDir, filename)
+
+	dst, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %v", err)
+	}
+	defer dst.Close()
+
+	size, err := io.Copy(dst, file)
+	if err != nil {
+		os.Remove(filePath) // Clean up on error
+		return nil, fmt.Errorf("failed to save file: %v", err)
+	}
+
+	bookFile := &entity.BookFile{
+		BookID:       bookID,
+		Format:       format,
+		FileName:     filename,
+		OriginalName: filepath.Base(header.Filename),
+		ContentType:  contentType,
+		Size:         size,
+	}
+
+	if err := s.fileRepo.Create(bookFile); err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to save book file: %v", err)
+	}
+
+	// The preview is cut from the master file here, so the full book never
+	// has to leave the server to produce one
+	if _, err := s.previewService.GenerateOnUpload(bookFile); err != nil {
+		log.Printf("Failed to generate preview for book %d: %v", bookID, err)
+	}
+
+	return bookFile, nil
+}
+
+func (s *bookFileService) GetBookFiles(bookID int) ([]entity.BookFile, error) {
+	files, err := s.fileRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book files: %v", err)
+	}
+	return files, nil
+}
+
+func (s *bookFileService) DeleteBookFile(id int) error {
+	file, err := s.fileRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.fileRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete book file: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(s.storeDir, file.FileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete stored file: %v", err)
+	}
+
+	return nil
+}
+
+// BookFileDownload is an opened book file together with the purchase it is
+// charged against and, for downloads, its reserved ledger entry. Watermark is
+// set when Content is a copy personalised for the buyer, Size being its
+// length. The caller must call Close.
+type BookFileDownload struct {
+	UserID    int
+	File      *entity.BookFile
+	OrderItem *entity.OrderItem
+	Content   *os.File
+	Size      int64
+	Watermark *Watermark
+	temporary bool
+	record    *entity.Download
+}
+
+// Close closes the content and removes a personalised copy
+func (d *BookFileDownload) Close() error {
+	err := d.Content.Close()
+	if d.temporary {
+		os.Remove(d.Content.Name())
+	}
+	return err
+}
+
+// OpenForDownload opens the stored file after verifying that the user has a
+// paid order for the book in a format that includes the file, with downloads
+// remaining. The download is reserved in the ledger before it is returned;
+// the caller settles it with RecordDownload or CancelDownload.
+func (s *bookFileService) OpenForDownload(userID, fileID int) (*BookFileDownload, error) {
+	bookFile, items, err := s.purchasedFile(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	download := &BookFileDownload{
+		UserID: userID,
+		File:   bookFile,
+	}
+	if err := s.reserveDownload(download, items); err != nil {
+		return nil, err
+	}
+
+	if err := s.openDownload(download); err != nil {
+		if cancelErr := s.CancelDownload(download); cancelErr != nil {
+			log.Printf("Failed to cancel download of file %d: %v", bookFile.ID, cancelErr)
+		}
+		return nil, err
+	}
+	return download, nil
+}
+
+// reserveDownload charges the download to the first purchase of the file
+// that still has downloads left
+func (s *bookFileService) reserveDownload(download *BookFileDownload, items []entity.OrderItem) error {
+	for i := range items {
+		record := &entity.Download{
+			UserID:      download.UserID,
+			OrderItemID: items[i].ID,
+			BookFileID:  &download.File.ID,
+		}
+		reserved, err := s.downloadRepo.Reserve(record, s.maxDownloads)
+		if err != nil {
+			return fmt.Errorf("failed to reserve download: %v", err)
+		}
+		if reserved {
+			download.OrderItem = &items[i]
+			download.record = record
+			return nil
+		}
+	}
+	return ErrDownloadLimitExceeded
+}
+
+// openDownload opens the stored file of a reserved download
+func (s *bookFileService) openDownload(download *BookFileDownload) error {
+	bookFile := download.File
+	f, err := os.Open(filepath.Join(s.storeDir, bookFile.FileName))
+	if err != nil {
+		return fmt.Errorf("stored file is missing")
+	}
+	download.Content = f
+	download.Size = bookFile.Size
+
+	// EPUBs are stamped with the buyer identity as social DRM
+	if bookFile.Format == "epub" {
+		user, err := s.userRepo.FindByID(download.UserID)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("user not found")
+		}
+		download.Watermark = &Watermark{
+			Username: user.Username,
+			Email:    user.Email,
+			OrderID:  download.OrderItem.OrderID,
+			IssuedAt: time.Now(),
+		}
+
+		if err := s.watermark(download); err != nil {
+			f.Close()
+			log.Printf("Failed to watermark file %d: %v", bookFile.ID, err)
+			return ErrWatermarkFailed
+		}
+	}
+	return nil
+}
+
+// watermark replaces the download content with a personalised copy written
+// to a temporary file, so a broken master is reported before anything is
+// sent to the buyer
+func (s *bookFileService) watermark(download *BookFileDownload) error {
+	tmp, err := os.CreateTemp("", "ebook-watermark-*.epub")
+	if err != nil {
+		return err
+	}
+
+	err = WatermarkEPUB(tmp, download.Content, download.File.Size, *download.Watermark)
+	if err == nil {
+		download.Size, err = tmp.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	download.Content.Close()
+	download.Content = tmp
+	download.temporary = true
+	return nil
+}
+
+// CreateDownloadLink mints a signed, expiring URL that downloads the file
+// without a bearer token
+func (s *bookFileService) CreateDownloadLink(userID, fileID int) (*model.DownloadLinkResponse, error) {
+	bookFile, _, err := s.findDownloadable(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.signer.TTL()).Truncate(time.Second)
+	values := s.signer.Sign(SignedDownload{
+		BookID:    bookFile.BookID,
+		FileID:    bookFile.ID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+
+	return &model.DownloadLinkResponse{
+		URL:       fmt.Sprintf("%s/api/books/files/signed?%s", s.baseURL, values.Encode()),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// OpenSignedDownload verifies a signed link and opens the file it grants.
+// Ownership is checked again so refunded purchases stop working immediately.
+func (s *bookFileService) OpenSignedDownload(values url.Values) (*BookFileDownload, error) {
+	claims, err := s.signer.Verify(values)
+	if err != nil {
+		return nil, err
+	}
+
+	download, err := s.OpenForDownload(claims.UserID, claims.FileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if download.File.BookID != claims.BookID {
+		if err := s.CancelDownload(download); err != nil {
+			log.Printf("Failed to cancel download of file %d: %v", download.File.ID, err)
+		}
+		download.Close()
+		return nil, ErrInvalidDownloadLink
+	}
+
+	return download, nil
+}
+
+// OpenForStream opens a purchased file for in-browser reading. Streams are
+// fetched in many range requests, so they are not counted against the download
+// limit; formats that are watermarked per download cannot be streamed, as the
+// reader would otherwise receive the unmarked master copy.
+func (s *bookFileService) OpenForStream(userID, fileID int) (*BookFileDownload, error) {
+	bookFile, err := s.fileRepo.FindByID(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if bookFile.Format == "epub" {
+		return nil, ErrStreamNotSupported
+	}
+
+	items, err := s.orderRepo.FindPurchasedItems(userID, bookFile.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check purchase: %v", err)
+	}
+	if _, err := purchasesOfFile(items, bookFile); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(s.storeDir, bookFile.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("stored file is missing")
+	}
+
+	return &BookFileDownload{
+		UserID:  userID,
+		File:    bookFile,
+		Content: f,
+		Size:    bookFile.Size,
+	}, nil
+}
+
+// RecordDownload settles a reserved download with what was sent. Partial
+// responses are kept for auditing and counted once the bytes sent for the
+// file since it was last completed reach its size, so a download resumed over
+// several range requests uses up the limit once, as does a single range
+// covering the file.
+func (s *bookFileService) RecordDownload(download *BookFileDownload, ipAddress, userAgent string, bytes int64, partial bool) error {
+	record := download.record
+	record.IPAddress = ipAddress
+	record.UserAgent = userAgent
+	record.Bytes = bytes
+	record.Partial = partial
+
+	if err := s.downloadRepo.Finish(record, download.File.Size); err != nil {
+		return fmt.Errorf("failed to record download: %v", err)
+	}
+	return nil
+}
+
+// CancelDownload releases a reserved download that sent no content
+func (s *bookFileService) CancelDownload(download *BookFileDownload) error {
+	if err := s.downloadRepo.Cancel(download.record.ID); err != nil {
+		return fmt.Errorf("failed to cancel download: %v", err)
+	}
+	return nil
+}
+
+// findDownloadable returns the file together with the first purchase that
+// includes it and still has downloads left
+func (s *bookFileService) findDownloadable(userID, fileID int) (*entity.BookFile, *entity.OrderItem, error) {
+	bookFile, items, err := s.purchasedFile(userID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range items {
+		if s.maxDownloads <= 0 {
+			return bookFile, &items[i], nil
+		}
+
+		used, err := s.downloadRepo.CountByOrderItem(items[i].ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to count downloads: %v", err)
+		}
+		if used < s.maxDownloads {
+			return bookFile, &items[i], nil
+		}
+	}
+
+	return nil, nil, ErrDownloadLimitExceeded
+}
+
+// purchasedFile returns the file together with the user's purchases that
+// include it
+func (s *bookFileService) purchasedFile(userID, fileID int) (*entity.BookFile, []entity.OrderItem, error) {
+	bookFile, err := s.fileRepo.FindByID(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items, err := s.orderRepo.FindPurchasedItems(userID, bookFile.BookID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check purchase: %v", err)
+	}
+	items, err = purchasesOfFile(items, bookFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bookFile, items, nil
+}
+
+// purchasesOfFile keeps the purchased items whose format includes the file
+func purchasesOfFile(items []entity.OrderItem, bookFile *entity.BookFile) ([]entity.OrderItem, error) {
+	if len(items) == 0 {
+		return nil, ErrBookNotPurchased
+	}
+
+	var matching []entity.OrderItem
+	for _, item := range items {
+		if unlocksBookFile(item.Format, bookFile.Format) {
+			matching = append(matching, item)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, ErrFormatNotPurchased
+	}
+	return matching, nil
+}
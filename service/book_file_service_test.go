@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LanangDepok/ebook-store/entity"
+)
+
+func TestPurchasesOfFile(t *testing.T) {
+	legacy := entity.OrderItem{ID: 1}
+	epub := entity.OrderItem{ID: 2, Format: entity.VariantFormatEPUB}
+	pdf := entity.OrderItem{ID: 3, Format: entity.VariantFormatPDF}
+	printed := entity.OrderItem{ID: 4, Format: entity.VariantFormatPrint}
+
+	tests := []struct {
+		name    string
+		items   []entity.OrderItem
+		format  string
+		wantIDs []int
+		wantErr error
+	}{
+		{name: "legacy purchase unlocks epub", items: []entity.OrderItem{legacy}, format: "epub", wantIDs: []int{1}},
+		{name: "legacy purchase unlocks pdf", items: []entity.OrderItem{legacy}, format: "pdf", wantIDs: []int{1}},
+		{name: "legacy purchase unlocks mobi", items: []entity.OrderItem{legacy}, format: "mobi", wantIDs: []int{1}},
+		{name: "epub unlocks mobi", items: []entity.OrderItem{epub}, format: "mobi", wantIDs: []int{2}},
+		{name: "epub does not unlock pdf", items: []entity.OrderItem{epub}, format: "pdf", wantErr: ErrFormatNotPurchased},
+		{name: "print unlocks no files", items: []entity.OrderItem{printed}, format: "epub", wantErr: ErrFormatNotPurchased},
+		{name: "only matching purchases are kept", items: []entity.OrderItem{epub, pdf, legacy}, format: "pdf", wantIDs: []int{3, 1}},
+		{name: "not purchased", format: "epub", wantErr: ErrBookNotPurchased},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := purchasesOfFile(tt.items, &entity.BookFile{Format: tt.format})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("purchasesOfFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("purchasesOfFile() = %d items, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, item := range got {
+				if item.ID != tt.wantIDs[i] {
+					t.Errorf("item %d has ID %d, want %d", i, item.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPurchasedFile(t *testing.T) {
+	// A legacy purchase shows up in the library as an empty format
+	purchased := []string{""}
+	for _, format := range []string{"epub", "pdf", "mobi"} {
+		if !purchasedFile(purchased, format) {
+			t.Errorf("legacy purchase does not include %s files", format)
+		}
+	}
+
+	if purchasedFile([]string{entity.VariantFormatAudiobook}, "epub") {
+		t.Errorf("audiobook purchase includes epub files")
+	}
+}
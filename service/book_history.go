@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+)
+
+// GetBookHistory lists the recorded versions of a book, newest first.
+// Deleted books keep their history.
+func (s *bookService) GetBookHistory(id int) ([]entity.BookVersion, error) {
+	versions, err := s.historyRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book history: %v", err)
+	}
+
+	if versions == nil {
+		versions = []entity.BookVersion{}
+	}
+	return versions, nil
+}
+
+// RollbackBook restores the fields saved in an earlier version. Stok and
+// terjual are left as they are: they move with every sale, so an old value
+// would be wrong. The price is kept too when the book is sold in several
+// formats, each with its own price. The rollback itself is recorded as a new
+// version.
+func (s *bookService) RollbackBook(userID, id, version int) (*entity.Book, error) {
+	target, err := s.historyRepo.FindVersion(id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book version: %v", err)
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%w: version %d of book %d", ErrVersionNotFound, version, id)
+	}
+
+	current, err := s.repo.FindByID(id)
+	if err != nil {
+		if err.Error() == "book not found" {
+			return nil, ErrBookNotFound
+		}
+		return nil, fmt.Errorf("failed to get book: %v", err)
+	}
+
+	snapshot := target.Snapshot
+	req := model.UpdateBookRequest{
+		NamaBarang: snapshot.NamaBarang,
+		Stok:       current.Stok,
+		Terjual:    current.Terjual,
+		Harga:      snapshot.Harga,
+		Keterangan: snapshot.Keterangan,
+		GambarBuku: snapshot.GambarBuku,
+		Penulis:    snapshot.Penulis,
+		Penerbit:   snapshot.Penerbit,
+		Bahasa:     snapshot.Bahasa,
+		Subjek:     snapshot.Subjek,
+		ISBN10:     snapshot.ISBN10,
+		ISBN13:     snapshot.ISBN13,
+	}
+	variants, err := s.variantRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book formats: %v", err)
+	}
+	if len(variants) > 1 {
+		req.Harga = current.Harga
+	}
+	return s.updateBook(userID, id, req, version)
+}
+
+func bookSnapshot(book *entity.Book) entity.BookSnapshot {
+	return entity.BookSnapshot{
+		NamaBarang: book.NamaBarang,
+		Stok:       book.Stok,
+		Terjual:    book.Terjual,
+		Harga:      book.Harga,
+		Keterangan: book.Keterangan,
+		GambarBuku: book.GambarBuku,
+		Penulis:    book.Penulis,
+		Penerbit:   book.Penerbit,
+		Bahasa:     book.Bahasa,
+		Subjek:     book.Subjek,
+		ISBN10:     book.ISBN10,
+		ISBN13:     book.ISBN13,
+		InStock:    book.InStock,
+	}
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// Upper bounds for an admin-chosen preview length
+const (
+	maxPreviewChapters = 20
+	maxPreviewPages    = 100
+)
+
+type BookPreviewService interface {
+	GetPreviews(bookID int) ([]entity.BookPreview, error)
+	OpenPreview(bookID int, format string) (*BookPreviewFile, error)
+	AttachPreview(bookID int, file multipart.File, header *multipart.FileHeader) (*entity.BookPreview, error)
+	GeneratePreview(fileID, length int) (*entity.BookPreview, error)
+	GenerateOnUpload(bookFile *entity.BookFile) (*entity.BookPreview, error)
+	DeletePreview(id int) error
+}
+
+// BookPreviewFile is an opened preview. The caller must close Content.
+type BookPreviewFile struct {
+	Preview *entity.BookPreview
+	Content *os.File
+}
+
+type bookPreviewService struct {
+	previewRepo     repository.BookPreviewRepository
+	fileRepo        repository.BookFileRepository
+	bookRepo        repository.BookRepository
+	storeDir        string
+	previewChapters int
+	previewPages    int
+}
+
+func NewBookPreviewService(previewRepo repository.BookPreviewRepository, fileRepo repository.BookFileRepository, bookRepo repository.BookRepository, storeDir string, previewChapters, previewPages int) BookPreviewService {
+	return &bookPreviewService{
+		previewRepo:     previewRepo,
+		fileRepo:        fileRepo,
+		bookRepo:        bookRepo,
+		storeDir:        storeDir,
+		previewChapters: previewChapters,
+		previewPages:    previewPages,
+	}
+}
+
+func (s *bookPreviewService) GetPreviews(bookID int) ([]entity.BookPreview, error) {
+	previews, err := s.previewRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book previews: %v", err)
+	}
+	if previews == nil {
+		previews = []entity.BookPreview{}
+	}
+	return previews, nil
+}
+
+// OpenPreview opens the preview of a book in the format, or its first
+// preview when no format is given. Previews of deleted books are not served.
+func (s *bookPreviewService) OpenPreview(bookID int, format string) (*BookPreviewFile, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	var preview *entity.BookPreview
+	if format == "" {
+		previews, err := s.previewRepo.FindByBookID(bookID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get book previews: %v", err)
+		}
+		if len(previews) > 0 {
+			preview = &previews[0]
+		}
+	} else {
+		var err error
+		preview, err = s.previewRepo.FindByBookAndFormat(bookID, strings.ToLower(format))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get book preview: %v", err)
+		}
+	}
+	if preview == nil {
+		return nil, ErrPreviewNotFound
+	}
+
+	f, err := os.Open(filepath.Join(s.storeDir, preview.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("stored preview is missing")
+	}
+
+	return &BookPreviewFile{Preview: preview, Content: f}, nil
+}
+
+// AttachPreview stores an admin-made preview, replacing any preview of the
+// book in the same format
+func (s *bookPreviewService) AttachPreview(bookID int, file multipart.File, header *multipart.FileHeader) (*entity.BookPreview, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	if header.Size > MaxBookFileSize {
+		return nil, fmt.Errorf("file size exceeds 200MB limit")
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	contentType, ok := bookFileContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid file type. Only EPUB, PDF, and MOBI are allowed")
+	}
+
+	dst, filename, err := s.createPreviewFile(bookID, format)
+	if err != nil {
+		return nil, err
+	}
+	defer dst.Close()
+
+	size, err := io.Copy(dst, file)
+	if err != nil {
+		os.Remove(dst.Name())
+		return nil, fmt.Errorf("failed to save file: %v", err)
+	}
+
+	preview := &entity.BookPreview{
+		BookID:      bookID,
+		Format:      format,
+		FileName:    filename,
+		ContentType: contentType,
+		Size:        size,
+		Source:      entity.PreviewSourceUploaded,
+	}
+	if err := s.save(preview); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// GeneratePreview cuts a preview of the given length out of a stored book
+// file, replacing any preview of the book in that format. A length of zero
+// uses the configured default.
+func (s *bookPreviewService) GeneratePreview(fileID, length int) (*entity.BookPreview, error) {
+	bookFile, err := s.fileRepo.FindByID(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch bookFile.Format {
+	case "epub":
+		if length > maxPreviewChapters {
+			return nil, fmt.Errorf("length must be at most %d chapters", maxPreviewChapters)
+		}
+	case "pdf":
+		if length > maxPreviewPages {
+			return nil, fmt.Errorf("length must be at most %d pages", maxPreviewPages)
+		}
+	default:
+		return nil, fmt.Errorf("previews can only be generated from EPUB and PDF files")
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("length must be a positive number")
+	}
+
+	return s.generate(bookFile, length)
+}
+
+// GenerateOnUpload creates the preview of a newly uploaded EPUB or PDF with
+// the default length. A preview uploaded by an admin is never replaced, and
+// nil is returned when no preview was generated.
+func (s *bookPreviewService) GenerateOnUpload(bookFile *entity.BookFile) (*entity.BookPreview, error) {
+	if bookFile.Format != "epub" && bookFile.Format != "pdf" {
+		return nil, nil
+	}
+
+	existing, err := s.previewRepo.FindByBookAndFormat(bookFile.BookID, bookFile.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book preview: %v", err)
+	}
+	if existing != nil && existing.Source == entity.PreviewSourceUploaded {
+		return nil, nil
+	}
+
+	return s.generate(bookFile, 0)
+}
+
+func (s *bookPreviewService) DeletePreview(id int) error {
+	preview, err := s.previewRepo.FindByID(id)
+	if err != nil {
+		return ErrPreviewNotFound
+	}
+
+	if err := s.previewRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete book preview: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(s.storeDir, preview.FileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete stored preview: %v", err)
+	}
+	return nil
+}
+
+// generate writes the preview from the master file on the server, so the
+// full book is never handed out to produce it
+func (s *bookPreviewService) generate(bookFile *entity.BookFile, length int) (*entity.BookPreview, error) {
+	book, err := s.bookRepo.FindByID(bookFile.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	master, err := os.Open(filepath.Join(s.storeDir, bookFile.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("stored file is missing")
+	}
+	defer master.Close()
+
+	dst, filename, err := s.createPreviewFile(bookFile.BookID, bookFile.Format)
+	if err != nil {
+		return nil, err
+	}
+	defer dst.Close()
+
+	var n int
+	if bookFile.Format == "epub" {
+		if length == 0 {
+			length = s.previewChapters
+		}
+		n, err = PreviewEPUB(dst, master, bookFile.Size, length, book.NamaBarang)
+	} else {
+		if length == 0 {
+			length = s.previewPages
+		}
+		n, err = PreviewPDF(dst, master, bookFile.Size, length)
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return nil, fmt.Errorf("failed to generate preview: %v", err)
+	}
+
+	info, err := dst.Stat()
+	if err != nil {
+		os.Remove(dst.Name())
+		return nil, fmt.Errorf("failed to save preview: %v", err)
+	}
+
+	preview := &entity.BookPreview{
+		BookID:       bookFile.BookID,
+		Format:       bookFile.Format,
+		FileName:     filename,
+		ContentType:  bookFile.ContentType,
+		Size:         info.Size(),
+		Source:       entity.PreviewSourceGenerated,
+		SourceFileID: &bookFile.ID,
+		Length:       n,
+	}
+	if err := s.save(preview); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+func (s *bookPreviewService) createPreviewFile(bookID int, format string) (*os.File, string, error) {
+	if err := os.MkdirAll(s.storeDir, 0750); err != nil {
+		return nil, "", fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	filename := fmt.Sprintf("preview_%d_%d_%s.%s", bookID, time.Now().UnixNano(), generateRandomString(8), format)
+	dst, err := os.OpenFile(filepath.Join(s.storeDir, filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create destination file: %v", err)
+	}
+	return dst, filename, nil
+}
+
+// save records the preview and removes the file of the preview it replaces
+func (s *bookPreviewService) save(preview *entity.BookPreview) error {
+	previous, err := s.previewRepo.FindByBookAndFormat(preview.BookID, preview.Format)
+	if err != nil {
+		os.Remove(filepath.Join(s.storeDir, preview.FileName))
+		return fmt.Errorf("failed to get book preview: %v", err)
+	}
+
+	if err := s.previewRepo.Save(preview); err != nil {
+		os.Remove(filepath.Join(s.storeDir, preview.FileName))
+		return fmt.Errorf("failed to save book preview: %v", err)
+	}
+
+	if previous != nil {
+		os.Remove(filepath.Join(s.storeDir, previous.FileName))
+	}
+	return nil
+}
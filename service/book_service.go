@@ -2,6 +2,9 @@ package service
 
 import (
 	"fmt"
+	"log"
+	"regexp"
+	"strings"
 
 	"github.com/LanangDepok/ebook-store/entity"
 	"github.com/LanangDepok/ebook-store/model"
@@ -9,45 +12,260 @@ import (
 )
 
 type BookService interface {
-	CreateBook(req model.CreateBookRequest) (*entity.Book, error)
-	GetAllBooks() ([]entity.Book, error)
+	CreateBook(userID int, req model.CreateBookRequest) (*entity.Book, error)
+	GetAllBooks(req model.BookListRequest) (*model.PaginationResponse, error)
+	SearchBooks(q, dictionary string) ([]entity.BookSearchResult, error)
 	GetBookByID(id int) (*entity.Book, error)
-	UpdateBook(id int, req model.UpdateBookRequest) (*entity.Book, error)
+	GetBookByISBN(isbn string) (*entity.Book, error)
+	UpdateBook(userID, id int, req model.UpdateBookRequest) (*entity.Book, error)
 	DeleteBook(id int) error
+	GetDeletedBooks(req model.BookListRequest) (*model.PaginationResponse, error)
+	RestoreBook(id int) (*entity.Book, error)
+	GetBookHistory(id int) ([]entity.BookVersion, error)
+	RollbackBook(userID, id, version int) (*entity.Book, error)
 }
 
+// searchLimit caps the number of ranked results returned for a query
+const searchLimit = 100
+
+// searchTerm matches the words of a search query; everything else, including
+// tsquery operators, is dropped
+var searchTerm = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
 type bookService struct {
-	repo repository.BookRepository
+	repo             repository.BookRepository
+	categoryRepo     repository.CategoryRepository
+	authorRepo       repository.AuthorRepository
+	publisherRepo    repository.PublisherRepository
+	seriesRepo       repository.SeriesRepository
+	previewRepo      repository.BookPreviewRepository
+	variantRepo      repository.BookVariantRepository
+	historyRepo      repository.BookHistoryRepository
+	notificationRepo repository.NotificationRepository
+	searchDictionary string
 }
 
-func NewBookService(repo repository.BookRepository) BookService {
-	return &bookService{repo: repo}
+func NewBookService(repo repository.BookRepository, categoryRepo repository.CategoryRepository, authorRepo repository.AuthorRepository, publisherRepo repository.PublisherRepository, seriesRepo repository.SeriesRepository, previewRepo repository.BookPreviewRepository, variantRepo repository.BookVariantRepository, historyRepo repository.BookHistoryRepository, notificationRepo repository.NotificationRepository, searchDictionary string) BookService {
+	return &bookService{
+		repo:             repo,
+		categoryRepo:     categoryRepo,
+		authorRepo:       authorRepo,
+		publisherRepo:    publisherRepo,
+		seriesRepo:       seriesRepo,
+		previewRepo:      previewRepo,
+		variantRepo:      variantRepo,
+		historyRepo:      historyRepo,
+		notificationRepo: notificationRepo,
+		searchDictionary: searchDictionary,
+	}
 }
 
-func (s *bookService) CreateBook(req model.CreateBookRequest) (*entity.Book, error) {
+func (s *bookService) CreateBook(userID int, req model.CreateBookRequest) (*entity.Book, error) {
+	format := req.Format
+	if format == "" {
+		format = entity.VariantFormatEPUB
+	}
+	if !variantFormats[format] {
+		return nil, ErrInvalidFormat
+	}
+
+	isbn10, isbn13, err := s.checkISBNs(0, req.ISBN10, req.ISBN13)
+	if err != nil {
+		return nil, err
+	}
+
 	book := &entity.Book{
 		NamaBarang: req.NamaBarang,
 		Stok:       req.Stok,
 		Harga:      req.Harga,
 		Keterangan: req.Keterangan,
 		GambarBuku: req.GambarBuku,
+		Penulis:    req.Penulis,
+		Penerbit:   req.Penerbit,
+		Bahasa:     req.Bahasa,
+		Subjek:     req.Subjek,
+		ISBN10:     isbn10,
+		ISBN13:     isbn13,
 		Terjual:    0,
 	}
 
-	err := s.repo.Create(book)
+	err = s.repo.Create(book)
+	if repository.IsDuplicateISBN(err) {
+		return nil, fmt.Errorf("%w: another book was just saved with ISBN %s", ErrDuplicateISBN, isbn13)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create book: %v", err)
 	}
 
+	if err := s.variantRepo.SyncFromBook(book.ID, format); err != nil {
+		return nil, fmt.Errorf("failed to create book format: %v", err)
+	}
+
+	if err := s.syncContributors(book, true, true); err != nil {
+		return nil, err
+	}
+
+	version := &entity.BookVersion{
+		BookID:   book.ID,
+		Action:   entity.BookActionCreate,
+		UserID:   userID,
+		Snapshot: bookSnapshot(book),
+	}
+	if err := s.historyRepo.Create(version); err != nil {
+		log.Printf("Book %d: failed to record history: %v", book.ID, err)
+	}
+
 	return book, nil
 }
 
-func (s *bookService) GetAllBooks() ([]entity.Book, error) {
-	books, err := s.repo.FindAll()
+// GetAllBooks returns one page of the filtered book list. Page based
+// pagination is used unless the request carries a cursor from a previous
+// page; next_cursor is returned whenever more rows follow.
+func (s *bookService) GetAllBooks(req model.BookListRequest) (*model.PaginationResponse, error) {
+	filter, err := bookFilterFromRequest(&req)
+	if err != nil {
+		return nil, err
+	}
+	return s.listBooks(filter, req)
+}
+
+// GetDeletedBooks lists soft deleted books for admins, with the same
+// filters and pagination as the catalog
+func (s *bookService) GetDeletedBooks(req model.BookListRequest) (*model.PaginationResponse, error) {
+	filter, err := bookFilterFromRequest(&req)
+	if err != nil {
+		return nil, err
+	}
+	filter.Deleted = true
+	return s.listBooks(filter, req)
+}
+
+func (s *bookService) listBooks(filter repository.BookFilter, req model.BookListRequest) (*model.PaginationResponse, error) {
+	total, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count books: %v", err)
+	}
+
+	// Fetch one extra row to know whether another page follows
+	filter.Limit = req.Limit + 1
+	books, err := s.repo.FindAll(filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get books: %v", err)
 	}
-	return books, nil
+
+	resp := &model.PaginationResponse{
+		Page:       req.Page,
+		Limit:      req.Limit,
+		Total:      total,
+		TotalPages: (total + req.Limit - 1) / req.Limit,
+	}
+
+	if len(books) > req.Limit {
+		books = books[:req.Limit]
+		resp.NextCursor = encodeBookCursor(filter, books[len(books)-1])
+	}
+	if books == nil {
+		books = []entity.Book{}
+	}
+	resp.Data = books
+
+	return resp, nil
+}
+
+func bookFilterFromRequest(req *model.BookListRequest) (repository.BookFilter, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultPageLimit
+	}
+	if req.Limit > maxPageLimit {
+		return repository.BookFilter{}, fmt.Errorf("limit must be at most %d", maxPageLimit)
+	}
+
+	filter := repository.BookFilter{
+		MinHarga:  req.MinHarga,
+		MaxHarga:  req.MaxHarga,
+		InStock:   req.InStock,
+		Category:  strings.TrimSpace(req.Category),
+		Author:    strings.TrimSpace(req.Author),
+		Role:      strings.TrimSpace(req.Role),
+		Publisher: strings.TrimSpace(req.Publisher),
+		Sort:      req.Sort,
+		Offset:    (req.Page - 1) * req.Limit,
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = repository.BookSortCreatedAt
+	}
+	if !repository.IsBookSort(filter.Sort) {
+		return filter, fmt.Errorf("sort must be one of: harga, terjual, created_at, nama_barang")
+	}
+
+	switch req.Order {
+	case "":
+		// Newest, best selling and most expensive first; names A-Z
+		filter.Desc = filter.Sort != repository.BookSortNama
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, fmt.Errorf("order must be asc or desc")
+	}
+
+	if filter.Role != "" && !isContributorRole(filter.Role) {
+		return filter, fmt.Errorf("role must be one of: %s", strings.Join(entity.ContributorRoles, ", "))
+	}
+
+	if req.MinHarga != nil && req.MaxHarga != nil && *req.MinHarga > *req.MaxHarga {
+		return filter, fmt.Errorf("min_harga must not be greater than max_harga")
+	}
+
+	if req.Cursor != "" {
+		after, err := decodeBookCursor(req.Cursor, filter)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+
+	return filter, nil
+}
+
+// SearchBooks ranks books against q. Every word is prefix matched, so
+// "pemrog go" finds "Pemrograman Go". An empty dictionary uses the default.
+func (s *bookService) SearchBooks(q, dictionary string) ([]entity.BookSearchResult, error) {
+	if dictionary == "" {
+		dictionary = s.searchDictionary
+	}
+	if dictionary != entity.SearchDictionaryIndonesian && dictionary != entity.SearchDictionarySimple {
+		return nil, fmt.Errorf("dict must be one of: %s, %s", entity.SearchDictionaryIndonesian, entity.SearchDictionarySimple)
+	}
+
+	tsquery := prefixTSQuery(q)
+	if tsquery == "" {
+		return []entity.BookSearchResult{}, nil
+	}
+
+	results, err := s.repo.Search(tsquery, dictionary, searchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search books: %v", err)
+	}
+
+	if results == nil {
+		results = []entity.BookSearchResult{}
+	}
+	return results, nil
+}
+
+// prefixTSQuery turns free text into a to_tsquery expression that requires
+// every word as a prefix, e.g. "belajar go" becomes "belajar:* & go:*"
+func prefixTSQuery(q string) string {
+	words := searchTerm.FindAllString(strings.ToLower(q), 10)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
 }
 
 func (s *bookService) GetBookByID(id int) (*entity.Book, error) {
@@ -55,15 +273,97 @@ func (s *bookService) GetBookByID(id int) (*entity.Book, error) {
 	if err != nil {
 		return nil, fmt.Errorf("book not found: %v", err)
 	}
+	return s.withDetails(book)
+}
+
+// GetBookByISBN looks a book up by an ISBN-10 or ISBN-13, with or without
+// hyphens
+func (s *bookService) GetBookByISBN(isbn string) (*entity.Book, error) {
+	isbn13, err := parseISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	isbn10, _ := isbn13To10(isbn13)
+	book, err := s.repo.FindByISBN(isbn10, isbn13)
+	if err != nil {
+		return nil, fmt.Errorf("book not found: %v", err)
+	}
+	if book.DeletedAt != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+	return s.withDetails(book)
+}
+
+// withDetails loads the categories, contributors, publisher, series,
+// formats and previews of a book
+func (s *bookService) withDetails(book *entity.Book) (*entity.Book, error) {
+	id := book.ID
+	var err error
+	book.Categories, err = s.categoryRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book categories: %v", err)
+	}
+
+	book.Contributors, err = s.authorRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book contributors: %v", err)
+	}
+
+	book.Publisher, err = s.publisherRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book publisher: %v", err)
+	}
+
+	book.Series, err = s.seriesRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book series: %v", err)
+	}
+
+	book.Variants, err = s.variantRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book formats: %v", err)
+	}
+
+	book.Previews, err = s.previewRepo.FindByBookID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book previews: %v", err)
+	}
 	return book, nil
 }
 
-func (s *bookService) UpdateBook(id int, req model.UpdateBookRequest) (*entity.Book, error) {
+func (s *bookService) UpdateBook(userID, id int, req model.UpdateBookRequest) (*entity.Book, error) {
+	return s.updateBook(userID, id, req, 0)
+}
+
+// updateBook saves the request over the book together with the change in its
+// history; revertedTo is the version a rollback returns to
+func (s *bookService) updateBook(userID, id int, req model.UpdateBookRequest, revertedTo int) (*entity.Book, error) {
 	// Check if book exists
 	existingBook, err := s.repo.FindByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("book not found: %v", err)
 	}
+	before := bookSnapshot(existingBook)
+
+	isbn10, isbn13, err := s.checkISBNs(id, req.ISBN10, req.ISBN13)
+	if err != nil {
+		return nil, err
+	}
+
+	// A book sold in several formats takes its price and stock from them
+	if req.Harga != existingBook.Harga || req.Stok != existingBook.Stok {
+		variants, err := s.variantRepo.FindByBookID(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get book formats: %v", err)
+		}
+		if len(variants) > 1 {
+			return nil, ErrBookHasVariants
+		}
+	}
+
+	penulisChanged := existingBook.Penulis != req.Penulis
+	penerbitChanged := existingBook.Penerbit != req.Penerbit
 
 	// Update book fields
 	existingBook.NamaBarang = req.NamaBarang
@@ -71,18 +371,120 @@ func (s *bookService) UpdateBook(id int, req model.UpdateBookRequest) (*entity.B
 	existingBook.Terjual = req.Terjual
 	existingBook.Harga = req.Harga
 	existingBook.Keterangan = req.Keterangan
+	existingBook.Penulis = req.Penulis
+	existingBook.Penerbit = req.Penerbit
+	existingBook.Bahasa = req.Bahasa
+	existingBook.Subjek = req.Subjek
+	existingBook.ISBN10 = isbn10
+	existingBook.ISBN13 = isbn13
 	if req.GambarBuku != "" {
 		existingBook.GambarBuku = req.GambarBuku
 	}
 
-	err = s.repo.Update(id, existingBook)
+	version := &entity.BookVersion{
+		Action:     entity.BookActionUpdate,
+		UserID:     userID,
+		RevertedTo: revertedTo,
+	}
+	if revertedTo != 0 {
+		version.Action = entity.BookActionRollback
+	}
+
+	err = s.repo.Update(id, existingBook, version)
+	if repository.IsDuplicateISBN(err) {
+		return nil, fmt.Errorf("%w: another book was just saved with ISBN %s", ErrDuplicateISBN, isbn13)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update book: %v", err)
 	}
 
+	if err := s.syncContributors(existingBook, penulisChanged, penerbitChanged); err != nil {
+		return nil, err
+	}
+
+	notifyWishlisters(s.notificationRepo, id, before, bookSnapshot(existingBook))
+
 	return existingBook, nil
 }
 
+// notifyWishlisters tells users with the book on their wishlist when its
+// price drops or it comes back in stock. Failures are only logged; the
+// update itself has already been saved.
+func notifyWishlisters(notificationRepo repository.NotificationRepository, bookID int, before, after entity.BookSnapshot) {
+	if after.Harga < before.Harga {
+		message := fmt.Sprintf("%s is now Rp%d (was Rp%d)", after.NamaBarang, after.Harga, before.Harga)
+		notifyBookWishlisters(notificationRepo, bookID, entity.NotificationPriceDrop, message)
+	}
+	if !before.InStock && after.InStock {
+		message := fmt.Sprintf("%s is back in stock", after.NamaBarang)
+		notifyBookWishlisters(notificationRepo, bookID, entity.NotificationBackInStock, message)
+	}
+}
+
+func notifyBookWishlisters(notificationRepo repository.NotificationRepository, bookID int, notificationType, message string) {
+	if _, err := notificationRepo.NotifyWishlisters(bookID, notificationType, message); err != nil {
+		log.Printf("Book %d: failed to send %s notifications: %v", bookID, notificationType, err)
+	}
+}
+
+// checkISBNs validates and completes the ISBNs of book id (0 for a new
+// book) and makes sure no other book uses them
+func (s *bookService) checkISBNs(id int, isbn10, isbn13 string) (string, string, error) {
+	isbn10, isbn13, err := resolveISBNs(isbn10, isbn13)
+	if err != nil || (isbn10 == "" && isbn13 == "") {
+		return isbn10, isbn13, err
+	}
+
+	if other, err := s.repo.FindByISBN(isbn10, isbn13); err == nil && other.ID != id {
+		if other.DeletedAt != nil {
+			return "", "", fmt.Errorf("%w: deleted book %d has ISBN %s, restore it instead", ErrDuplicateISBN, other.ID, isbn13)
+		}
+		return "", "", fmt.Errorf("%w: book %d already has ISBN %s", ErrDuplicateISBN, other.ID, isbn13)
+	}
+	return isbn10, isbn13, nil
+}
+
+// syncContributors links the book to the authors named in its penulis text
+// and the publisher named in penerbit, creating them when needed. Only the
+// fields that changed are synced, so credits set through the contributor
+// endpoints are not overwritten by an unrelated edit.
+func (s *bookService) syncContributors(book *entity.Book, penulis, penerbit bool) error {
+	if penulis {
+		if err := s.authorRepo.SyncBookAuthors(book.ID, authorsFromText(book.Penulis)); err != nil {
+			return fmt.Errorf("failed to sync book authors: %v", err)
+		}
+	}
+
+	if penerbit {
+		var publisherID *int
+		if nama := strings.TrimSpace(book.Penerbit); nama != "" {
+			publisher := &entity.Publisher{Nama: nama, Slug: contributorSlug("publisher", nama)}
+			if err := s.publisherRepo.FindOrCreate(publisher); err != nil {
+				return fmt.Errorf("failed to sync book publisher: %v", err)
+			}
+			publisherID = &publisher.ID
+		}
+		if err := s.publisherRepo.SetBookPublisher(book.ID, publisherID); err != nil {
+			return fmt.Errorf("failed to sync book publisher: %v", err)
+		}
+	}
+
+	var err error
+	book.Contributors, err = s.authorRepo.FindByBookID(book.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get book contributors: %v", err)
+	}
+	book.Publisher, err = s.publisherRepo.FindByBookID(book.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get book publisher: %v", err)
+	}
+	book.Penulis = joinContributors(book.Contributors, entity.RoleAuthor)
+	if book.Publisher != nil {
+		book.Penerbit = book.Publisher.Nama
+	}
+	return nil
+}
+
 func (s *bookService) DeleteBook(id int) error {
 	// Check if book exists
 	_, err := s.repo.FindByID(id)
@@ -97,3 +499,11 @@ func (s *bookService) DeleteBook(id int) error {
 
 	return nil
 }
+
+// RestoreBook brings a soft deleted book back into the catalog
+func (s *bookService) RestoreBook(id int) (*entity.Book, error) {
+	if err := s.repo.Restore(id); err != nil {
+		return nil, err
+	}
+	return s.GetBookByID(id)
+}
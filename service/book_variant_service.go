@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+var variantFormats = map[string]bool{
+	entity.VariantFormatEPUB:      true,
+	entity.VariantFormatPDF:       true,
+	entity.VariantFormatAudiobook: true,
+	entity.VariantFormatPrint:     true,
+}
+
+type BookVariantService interface {
+	GetVariants(bookID int) ([]entity.BookVariant, error)
+	CreateVariant(bookID int, req model.BookVariantRequest) (*entity.BookVariant, error)
+	UpdateVariant(id int, req model.BookVariantRequest) (*entity.BookVariant, error)
+	DeleteVariant(id int) error
+}
+
+type bookVariantService struct {
+	variantRepo      repository.BookVariantRepository
+	bookRepo         repository.BookRepository
+	notificationRepo repository.NotificationRepository
+}
+
+func NewBookVariantService(variantRepo repository.BookVariantRepository, bookRepo repository.BookRepository, notificationRepo repository.NotificationRepository) BookVariantService {
+	return &bookVariantService{
+		variantRepo:      variantRepo,
+		bookRepo:         bookRepo,
+		notificationRepo: notificationRepo,
+	}
+}
+
+func (s *bookVariantService) GetVariants(bookID int) ([]entity.BookVariant, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	variants, err := s.variantRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book formats: %v", err)
+	}
+
+	if variants == nil {
+		variants = []entity.BookVariant{}
+	}
+	return variants, nil
+}
+
+func (s *bookVariantService) CreateVariant(bookID int, req model.BookVariantRequest) (*entity.BookVariant, error) {
+	if err := validateVariantRequest(req); err != nil {
+		return nil, err
+	}
+
+	book, err := s.bookRepo.FindByID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	existing, err := s.variantRepo.FindByBookAndFormat(bookID, req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check book formats: %v", err)
+	}
+	if existing != nil {
+		return nil, ErrDuplicateVariant
+	}
+
+	variant := &entity.BookVariant{
+		BookID: bookID,
+		Format: req.Format,
+		Harga:  req.Harga,
+		Stok:   req.Stok,
+	}
+	if err := s.variantRepo.Create(variant); err != nil {
+		return nil, fmt.Errorf("failed to create book format: %v", err)
+	}
+
+	s.notifyChanges(book)
+	return variant, nil
+}
+
+func (s *bookVariantService) UpdateVariant(id int, req model.BookVariantRequest) (*entity.BookVariant, error) {
+	if err := validateVariantRequest(req); err != nil {
+		return nil, err
+	}
+
+	variant, err := s.variantRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Format != variant.Format {
+		existing, err := s.variantRepo.FindByBookAndFormat(variant.BookID, req.Format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check book formats: %v", err)
+		}
+		if existing != nil {
+			return nil, ErrDuplicateVariant
+		}
+	}
+
+	book, err := s.bookRepo.FindByID(variant.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	variant.Format = req.Format
+	variant.Harga = req.Harga
+	variant.Stok = req.Stok
+	if err := s.variantRepo.Update(variant); err != nil {
+		return nil, fmt.Errorf("failed to update book format: %v", err)
+	}
+
+	s.notifyChanges(book)
+	return variant, nil
+}
+
+func (s *bookVariantService) DeleteVariant(id int) error {
+	return s.variantRepo.Delete(id)
+}
+
+// notifyChanges compares the book as it was before a format changed with
+// the price and stock now derived from its formats, and tells wishlisters
+// about a price drop or restock
+func (s *bookVariantService) notifyChanges(before *entity.Book) {
+	after, err := s.bookRepo.FindByID(before.ID)
+	if err != nil {
+		return
+	}
+	notifyWishlisters(s.notificationRepo, before.ID, bookSnapshot(before), bookSnapshot(after))
+}
+
+func validateVariantRequest(req model.BookVariantRequest) error {
+	if !variantFormats[req.Format] {
+		return ErrInvalidFormat
+	}
+	if req.Harga <= 0 {
+		return fmt.Errorf("invalid price")
+	}
+	if req.Stok != nil && *req.Stok < 0 {
+		return fmt.Errorf("stok cannot be negative")
+	}
+	if req.Format == entity.VariantFormatPrint && req.Stok == nil {
+		return fmt.Errorf("print editions need a stok")
+	}
+	return nil
+}
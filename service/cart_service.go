@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/LanangDepok/ebook-store/entity"
 	"github.com/LanangDepok/ebook-store/model"
@@ -17,30 +18,37 @@ type CartService interface {
 }
 
 type cartService struct {
-	cartRepo repository.CartRepository
-	bookRepo repository.BookRepository
+	cartRepo    repository.CartRepository
+	bookRepo    repository.BookRepository
+	variantRepo repository.BookVariantRepository
 }
 
-func NewCartService(cartRepo repository.CartRepository, bookRepo repository.BookRepository) CartService {
+func NewCartService(cartRepo repository.CartRepository, bookRepo repository.BookRepository, variantRepo repository.BookVariantRepository) CartService {
 	return &cartService{
-		cartRepo: cartRepo,
-		bookRepo: bookRepo,
+		cartRepo:    cartRepo,
+		bookRepo:    bookRepo,
+		variantRepo: variantRepo,
 	}
 }
 
 func (s *cartService) AddToCart(userID int, req model.AddToCartRequest) error {
-	// Check if book exists and has stock
+	// Check if book exists and the chosen format has stock
 	book, err := s.bookRepo.FindByID(req.BookID)
 	if err != nil {
 		return fmt.Errorf("book not found")
 	}
 
-	if book.Stok < req.Jumlah {
+	variant, err := s.findVariant(book.ID, req)
+	if err != nil {
+		return err
+	}
+
+	if variant.Stok != nil && *variant.Stok < req.Jumlah {
 		return fmt.Errorf("insufficient stock")
 	}
 
-	// Check if item already in cart
-	existingCart, err := s.cartRepo.FindByUserAndBook(userID, req.BookID)
+	// Check if the format is already in cart
+	existingCart, err := s.cartRepo.FindByUserAndVariant(userID, variant.ID)
 	if err != nil {
 		return fmt.Errorf("failed to check cart: %v", err)
 	}
@@ -48,7 +56,7 @@ func (s *cartService) AddToCart(userID int, req model.AddToCartRequest) error {
 	if existingCart != nil {
 		// Update existing cart item
 		newQuantity := existingCart.Jumlah + req.Jumlah
-		if book.Stok < newQuantity {
+		if variant.Stok != nil && *variant.Stok < newQuantity {
 			return fmt.Errorf("insufficient stock")
 		}
 		return s.cartRepo.UpdateQuantity(existingCart.ID, newQuantity)
@@ -56,15 +64,51 @@ func (s *cartService) AddToCart(userID int, req model.AddToCartRequest) error {
 
 	// Create new cart item
 	cart := &entity.Cart{
-		UserID: userID,
-		BookID: req.BookID,
-		Jumlah: req.Jumlah,
-		Harga:  book.Harga,
+		UserID:    userID,
+		BookID:    req.BookID,
+		VariantID: variant.ID,
+		Jumlah:    req.Jumlah,
+		Harga:     variant.Harga,
 	}
 
 	return s.cartRepo.Create(cart)
 }
 
+// findVariant resolves the format being added, by variant ID or format
+// name, or the book's only format when it is sold in just one
+func (s *cartService) findVariant(bookID int, req model.AddToCartRequest) (*entity.BookVariant, error) {
+	if req.VariantID > 0 {
+		variant, err := s.variantRepo.FindByID(req.VariantID)
+		if err != nil || variant.BookID != bookID {
+			return nil, fmt.Errorf("book variant not found")
+		}
+		return variant, nil
+	}
+
+	if format := strings.ToLower(strings.TrimSpace(req.Format)); format != "" {
+		variant, err := s.variantRepo.FindByBookAndFormat(bookID, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get book variant: %v", err)
+		}
+		if variant == nil {
+			return nil, fmt.Errorf("book is not sold as %s", format)
+		}
+		return variant, nil
+	}
+
+	variants, err := s.variantRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book variants: %v", err)
+	}
+	switch len(variants) {
+	case 0:
+		return nil, fmt.Errorf("book is not for sale")
+	case 1:
+		return &variants[0], nil
+	}
+	return nil, fmt.Errorf("book is sold in several formats, choose a variant_id or format")
+}
+
 func (s *cartService) GetCart(userID int) ([]entity.CartItem, int, error) {
 	items, err := s.cartRepo.FindByUserID(userID)
 	if err != nil {
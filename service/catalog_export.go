@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+)
+
+const (
+	onixSenderName = "Ebook Store"
+
+	// onixSchemeName names our category tree in proprietary subject codes
+	// and the book ID in proprietary product identifiers
+	onixSchemeName = "ebook-store"
+)
+
+// onixExportRoles maps our contributor roles to ONIX role codes
+var onixExportRoles = map[string]string{
+	entity.RoleAuthor:      "A01",
+	entity.RoleEditor:      "B01",
+	entity.RoleTranslator:  "B06",
+	entity.RoleIllustrator: "A12",
+}
+
+// onixExportLanguages maps the two letter codes in books.bahasa to the
+// ISO 639-2/B codes ONIX expects
+var onixExportLanguages = map[string]string{
+	"id": "ind",
+	"en": "eng",
+	"ms": "may",
+	"jv": "jav",
+	"su": "sun",
+	"ar": "ara",
+	"zh": "chi",
+	"ja": "jpn",
+	"ko": "kor",
+	"nl": "dut",
+	"de": "ger",
+	"fr": "fre",
+}
+
+// onixProduct describes a book as an ONIX 3.0 product record
+func (s *exportService) onixProduct(book *entity.Book) model.ONIXProduct {
+	product := model.ONIXProduct{
+		RecordReference:  fmt.Sprintf("%s.book.%d", onixSchemeName, book.ID),
+		NotificationType: "03",
+	}
+
+	if book.ISBN13 != "" {
+		product.ProductIdentifiers = append(product.ProductIdentifiers, model.ONIXProductIdentifier{
+			ProductIDType: "15", IDValue: book.ISBN13,
+		})
+	}
+	if book.ISBN10 != "" {
+		product.ProductIdentifiers = append(product.ProductIdentifiers, model.ONIXProductIdentifier{
+			ProductIDType: "02", IDValue: book.ISBN10,
+		})
+	}
+	if len(product.ProductIdentifiers) == 0 {
+		product.ProductIdentifiers = append(product.ProductIdentifiers, model.ONIXProductIdentifier{
+			ProductIDType: "01", IDTypeName: onixSchemeName, IDValue: strconv.Itoa(book.ID),
+		})
+	}
+
+	detail := &product.DescriptiveDetail
+	detail.ProductComposition = "00"
+	detail.ProductForm = "ED"
+	detail.ProductFormDetail = "E101"
+	detail.TitleDetails = []model.ONIXTitleDetail{{
+		TitleType: "01",
+		TitleElements: []model.ONIXTitleElement{{
+			TitleElementLevel: "01",
+			TitleText:         book.NamaBarang,
+		}},
+	}}
+
+	contributors := book.Contributors
+	if len(contributors) == 0 {
+		// Books without credits only have the penulis text
+		for _, name := range splitAuthors(book.Penulis) {
+			contributors = append(contributors, entity.BookContributor{Nama: name, Role: entity.RoleAuthor})
+		}
+	}
+	for i, contributor := range contributors {
+		detail.Contributors = append(detail.Contributors, model.ONIXContributor{
+			SequenceNumber:  strconv.Itoa(i + 1),
+			ContributorRole: []string{onixExportRoles[contributor.Role]},
+			PersonName:      contributor.Nama,
+		})
+	}
+
+	if bahasa := strings.ToLower(book.Bahasa); bahasa != "" {
+		code, ok := onixExportLanguages[bahasa]
+		if !ok && len(bahasa) == 3 {
+			code, ok = bahasa, true
+		}
+		if ok {
+			detail.Languages = []model.ONIXLanguage{{LanguageRole: "01", LanguageCode: code}}
+		}
+	}
+
+	for _, category := range book.Categories {
+		detail.Subjects = append(detail.Subjects, model.ONIXSubject{
+			SubjectSchemeIdentifier: "24",
+			SubjectSchemeName:       onixSchemeName,
+			SubjectCode:             category.Slug,
+			SubjectHeadingText:      category.Nama,
+		})
+	}
+	if book.Subjek != "" {
+		detail.Subjects = append(detail.Subjects, model.ONIXSubject{
+			SubjectSchemeIdentifier: "20",
+			SubjectHeadingText:      book.Subjek,
+		})
+	}
+
+	if book.Keterangan != "" {
+		product.CollateralDetail.TextContents = []model.ONIXTextContent{{
+			TextType:        "03",
+			ContentAudience: "00",
+			Texts:           []model.ONIXText{{Value: book.Keterangan}},
+		}}
+	}
+	if book.GambarBuku != "" {
+		product.CollateralDetail.SupportingResources = []model.ONIXSupportingResource{{
+			ResourceContentType: "01",
+			ContentAudience:     "00",
+			ResourceMode:        "03",
+			ResourceVersions: []model.ONIXResourceVersion{{
+				ResourceForm:  "02",
+				ResourceLinks: []string{s.uploadService.GetImageURL(book.GambarBuku)},
+			}},
+		}}
+	}
+
+	if book.Penerbit != "" {
+		product.PublishingDetail.Publishers = []model.ONIXPublisher{{
+			PublishingRole: "01", PublisherName: book.Penerbit,
+		}}
+	}
+	product.PublishingDetail.PublishingStatus = "04"
+
+	availability := "21"
+	if !book.InStock {
+		availability = "31"
+	}
+	product.ProductSupply = []model.ONIXProductSupply{{
+		SupplyDetails: []model.ONIXSupplyDetail{{
+			Supplier:            &model.ONIXSupplier{SupplierRole: "08", SupplierName: onixSenderName},
+			ProductAvailability: availability,
+			Stock:               []model.ONIXStock{{OnHand: strconv.Itoa(book.Stok)}},
+			Prices: []model.ONIXPrice{{
+				PriceType: "02", PriceAmount: strconv.Itoa(book.Harga), CurrencyCode: "IDR",
+			}},
+		}},
+	}}
+
+	return product
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+)
+
+// csvImportColumns maps accepted CSV header names to book fields
+var csvImportColumns = map[string]string{
+	"isbn":        "isbn",
+	"isbn_10":     "isbn_10",
+	"isbn10":      "isbn_10",
+	"isbn_13":     "isbn_13",
+	"isbn13":      "isbn_13",
+	"nama_barang": "nama_barang",
+	"judul":       "nama_barang",
+	"title":       "nama_barang",
+	"penulis":     "penulis",
+	"author":      "penulis",
+	"authors":     "penulis",
+	"penerbit":    "penerbit",
+	"publisher":   "penerbit",
+	"bahasa":      "bahasa",
+	"language":    "bahasa",
+	"subjek":      "subjek",
+	"subject":     "subjek",
+	"subjects":    "subjek",
+	"keterangan":  "keterangan",
+	"description": "keterangan",
+	"harga":       "harga",
+	"price":       "harga",
+	"stok":        "stok",
+	"stock":       "stok",
+}
+
+// onixContributorRoles maps ONIX contributor role codes to ours
+var onixContributorRoles = map[string]string{
+	"A01": entity.RoleAuthor,
+	"B01": entity.RoleEditor,
+	"B06": entity.RoleTranslator,
+	"A12": entity.RoleIllustrator,
+}
+
+// onixLanguages maps the ISO 639-2/B codes ONIX uses to the two letter
+// codes stored in books.bahasa; other codes are kept as they are
+var onixLanguages = map[string]string{
+	"ind": "id",
+	"eng": "en",
+	"may": "ms",
+	"msa": "ms",
+	"jav": "jv",
+	"sun": "su",
+	"ara": "ar",
+	"chi": "zh",
+	"zho": "zh",
+	"jpn": "ja",
+	"kor": "ko",
+	"dut": "nl",
+	"nld": "nl",
+	"ger": "de",
+	"deu": "de",
+	"fre": "fr",
+	"fra": "fr",
+}
+
+// parseCSVImport reads a catalog spreadsheet with a header row. Comma and
+// semicolon separated files are accepted. Rows that fail validation are
+// returned with Error set.
+func parseCSVImport(r io.Reader) ([]entity.ImportRecord, error) {
+	br := bufio.NewReader(r)
+	firstLine, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read CSV: %v", err)
+	}
+	firstLine = bytes.TrimPrefix(firstLine, []byte("\xef\xbb\xbf"))
+	if i := bytes.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvImportColumns[name]; ok {
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"nama_barang", "harga"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+	_, hasISBN := columns["isbn"]
+	_, hasISBN10 := columns["isbn_10"]
+	_, hasISBN13 := columns["isbn_13"]
+	if !hasISBN && !hasISBN10 && !hasISBN13 {
+		return nil, fmt.Errorf("CSV header needs an isbn, isbn_10 or isbn_13 column")
+	}
+
+	var records []entity.ImportRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		get := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		record := entity.ImportRecord{Row: line}
+		record.Book = entity.Book{
+			NamaBarang: get("nama_barang"),
+			Keterangan: get("keterangan"),
+			Penulis:    get("penulis"),
+			Penerbit:   get("penerbit"),
+			Bahasa:     get("bahasa"),
+			Subjek:     get("subjek"),
+		}
+		record.Error = fillImportRecord(&record, get("isbn"), get("isbn_10"), get("isbn_13"), get("harga"), get("stok"))
+		if record.Error == "" {
+			for _, name := range splitAuthors(record.Book.Penulis) {
+				record.Contributors = append(record.Contributors, entity.BookContributor{
+					Nama: name, Slug: contributorSlug("author", name), Role: entity.RoleAuthor,
+				})
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// parseONIXImport reads an ONIX 3.0 message in reference tag format
+func parseONIXImport(r io.Reader) ([]entity.ImportRecord, error) {
+	var message model.ONIXMessage
+	if err := xml.NewDecoder(r).Decode(&message); err != nil {
+		return nil, fmt.Errorf("failed to parse ONIX message: %v", err)
+	}
+
+	records := make([]entity.ImportRecord, 0, len(message.Products))
+	for i, product := range message.Products {
+		records = append(records, onixRecord(i+1, product))
+	}
+	return records, nil
+}
+
+func onixRecord(row int, product model.ONIXProduct) entity.ImportRecord {
+	record := entity.ImportRecord{Row: row}
+
+	var isbn10, isbn13 string
+	for _, id := range product.ProductIdentifiers {
+		switch id.ProductIDType {
+		case "02":
+			isbn10 = id.IDValue
+		case "15":
+			isbn13 = id.IDValue
+		case "03":
+			if isbn13 == "" {
+				isbn13 = id.IDValue
+			}
+		}
+	}
+
+	if product.NotificationType == "05" {
+		record.Book.ISBN13 = normalizeISBN(isbn13)
+		record.Error = "delete notifications (NotificationType 05) are not supported"
+		return record
+	}
+
+	detail := product.DescriptiveDetail
+	record.Book.NamaBarang = onixTitle(detail.TitleDetails)
+	for _, language := range detail.Languages {
+		if language.LanguageRole == "01" || record.Book.Bahasa == "" {
+			code := strings.ToLower(strings.TrimSpace(language.LanguageCode))
+			if mapped, ok := onixLanguages[code]; ok {
+				code = mapped
+			}
+			record.Book.Bahasa = code
+		}
+	}
+
+	var subjects []string
+	for _, subject := range detail.Subjects {
+		// Our own category codes, as written by the export, are not keywords
+		if subject.SubjectSchemeName == onixSchemeName {
+			continue
+		}
+		if text := strings.TrimSpace(subject.SubjectHeadingText); text != "" {
+			subjects = append(subjects, text)
+		}
+	}
+	record.Book.Subjek = strings.Join(subjects, ", ")
+
+	var authors []string
+	for _, contributor := range detail.Contributors {
+		name := strings.TrimSpace(contributor.PersonName)
+		if name == "" {
+			name = strings.TrimSpace(contributor.NamesBeforeKey + " " + contributor.KeyNames)
+		}
+		if name == "" {
+			name = strings.TrimSpace(contributor.CorporateName)
+		}
+		if name == "" {
+			continue
+		}
+		for _, code := range contributor.ContributorRole {
+			role, ok := onixContributorRoles[strings.TrimSpace(code)]
+			if !ok {
+				continue
+			}
+			record.Contributors = append(record.Contributors, entity.BookContributor{
+				Nama: name, Slug: contributorSlug("author", name), Role: role,
+			})
+			if role == entity.RoleAuthor {
+				authors = append(authors, name)
+			}
+		}
+	}
+	record.Book.Penulis = strings.Join(authors, ", ")
+
+	descriptions := map[string]string{}
+	for _, content := range product.CollateralDetail.TextContents {
+		for _, text := range content.Texts {
+			if value := plainText(text.Value); value != "" && descriptions[content.TextType] == "" {
+				descriptions[content.TextType] = value
+			}
+		}
+	}
+	record.Book.Keterangan = firstTrimmed([]string{descriptions["03"], descriptions["02"]})
+
+	for _, publisher := range product.PublishingDetail.Publishers {
+		if publisher.PublishingRole == "01" || record.Book.Penerbit == "" {
+			record.Book.Penerbit = strings.TrimSpace(publisher.PublisherName)
+		}
+	}
+
+	var harga, stok string
+	for _, supply := range product.ProductSupply {
+		for _, detail := range supply.SupplyDetails {
+			for _, price := range detail.Prices {
+				if strings.EqualFold(price.CurrencyCode, "IDR") && harga == "" {
+					harga = price.PriceAmount
+				}
+			}
+			for _, stock := range detail.Stock {
+				if stok == "" {
+					stok = stock.OnHand
+				}
+			}
+		}
+	}
+	record.Error = fillImportRecord(&record, "", isbn10, isbn13, harga, stok)
+	return record
+}
+
+// onixTitle returns the distinctive title with its subtitle
+func onixTitle(details []model.ONIXTitleDetail) string {
+	for _, detail := range details {
+		if detail.TitleType != "01" && len(details) > 1 {
+			continue
+		}
+		for _, element := range detail.TitleElements {
+			title := strings.TrimSpace(element.TitleText)
+			if title == "" {
+				title = strings.TrimSpace(element.TitlePrefix + " " + element.TitleWithoutPrefix)
+			}
+			if subtitle := strings.TrimSpace(element.Subtitle); subtitle != "" {
+				title += ": " + subtitle
+			}
+			if title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// fillImportRecord validates the fields shared by every import format and
+// stores them on the record. It returns the validation error, if any.
+func fillImportRecord(record *entity.ImportRecord, isbn, isbn10, isbn13, harga, stok string) string {
+	if isbn != "" {
+		parsed, err := parseISBN(isbn)
+		if err != nil {
+			return err.Error()
+		}
+		if isbn13 == "" {
+			isbn13 = parsed
+		}
+	}
+
+	var err error
+	record.Book.ISBN10, record.Book.ISBN13, err = resolveISBNs(isbn10, isbn13)
+	if err != nil {
+		return err.Error()
+	}
+	if record.Book.ISBN13 == "" {
+		return "isbn is required to match books on import"
+	}
+
+	if record.Book.NamaBarang == "" {
+		return "nama_barang is required"
+	}
+
+	if harga = strings.TrimSpace(harga); harga == "" {
+		return "harga is required"
+	}
+	amount, err := strconv.ParseFloat(harga, 64)
+	if err != nil || amount <= 0 {
+		return fmt.Sprintf("harga %q must be a positive number", harga)
+	}
+	record.Book.Harga = int(math.Round(amount))
+
+	if stok = strings.TrimSpace(stok); stok != "" {
+		n, err := strconv.Atoi(stok)
+		if err != nil || n < 0 {
+			return fmt.Sprintf("stok %q must be a non-negative whole number", stok)
+		}
+		record.Stok = &n
+	}
+
+	if record.Book.Penerbit != "" {
+		record.PublisherSlug = contributorSlug("publisher", record.Book.Penerbit)
+	}
+	return ""
+}
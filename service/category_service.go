@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+var (
+	validSlug    = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+type CategoryService interface {
+	CreateCategory(req model.CategoryRequest) (*entity.Category, error)
+	GetCategoryTree() ([]entity.Category, error)
+	GetCategoryByID(id int) (*entity.Category, error)
+	UpdateCategory(id int, req model.CategoryRequest) (*entity.Category, error)
+	DeleteCategory(id int) error
+	SetBookCategories(bookID int, req model.SetBookCategoriesRequest) ([]entity.Category, error)
+}
+
+type categoryService struct {
+	categoryRepo repository.CategoryRepository
+	bookRepo     repository.BookRepository
+}
+
+func NewCategoryService(categoryRepo repository.CategoryRepository, bookRepo repository.BookRepository) CategoryService {
+	return &categoryService{
+		categoryRepo: categoryRepo,
+		bookRepo:     bookRepo,
+	}
+}
+
+func (s *categoryService) CreateCategory(req model.CategoryRequest) (*entity.Category, error) {
+	category := &entity.Category{}
+	if err := s.applyRequest(category, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.categoryRepo.Create(category); err != nil {
+		return nil, fmt.Errorf("failed to create category: %v", err)
+	}
+	return category, nil
+}
+
+// GetCategoryTree returns the root categories with their subcategories
+// nested under Children. Book counts include every descendant.
+func (s *categoryService) GetCategoryTree() ([]entity.Category, error) {
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %v", err)
+	}
+
+	children := map[int][]entity.Category{}
+	var roots []entity.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var attach func(nodes []entity.Category) []entity.Category
+	attach = func(nodes []entity.Category) []entity.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	if roots == nil {
+		return []entity.Category{}, nil
+	}
+	return attach(roots), nil
+}
+
+func (s *categoryService) GetCategoryByID(id int) (*entity.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *categoryService) UpdateCategory(id int, req model.CategoryRequest) (*entity.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A category cannot be moved below itself or one of its descendants
+	if req.ParentID != nil {
+		cycle, err := s.categoryRepo.IsDescendant(*req.ParentID, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check parent: %v", err)
+		}
+		if cycle {
+			return nil, fmt.Errorf("parent_id cannot be the category itself or one of its subcategories")
+		}
+	}
+
+	if err := s.applyRequest(category, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.categoryRepo.Update(category); err != nil {
+		return nil, fmt.Errorf("failed to update category: %v", err)
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a leaf category; books filed under it are unlinked
+func (s *categoryService) DeleteCategory(id int) error {
+	hasChildren, err := s.categoryRepo.HasChildren(id)
+	if err != nil {
+		return fmt.Errorf("failed to check subcategories: %v", err)
+	}
+	if hasChildren {
+		return fmt.Errorf("category has subcategories, move or delete them first")
+	}
+
+	return s.categoryRepo.Delete(id)
+}
+
+func (s *categoryService) SetBookCategories(bookID int, req model.SetBookCategoriesRequest) ([]entity.Category, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	for _, categoryID := range req.CategoryIDs {
+		if _, err := s.categoryRepo.FindByID(categoryID); err != nil {
+			return nil, fmt.Errorf("category %d not found", categoryID)
+		}
+	}
+
+	if err := s.categoryRepo.SetBookCategories(bookID, req.CategoryIDs); err != nil {
+		return nil, fmt.Errorf("failed to set book categories: %v", err)
+	}
+
+	categories, err := s.categoryRepo.FindByBookID(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get book categories: %v", err)
+	}
+	if categories == nil {
+		categories = []entity.Category{}
+	}
+	return categories, nil
+}
+
+func (s *categoryService) applyRequest(category *entity.Category, req model.CategoryRequest) error {
+	req.Nama = strings.TrimSpace(req.Nama)
+	if req.Nama == "" {
+		return fmt.Errorf("nama is required")
+	}
+
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = slugify(req.Nama)
+	}
+	if !validSlug.MatchString(slug) {
+		return fmt.Errorf("slug may only contain lowercase letters, digits and dashes")
+	}
+
+	if req.ParentID != nil {
+		if _, err := s.categoryRepo.FindByID(*req.ParentID); err != nil {
+			return fmt.Errorf("parent category not found")
+		}
+	}
+
+	category.ParentID = req.ParentID
+	category.Nama = req.Nama
+	category.Slug = slug
+	category.Keterangan = strings.TrimSpace(req.Keterangan)
+	return nil
+}
+
+// slugify turns a name like "Fiksi & Sastra" into "fiksi-sastra"
+func slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
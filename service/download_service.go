@@ -0,0 +1,42 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type DownloadService interface {
+	GetDownloadHistory(userID int) ([]entity.Download, error)
+	ResetDownloads(req model.ResetDownloadsRequest) (int64, error)
+}
+
+type downloadService struct {
+	downloadRepo repository.DownloadRepository
+}
+
+func NewDownloadService(downloadRepo repository.DownloadRepository) DownloadService {
+	return &downloadService{downloadRepo: downloadRepo}
+}
+
+func (s *downloadService) GetDownloadHistory(userID int) ([]entity.Download, error) {
+	downloads, err := s.downloadRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download history: %v", err)
+	}
+	return downloads, nil
+}
+
+func (s *downloadService) ResetDownloads(req model.ResetDownloadsRequest) (int64, error) {
+	if req.UserID <= 0 {
+		return 0, fmt.Errorf("user_id is required")
+	}
+
+	reset, err := s.downloadRepo.Reset(req.UserID, req.OrderItemID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset downloads: %v", err)
+	}
+	return reset, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxZipEntrySize is the largest EPUB entry read into memory. Package and
+// content documents and covers are far smaller; the cap keeps a compressed
+// bomb from exhausting memory.
+const maxZipEntrySize = 16 << 20
+
+var errZipEntryTooLarge = errors.New("entry is too large")
+
+// epubContainer is the META-INF/container.xml document pointing at the OPF package
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// findOPFPath returns the zip path of the EPUB package document
+func findOPFPath(zr *zip.Reader) (string, error) {
+	data, err := readZipFile(zr, "META-INF/container.xml")
+	if err != nil {
+		return "", fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("invalid EPUB container: %v", err)
+	}
+
+	for _, rootfile := range container.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			return rootfile.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("invalid EPUB: package document not found")
+}
+
+// readZipFile reads a whole entry from the archive, refusing entries larger
+// than maxZipEntrySize. The declared size is checked first and the data is
+// read through a limit, as the declared size may be forged.
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		if f.UncompressedSize64 > maxZipEntrySize {
+			return nil, fmt.Errorf("%s: %w", name, errZipEntryTooLarge)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(io.LimitReader(rc, maxZipEntrySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxZipEntrySize {
+			return nil, fmt.Errorf("%s: %w", name, errZipEntryTooLarge)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// EPUBMetadata is the catalog information found in an EPUB package document
+type EPUBMetadata struct {
+	Title       string   `json:"title"`
+	Creators    []string `json:"creators"`
+	Language    string   `json:"language"`
+	Publisher   string   `json:"publisher"`
+	ISBN        string   `json:"isbn"`
+	Description string   `json:"description"`
+	Subjects    []string `json:"subjects"`
+	Cover       []byte   `json:"-"`
+	CoverType   string   `json:"-"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles       []string `xml:"http://purl.org/dc/elements/1.1/ title"`
+		Creators     []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Languages    []string `xml:"http://purl.org/dc/elements/1.1/ language"`
+		Publishers   []string `xml:"http://purl.org/dc/elements/1.1/ publisher"`
+		Descriptions []string `xml:"http://purl.org/dc/elements/1.1/ description"`
+		Subjects     []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+		Identifiers  []struct {
+			Value  string `xml:",chardata"`
+			Scheme string `xml:"scheme,attr"`
+		} `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+		Metas []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []opfItem `xml:"item"`
+	} `xml:"manifest"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type opfItem struct {
+	ID           string `xml:"id,attr"`
+	Href         string `xml:"href,attr"`
+	MediaType    string `xml:"media-type,attr"`
+	Properties   string `xml:"properties,attr"`
+	Fallback     string `xml:"fallback,attr"`
+	MediaOverlay string `xml:"media-overlay,attr"`
+}
+
+// ExtractEPUBMetadata reads the OPF package metadata and embedded cover image
+func ExtractEPUBMetadata(r io.ReaderAt, size int64) (*EPUBMetadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	opfPath, err := findOPFPath(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readZipFile(zr, opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid EPUB package document: %v", err)
+	}
+
+	meta := &EPUBMetadata{
+		Title:       firstTrimmed(pkg.Metadata.Titles),
+		Creators:    trimAll(pkg.Metadata.Creators),
+		Language:    firstTrimmed(pkg.Metadata.Languages),
+		Publisher:   firstTrimmed(pkg.Metadata.Publishers),
+		Description: plainText(firstTrimmed(pkg.Metadata.Descriptions)),
+		Subjects:    trimAll(pkg.Metadata.Subjects),
+	}
+
+	for _, identifier := range pkg.Metadata.Identifiers {
+		if isbn := identifierISBN(identifier.Value, identifier.Scheme); isbn != "" {
+			meta.ISBN = isbn
+			break
+		}
+	}
+
+	if cover := findCoverItem(&pkg); cover != nil {
+		if data, err := readZipFile(zr, resolveHref(opfPath, cover.Href)); err == nil {
+			meta.Cover = data
+			meta.CoverType = cover.MediaType
+		}
+	}
+
+	return meta, nil
+}
+
+// findCoverItem locates the cover image via the EPUB 3 cover-image property,
+// falling back to the EPUB 2 <meta name="cover"> convention
+func findCoverItem(pkg *opfPackage) *opfItem {
+	for i, item := range pkg.Manifest.Items {
+		for _, property := range strings.Fields(item.Properties) {
+			if property == "cover-image" {
+				return &pkg.Manifest.Items[i]
+			}
+		}
+	}
+
+	for _, meta := range pkg.Metadata.Metas {
+		if meta.Name != "cover" {
+			continue
+		}
+		for i, item := range pkg.Manifest.Items {
+			if item.ID == meta.Content && strings.HasPrefix(item.MediaType, "image/") {
+				return &pkg.Manifest.Items[i]
+			}
+		}
+	}
+	return nil
+}
+
+// identifierISBN returns the ISBN digits of an identifier, or "" if it is not
+// a valid ISBN
+func identifierISBN(value, scheme string) string {
+	value = strings.TrimSpace(value)
+	lower := strings.ToLower(value)
+	switch {
+	case strings.HasPrefix(lower, "urn:isbn:"):
+		value = value[len("urn:isbn:"):]
+	case strings.HasPrefix(lower, "isbn:"):
+		value = value[len("isbn:"):]
+	case strings.EqualFold(scheme, "isbn"):
+	default:
+		return ""
+	}
+
+	value = normalizeISBN(value)
+	if _, err := parseISBN(value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// resolveHref resolves a manifest href relative to the OPF document
+func resolveHref(opfPath, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+// plainText strips markup from descriptions that embed HTML
+func plainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
+
+func firstTrimmed(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
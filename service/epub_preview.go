@@ -0,0 +1,547 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	previewEndPageID   = "ebook-store-preview-end"
+	previewEndPageName = "ebook-store-preview-end.xhtml"
+
+	// minChapterText is the amount of text a spine document needs to count as
+	// a chapter. Shorter documents such as the title page, dedication or
+	// table of contents are kept in the preview without using up a chapter.
+	minChapterText = 500
+
+	// maxEPUBPreviewScan caps the content documents decompressed while
+	// looking for chapters
+	maxEPUBPreviewScan = 256 << 20
+)
+
+var (
+	opfItemTag       = regexp.MustCompile(`<([A-Za-z_][\w.-]*:)?item\s[^>]*?(/>|>\s*</([A-Za-z_][\w.-]*:)?item\s*>)`)
+	opfItemrefTag    = regexp.MustCompile(`<([A-Za-z_][\w.-]*:)?itemref\s[^>]*?(/>|>\s*</([A-Za-z_][\w.-]*:)?itemref\s*>)`)
+	opfReferenceTag  = regexp.MustCompile(`<([A-Za-z_][\w.-]*:)?reference\s[^>]*?(/>|>\s*</([A-Za-z_][\w.-]*:)?reference\s*>)`)
+	opfSpineClose    = regexp.MustCompile(`</([A-Za-z_][\w.-]*:)?spine\s*>`)
+	xmlIDAttr        = regexp.MustCompile(`\sid\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	xmlIDRefAttr     = regexp.MustCompile(`\sidref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	xmlHrefAttr      = regexp.MustCompile(`\shref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	xhtmlBodyOpening = regexp.MustCompile(`(?i)<body[\s>]`)
+
+	// References from XHTML, SVG and SMIL attributes and from CSS
+	resourceAttr = regexp.MustCompile(`(?i)\s(?:[\w-]+:)?(?:href|src|poster|data|altimg)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	srcsetAttr   = regexp.MustCompile(`(?i)\ssrcset\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	cssURL       = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)`)
+	cssImport    = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// PreviewEPUB writes a preview of the master EPUB to w holding its first
+// chapters and returns how many chapters were included. Content documents
+// after the cut, and resources only they use, are removed from the archive,
+// not just from the spine, so the rest of the book cannot be recovered from
+// the preview. A preview never contains the whole book: if it has no more
+// than the requested number of chapters, the last one is left out.
+func PreviewEPUB(w io.Writer, master io.ReaderAt, size int64, chapters int, title string) (int, error) {
+	zr, err := zip.NewReader(master, size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	opfPath, err := findOPFPath(zr)
+	if err != nil {
+		return 0, err
+	}
+
+	opf, err := readZipFile(zr, opfPath)
+	if err != nil {
+		return 0, fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	var pkg opfPackage
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return 0, fmt.Errorf("invalid EPUB package document: %v", err)
+	}
+
+	items := make(map[string]opfItem)
+	for _, item := range pkg.Manifest.Items {
+		items[item.ID] = item
+	}
+
+	// Spine positions at which a chapter ends
+	var chapterEnds []int
+	var scanned int64
+	for i, ref := range pkg.Spine.Itemrefs {
+		item, ok := items[ref.IDRef]
+		if !ok || !isContentDocument(item.MediaType) || ref.Linear == "no" {
+			continue
+		}
+		doc, err := readZipFile(zr, resolveHref(opfPath, item.Href))
+		if errors.Is(err, errZipEntryTooLarge) {
+			return 0, fmt.Errorf("invalid EPUB: %v", err)
+		}
+		if err != nil {
+			continue
+		}
+		scanned += int64(len(doc))
+		if scanned > maxEPUBPreviewScan {
+			return 0, fmt.Errorf("EPUB content is too large for a preview")
+		}
+		if utf8.RuneCountInString(documentText(doc)) >= minChapterText {
+			chapterEnds = append(chapterEnds, i)
+		}
+	}
+
+	if len(chapterEnds) < 2 {
+		return 0, fmt.Errorf("EPUB has too few chapters for a preview")
+	}
+	if chapters > len(chapterEnds)-1 {
+		chapters = len(chapterEnds) - 1
+	}
+	cut := chapterEnds[chapters-1]
+
+	keptRefs := make(map[string]bool)
+	for _, ref := range pkg.Spine.Itemrefs[:cut+1] {
+		keptRefs[ref.IDRef] = true
+	}
+
+	// The preview holds the kept chapters, the navigation document, the NCX,
+	// the cover and whatever those refer to. Every other manifest item,
+	// including images, audio and fonts used only by later chapters and
+	// content documents outside the spine such as footnote files, is removed
+	// from the manifest and the archive.
+	keptItems, err := previewItems(zr, opfPath, &pkg, keptRefs)
+	if err != nil {
+		return 0, err
+	}
+
+	dropped := make(map[string]bool)
+	droppedPaths := make(map[string]bool)
+	keptPaths := map[string]bool{"mimetype": true, opfPath: true}
+	for _, item := range pkg.Manifest.Items {
+		if keptItems[item.ID] {
+			keptPaths[resolveHref(opfPath, item.Href)] = true
+			continue
+		}
+		dropped[item.ID] = true
+		droppedPaths[resolveHref(opfPath, item.Href)] = true
+	}
+	droppedRefs := make(map[string]bool)
+	for _, ref := range pkg.Spine.Itemrefs[cut+1:] {
+		droppedRefs[ref.IDRef] = true
+	}
+
+	opf, err = previewOPF(opf, opfPath, dropped, droppedRefs, droppedPaths)
+	if err != nil {
+		return 0, err
+	}
+
+	// The NCX and the navigation document list the whole book; entries
+	// pointing at removed documents are pruned
+	tocs := make(map[string][]byte)
+	for _, item := range pkg.Manifest.Items {
+		entries := navEntries
+		switch {
+		case !keptItems[item.ID]:
+			continue
+		case item.MediaType == ncxMediaType:
+			entries = ncxEntries
+		case !hasProperty(item.Properties, "nav"):
+			continue
+		}
+
+		itemPath := resolveHref(opfPath, item.Href)
+		doc, err := readZipFile(zr, itemPath)
+		if errors.Is(err, errZipEntryTooLarge) {
+			return 0, fmt.Errorf("invalid EPUB: %v", err)
+		}
+		if err != nil {
+			continue
+		}
+		if tocs[itemPath], err = previewTOC(doc, itemPath, droppedPaths, entries); err != nil {
+			return 0, err
+		}
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must come first and stay uncompressed
+	for _, f := range zr.File {
+		if f.Name == "mimetype" {
+			if err := zw.Copy(f); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for _, f := range zr.File {
+		toc, isTOC := tocs[f.Name]
+		switch {
+		case f.Name == "mimetype":
+			continue
+		case f.Name == opfPath:
+			if err := writeZipEntry(zw, f.Name, opf); err != nil {
+				return 0, err
+			}
+		case isTOC:
+			if err := writeZipEntry(zw, f.Name, toc); err != nil {
+				return 0, err
+			}
+		case keptPaths[f.Name], strings.HasPrefix(f.Name, "META-INF/"):
+			if err := zw.Copy(f); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	pagePath := path.Join(path.Dir(opfPath), previewEndPageName)
+	if err := writeZipEntry(zw, pagePath, previewEndPage(title)); err != nil {
+		return 0, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	return chapters, nil
+}
+
+// previewOPF removes the dropped items from the manifest, the dropped
+// itemrefs and items from the spine and the dropped documents from the guide,
+// and appends the end-of-preview page. Like watermarkOPF it edits the
+// document textually to keep the publisher's formatting.
+func previewOPF(opf []byte, opfPath string, dropped, droppedRefs, droppedPaths map[string]bool) ([]byte, error) {
+	out := opfItemTag.ReplaceAllFunc(opf, func(tag []byte) []byte {
+		if dropped[attrValue(xmlIDAttr, tag)] {
+			return nil
+		}
+		return tag
+	})
+	out = opfItemrefTag.ReplaceAllFunc(out, func(tag []byte) []byte {
+		idref := attrValue(xmlIDRefAttr, tag)
+		if dropped[idref] || droppedRefs[idref] {
+			return nil
+		}
+		return tag
+	})
+	out = opfReferenceTag.ReplaceAllFunc(out, func(tag []byte) []byte {
+		href := attrValue(xmlHrefAttr, tag)
+		if i := strings.IndexByte(href, '#'); i >= 0 {
+			href = href[:i]
+		}
+		if droppedPaths[resolveHref(opfPath, href)] {
+			return nil
+		}
+		return tag
+	})
+
+	manifest := opfManifestClose.FindSubmatchIndex(out)
+	spine := opfSpineClose.FindSubmatchIndex(out)
+	if manifest == nil || spine == nil {
+		return nil, fmt.Errorf("invalid EPUB: malformed package document")
+	}
+
+	prefix := func(loc []int) string {
+		if loc[2] < 0 {
+			return ""
+		}
+		return string(out[loc[2]:loc[3]])
+	}
+
+	manifestItem := fmt.Sprintf(`<%sitem id="%s" href="%s" media-type="application/xhtml+xml"/>`,
+		prefix(manifest), previewEndPageID, previewEndPageName)
+	spineItem := fmt.Sprintf(`<%sitemref idref="%s"/>`, prefix(spine), previewEndPageID)
+
+	// The spine follows the manifest, so insert into the spine first
+	result := append([]byte(nil), out[:spine[0]]...)
+	result = append(result, spineItem...)
+	result = append(result, out[spine[0]:]...)
+	result = append(result[:manifest[0]], append([]byte(manifestItem), result[manifest[0]:]...)...)
+	return result, nil
+}
+
+// previewItems returns the IDs of the manifest items the preview keeps: the
+// kept spine documents, the navigation document, the NCX, the cover, the
+// fallbacks and media overlays of kept items and every resource a kept
+// document, style sheet, SVG image or media overlay refers to. Content
+// documents are only kept through the spine, so a link to a later chapter
+// does not bring it back.
+func previewItems(zr *zip.Reader, opfPath string, pkg *opfPackage, keptRefs map[string]bool) (map[string]bool, error) {
+	byID := make(map[string]opfItem)
+	byPath := make(map[string]opfItem)
+	for _, item := range pkg.Manifest.Items {
+		byID[item.ID] = item
+		byPath[resolveHref(opfPath, item.Href)] = item
+	}
+
+	kept := make(map[string]bool)
+	var queue []opfItem
+	keep := func(item opfItem) {
+		if item.ID == "" || kept[item.ID] {
+			return
+		}
+		kept[item.ID] = true
+		queue = append(queue, item)
+	}
+
+	for _, item := range pkg.Manifest.Items {
+		if keptRefs[item.ID] || hasProperty(item.Properties, "nav") ||
+			item.ID == pkg.Spine.Toc || item.MediaType == ncxMediaType {
+			keep(item)
+		}
+	}
+	if cover := findCoverItem(pkg); cover != nil {
+		keep(*cover)
+	}
+
+	var scanned int64
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+
+		for _, id := range []string{item.Fallback, item.MediaOverlay} {
+			if fallback, ok := byID[id]; ok {
+				keep(fallback)
+			}
+		}
+
+		// The NCX and the navigation document link to every chapter; what
+		// they keep is decided by the spine
+		if item.MediaType == ncxMediaType || hasProperty(item.Properties, "nav") || !refersToResources(item.MediaType) {
+			continue
+		}
+
+		itemPath := resolveHref(opfPath, item.Href)
+		doc, err := readZipFile(zr, itemPath)
+		if errors.Is(err, errZipEntryTooLarge) {
+			return nil, fmt.Errorf("invalid EPUB: %v", err)
+		}
+		if err != nil {
+			continue
+		}
+		scanned += int64(len(doc))
+		if scanned > maxEPUBPreviewScan {
+			return nil, fmt.Errorf("EPUB content is too large for a preview")
+		}
+
+		for _, ref := range resourceRefs(doc, item.MediaType == "text/css") {
+			resource, ok := byPath[resolveRef(itemPath, ref)]
+			if ok && !isContentDocument(resource.MediaType) {
+				keep(resource)
+			}
+		}
+	}
+	return kept, nil
+}
+
+// refersToResources reports whether documents of mediaType can refer to
+// other files in the archive
+func refersToResources(mediaType string) bool {
+	switch mediaType {
+	case "application/xhtml+xml", "text/html", "text/css", "image/svg+xml", "application/smil+xml":
+		return true
+	}
+	return false
+}
+
+// resourceRefs lists the references in a markup document or, with css set, a
+// style sheet. Markup documents may embed style sheets, so their CSS
+// references are collected too.
+func resourceRefs(doc []byte, css bool) []string {
+	var refs []string
+	collect := func(re *regexp.Regexp) {
+		for _, m := range re.FindAllSubmatch(doc, -1) {
+			for _, group := range m[1:] {
+				if group != nil {
+					refs = append(refs, string(group))
+					break
+				}
+			}
+		}
+	}
+
+	collect(cssURL)
+	collect(cssImport)
+	if css {
+		return refs
+	}
+
+	collect(resourceAttr)
+	for _, m := range srcsetAttr.FindAllSubmatch(doc, -1) {
+		srcset := m[1]
+		if srcset == nil {
+			srcset = m[2]
+		}
+		for _, candidate := range strings.Split(string(srcset), ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				refs = append(refs, fields[0])
+			}
+		}
+	}
+	return refs
+}
+
+// resolveRef resolves a reference made from the document at docPath to an
+// archive path, or "" for external and fragment-only references
+func resolveRef(docPath, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(html.UnescapeString(ref)))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return ""
+	}
+	return path.Join(path.Dir(docPath), u.Path)
+}
+
+// tocEntries names the elements of a table of contents that each point at
+// one document, and where that pointer is
+type tocEntries struct {
+	entries map[string]bool
+	link    string
+	attr    string
+}
+
+const ncxMediaType = "application/x-dtbncx+xml"
+
+var (
+	ncxEntries = tocEntries{
+		entries: map[string]bool{"navPoint": true, "pageTarget": true, "navTarget": true},
+		link:    "content",
+		attr:    "src",
+	}
+	navEntries = tocEntries{
+		entries: map[string]bool{"li": true},
+		link:    "a",
+		attr:    "href",
+	}
+)
+
+// previewTOC returns the table of contents doc at tocPath without the entries
+// that point at dropped documents. An entry is removed with everything nested
+// in it. The rest of the document is copied byte for byte.
+func previewTOC(doc []byte, tocPath string, droppedPaths map[string]bool, toc tocEntries) ([]byte, error) {
+	type entry struct {
+		start   int64
+		depth   int
+		decided bool
+		drop    bool
+	}
+	var open []entry
+	var cuts [][2]int64
+
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+
+	depth := 0
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPUB table of contents %s: %v", tocPath, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if toc.entries[t.Name.Local] {
+				open = append(open, entry{start: start, depth: depth})
+			}
+			if t.Name.Local == toc.link && len(open) > 0 && !open[len(open)-1].decided {
+				last := &open[len(open)-1]
+				last.decided = true
+				for _, attr := range t.Attr {
+					if attr.Name.Local == toc.attr {
+						last.drop = droppedPaths[resolveRef(tocPath, attr.Value)]
+					}
+				}
+			}
+		case xml.EndElement:
+			if len(open) > 0 && open[len(open)-1].depth == depth && toc.entries[t.Name.Local] {
+				last := open[len(open)-1]
+				open = open[:len(open)-1]
+				if last.drop {
+					cuts = append(cuts, [2]int64{last.start, d.InputOffset()})
+				}
+			}
+			depth--
+		}
+	}
+
+	// Nested entries end before the entries holding them, so the cuts are
+	// sorted by their start before the ones inside others are skipped
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i][0] < cuts[j][0] })
+	var out []byte
+	var pos int64
+	for _, cut := range cuts {
+		if cut[0] < pos {
+			continue
+		}
+		out = append(out, doc[pos:cut[0]]...)
+		pos = cut[1]
+	}
+	return append(out, doc[pos:]...), nil
+}
+
+func previewEndPage(title string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title>End of preview</title>
+</head>
+<body>
+<div>
+<h2>End of preview</h2>
+<p>You have reached the end of the free preview of %s.</p>
+<p>Buy the book to keep reading.</p>
+</div>
+</body>
+</html>
+`, html.EscapeString(title))
+	return buf.Bytes()
+}
+
+// documentText returns the readable text of an XHTML document's body
+func documentText(doc []byte) string {
+	if loc := xhtmlBodyOpening.FindIndex(doc); loc != nil {
+		doc = doc[loc[0]:]
+	}
+	return plainText(string(doc))
+}
+
+func isContentDocument(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html"
+}
+
+func hasProperty(properties, name string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func attrValue(attr *regexp.Regexp, tag []byte) string {
+	m := attr.FindSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	if m[1] != nil {
+		return string(m[1])
+	}
+	return string(m[2])
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testEPUB builds an EPUB from file contents by archive path. The mimetype
+// and container entries are added, pointing at OEBPS/content.opf.
+func testEPUB(files map[string]string) []byte {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	fw, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	io.WriteString(fw, "application/epub+zip")
+	fw, _ = zw.Create("META-INF/container.xml")
+	io.WriteString(fw, `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`)
+	for name, content := range files {
+		fw, _ := zw.Create(name)
+		io.WriteString(fw, content)
+	}
+	zw.Close()
+	return b.Bytes()
+}
+
+// testChapter returns a chapter long enough to count towards the preview,
+// with extra markup at the start of its body
+func testChapter(n int, markup string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter %d</title>
+<link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>%s<p>CHAPTER-%d %s</p></body></html>`, n, markup, n, strings.Repeat("lorem ipsum ", 60))
+}
+
+// testIllustratedBook returns a four chapter book. Chapter 1 uses an image, a
+// style sheet and through it a font; chapter 4 uses an image, an SVG
+// drawing, audio and a video poster; chapter 2 links to a footnote file
+// outside the spine. The NCX and the navigation document list every
+// chapter.
+func testIllustratedBook() []byte {
+	return testEPUB(map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Test</dc:title><meta name="cover" content="cover"/></metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+<item id="cover" href="images/cover.jpg" media-type="image/jpeg"/>
+<item id="css" href="style.css" media-type="text/css"/>
+<item id="font" href="fonts/serif.otf" media-type="font/otf"/>
+<item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch2" href="ch2.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch3" href="ch3.xhtml" media-type="application/xhtml+xml"/>
+<item id="ch4" href="ch4.xhtml" media-type="application/xhtml+xml"/>
+<item id="notes" href="notes.xhtml" media-type="application/xhtml+xml"/>
+<item id="img1" href="images/map.png" media-type="image/png"/>
+<item id="img4" href="images/secret%20plan.png" media-type="image/png"/>
+<item id="svg4" href="images/diagram.svg" media-type="image/svg+xml"/>
+<item id="svgimg4" href="images/inside-diagram.png" media-type="image/png"/>
+<item id="audio4" href="audio/finale.mp3" media-type="audio/mpeg"/>
+<item id="poster4" href="images/poster.jpg" media-type="image/jpeg"/>
+</manifest>
+<spine toc="ncx">
+<itemref idref="ch1"/><itemref idref="ch2"/><itemref idref="ch3"/><itemref idref="ch4"/>
+</spine>
+<guide><reference type="text" href="ch1.xhtml"/><reference type="notes" href="notes.xhtml#n1"/></guide>
+</package>`,
+		"OEBPS/nav.xhtml": `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>Contents</title></head>
+<body><nav epub:type="toc"><ol>
+<li><a href="ch1.xhtml">NAV-ONE</a></li>
+<li><a href="ch2.xhtml">NAV-TWO</a><ol><li><a href="ch3.xhtml#s1">NAV-THREE-NESTED</a></li></ol></li>
+<li><a href="ch4.xhtml">NAV-FOUR</a></li>
+</ol></nav></body></html>`,
+		"OEBPS/toc.ncx": `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1"><head/><docTitle><text>Test</text></docTitle>
+<navMap>
+<navPoint id="p1" playOrder="1"><navLabel><text>NCX-ONE</text></navLabel><content src="ch1.xhtml"/></navPoint>
+<navPoint id="p2" playOrder="2"><navLabel><text>NCX-TWO</text></navLabel><content src="ch2.xhtml"/>
+<navPoint id="p3" playOrder="3"><navLabel><text>NCX-THREE-NESTED</text></navLabel><content src="ch3.xhtml#s1"/></navPoint>
+</navPoint>
+<navPoint id="p4" playOrder="4"><navLabel><text>NCX-FOUR</text></navLabel><content src="ch4.xhtml"/></navPoint>
+</navMap>
+<pageList><pageTarget type="normal" value="1"><navLabel><text>PAGE-ONE</text></navLabel><content src="ch1.xhtml#p1"/></pageTarget>
+<pageTarget type="normal" value="99"><navLabel><text>PAGE-NINETY-NINE</text></navLabel><content src="ch4.xhtml#p99"/></pageTarget></pageList>
+</ncx>`,
+		"OEBPS/style.css":                 `@font-face { font-family: Serif; src: url("fonts/serif.otf"); }`,
+		"OEBPS/fonts/serif.otf":           "FONT-DATA",
+		"OEBPS/images/cover.jpg":          "COVER-DATA",
+		"OEBPS/images/map.png":            "MAP-DATA",
+		"OEBPS/images/secret plan.png":    "SECRET-PLAN-DATA",
+		"OEBPS/images/diagram.svg":        `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><image xlink:href="inside-diagram.png"/></svg>`,
+		"OEBPS/images/inside-diagram.png": "INSIDE-DIAGRAM-DATA",
+		"OEBPS/images/poster.jpg":         "POSTER-DATA",
+		"OEBPS/audio/finale.mp3":          "FINALE-AUDIO-DATA",
+		"OEBPS/stray.txt":                 "STRAY-DATA",
+		"OEBPS/ch1.xhtml":                 testChapter(1, `<img src="images/map.png" alt=""/>`),
+		"OEBPS/ch2.xhtml":                 testChapter(2, `<a href="notes.xhtml#n1">1</a> <a href="ch4.xhtml">later</a>`),
+		"OEBPS/ch3.xhtml":                 testChapter(3, ""),
+		"OEBPS/ch4.xhtml": testChapter(4, `<img src="images/secret%20plan.png" alt=""/>`+
+			`<img src="images/diagram.svg" alt=""/><audio src="audio/finale.mp3"/><video poster="images/poster.jpg"/>`),
+		"OEBPS/notes.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><body><p id="n1">FOOTNOTE-TEXT</p></body></html>`,
+	})
+}
+
+// readTestArchive returns the entries of a zip archive by name
+func readTestArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading preview: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestPreviewEPUB(t *testing.T) {
+	tests := []struct {
+		name         string
+		chapters     int
+		wantChapters int
+		wantFiles    []string
+		notWantFiles []string
+		want         []string
+		notWant      []string
+	}{
+		{
+			name:         "resources of later chapters are removed",
+			chapters:     1,
+			wantChapters: 1,
+			wantFiles: []string{
+				"OEBPS/ch1.xhtml", "OEBPS/nav.xhtml", "OEBPS/toc.ncx", "OEBPS/style.css",
+				"OEBPS/fonts/serif.otf", "OEBPS/images/cover.jpg", "OEBPS/images/map.png",
+			},
+			notWantFiles: []string{
+				"OEBPS/ch2.xhtml", "OEBPS/ch3.xhtml", "OEBPS/ch4.xhtml", "OEBPS/notes.xhtml",
+				"OEBPS/images/secret plan.png", "OEBPS/images/diagram.svg", "OEBPS/images/inside-diagram.png",
+				"OEBPS/audio/finale.mp3", "OEBPS/images/poster.jpg", "OEBPS/stray.txt",
+			},
+			want: []string{"NCX-ONE", "PAGE-ONE", "NAV-ONE", `href="images/map.png"`, "ebook-store-preview-end"},
+			notWant: []string{
+				"NCX-TWO", "NCX-FOUR", "PAGE-NINETY-NINE", "NAV-TWO", "NAV-FOUR",
+				"ch2.xhtml", "ch4.xhtml", "secret%20plan.png", "diagram.svg", "finale.mp3", "poster.jpg", "notes.xhtml",
+			},
+		},
+		{
+			name:         "never the whole book",
+			chapters:     10,
+			wantChapters: 3,
+			wantFiles:    []string{"OEBPS/ch1.xhtml", "OEBPS/ch2.xhtml", "OEBPS/ch3.xhtml", "OEBPS/images/map.png"},
+			notWantFiles: []string{
+				"OEBPS/ch4.xhtml", "OEBPS/notes.xhtml", "OEBPS/images/secret plan.png", "OEBPS/images/diagram.svg",
+				"OEBPS/images/inside-diagram.png", "OEBPS/audio/finale.mp3", "OEBPS/images/poster.jpg",
+			},
+			want:    []string{"NCX-TWO", "NCX-THREE-NESTED", "NAV-THREE-NESTED"},
+			notWant: []string{"NCX-FOUR", "NAV-FOUR", "PAGE-NINETY-NINE", "finale.mp3"},
+		},
+	}
+
+	master := testIllustratedBook()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			n, err := PreviewEPUB(&out, bytes.NewReader(master), int64(len(master)), tt.chapters, "Test")
+			if err != nil {
+				t.Fatalf("PreviewEPUB() error = %v", err)
+			}
+			if n != tt.wantChapters {
+				t.Errorf("PreviewEPUB() = %d chapters, want %d", n, tt.wantChapters)
+			}
+
+			files := readTestArchive(t, out.Bytes())
+			for _, name := range tt.wantFiles {
+				if _, ok := files[name]; !ok {
+					t.Errorf("preview does not contain %s", name)
+				}
+			}
+			for _, name := range tt.notWantFiles {
+				if _, ok := files[name]; ok {
+					t.Errorf("preview contains %s", name)
+				}
+			}
+
+			// The package document and the tables of contents must not
+			// mention what was removed
+			text := files["OEBPS/content.opf"] + files["OEBPS/toc.ncx"] + files["OEBPS/nav.xhtml"]
+			for _, s := range tt.want {
+				if !strings.Contains(text, s) && !strings.Contains(files["OEBPS/ch1.xhtml"], s) {
+					t.Errorf("preview does not contain %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(text, s) {
+					t.Errorf("preview contains %q", s)
+				}
+			}
+
+			// Every manifest item must still be in the archive
+			metadata, err := ExtractEPUBMetadata(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("reading preview metadata: %v", err)
+			}
+			if string(metadata.Cover) != "COVER-DATA" {
+				t.Errorf("preview cover = %q, want COVER-DATA", metadata.Cover)
+			}
+		})
+	}
+}
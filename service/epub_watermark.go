@@ -0,0 +1,172 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	watermarkPageID   = "ebook-store-copyright"
+	watermarkPageName = "ebook-store-copyright.xhtml"
+)
+
+var (
+	opfMetadataClose = regexp.MustCompile(`</([A-Za-z_][\w.-]*:)?metadata\s*>`)
+	opfManifestClose = regexp.MustCompile(`</([A-Za-z_][\w.-]*:)?manifest\s*>`)
+	opfSpineOpen     = regexp.MustCompile(`<([A-Za-z_][\w.-]*:)?spine\b[^>]*>`)
+)
+
+// Watermark identifies the buyer a personalised copy is issued to
+type Watermark struct {
+	Username string
+	Email    string
+	OrderID  int
+	IssuedAt time.Time
+}
+
+// WatermarkEPUB streams a personalised copy of the master EPUB to w. A
+// copyright page naming the buyer is added at the start of the spine and the
+// buyer identity is stored as metadata in the OPF package. Every other entry
+// is copied unchanged, so the master file on disk is never modified.
+func WatermarkEPUB(w io.Writer, master io.ReaderAt, size int64, mark Watermark) error {
+	zr, err := zip.NewReader(master, size)
+	if err != nil {
+		return fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	opfPath, err := findOPFPath(zr)
+	if err != nil {
+		return err
+	}
+
+	opf, err := readZipFile(zr, opfPath)
+	if err != nil {
+		return fmt.Errorf("invalid EPUB: %v", err)
+	}
+
+	opf, err = watermarkOPF(opf, mark)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must come first and stay uncompressed
+	for _, f := range zr.File {
+		if f.Name == "mimetype" {
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range zr.File {
+		switch f.Name {
+		case "mimetype":
+			continue
+		case opfPath:
+			if err := writeZipEntry(zw, f.Name, opf); err != nil {
+				return err
+			}
+		default:
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	pagePath := path.Join(path.Dir(opfPath), watermarkPageName)
+	if err := writeZipEntry(zw, pagePath, watermarkPage(mark)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// watermarkOPF adds buyer metadata, the copyright page manifest item and its
+// spine entry to the package document. The document is edited textually so
+// namespaces and formatting of the publisher's OPF are preserved.
+func watermarkOPF(opf []byte, mark Watermark) ([]byte, error) {
+	metadata := opfMetadataClose.FindSubmatchIndex(opf)
+	manifest := opfManifestClose.FindSubmatchIndex(opf)
+	spine := opfSpineOpen.FindSubmatchIndex(opf)
+	if metadata == nil || manifest == nil || spine == nil {
+		return nil, fmt.Errorf("invalid EPUB: malformed package document")
+	}
+
+	prefix := func(loc []int) string {
+		if loc[2] < 0 {
+			return ""
+		}
+		return string(opf[loc[2]:loc[3]])
+	}
+
+	meta := prefix(metadata) + "meta"
+	metaTags := fmt.Sprintf(
+		`<%[1]s name="ebook-store:buyer" content="%[2]s"/><%[1]s name="ebook-store:buyer-email" content="%[3]s"/><%[1]s name="ebook-store:order-id" content="%[4]d"/><%[1]s name="ebook-store:issued" content="%[5]s"/>`,
+		meta, html.EscapeString(mark.Username), html.EscapeString(mark.Email),
+		mark.OrderID, mark.IssuedAt.UTC().Format(time.RFC3339),
+	)
+	manifestItem := fmt.Sprintf(`<%sitem id="%s" href="%s" media-type="application/xhtml+xml"/>`,
+		prefix(manifest), watermarkPageID, watermarkPageName)
+	spineItem := fmt.Sprintf(`<%sitemref idref="%s"/>`, prefix(spine), watermarkPageID)
+
+	// Insert from the end of the document backwards so earlier offsets stay valid
+	edits := []struct {
+		at   int
+		text string
+	}{
+		{metadata[0], metaTags},
+		{manifest[0], manifestItem},
+		{spine[1], spineItem},
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].at > edits[j].at })
+
+	out := append([]byte(nil), opf...)
+	for _, edit := range edits {
+		out = append(out[:edit.at], append([]byte(edit.text), out[edit.at:]...)...)
+	}
+	return out, nil
+}
+
+func watermarkPage(mark Watermark) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title>Copyright</title>
+</head>
+<body>
+<div>
+<h2>Licensed copy</h2>
+<p>This ebook is licensed to %s (%s), order #%d, on %s.</p>
+<p>It is for the personal use of the purchaser only. Sharing or redistributing this copy is not permitted.</p>
+</div>
+</body>
+</html>
+`, html.EscapeString(mark.Username), html.EscapeString(mark.Email),
+		mark.OrderID, mark.IssuedAt.UTC().Format("2 January 2006"))
+	return buf.Bytes()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
@@ -0,0 +1,27 @@
+package service
+
+import "errors"
+
+// Errors that controllers map to specific HTTP status codes
+var (
+	ErrBookNotPurchased      = errors.New("book has not been purchased")
+	ErrFormatNotPurchased    = errors.New("this file format is not included in the formats you purchased")
+	ErrInvalidDownloadLink   = errors.New("invalid or tampered download link")
+	ErrDownloadLinkExpired   = errors.New("download link has expired")
+	ErrDownloadLimitExceeded = errors.New("download limit exceeded for this purchase")
+	ErrAnnotationNotFound    = errors.New("annotation not found")
+	ErrStreamNotSupported    = errors.New("this format is personalised per download and cannot be streamed")
+	ErrWatermarkFailed       = errors.New("failed to personalise the book file")
+	ErrInvalidISBN           = errors.New("invalid ISBN")
+	ErrDuplicateISBN         = errors.New("ISBN is already used by another book")
+	ErrNotVerifiedBuyer      = errors.New("only customers with a completed order for this book can review it")
+	ErrReviewExists          = errors.New("you have already reviewed this book")
+	ErrReviewNotFound        = errors.New("review not found")
+	ErrOwnReviewVote         = errors.New("you cannot vote on your own review")
+	ErrPreviewNotFound       = errors.New("this book has no preview")
+	ErrInvalidFormat         = errors.New("format must be one of: epub, pdf, audiobook, print")
+	ErrBookNotFound          = errors.New("book not found")
+	ErrVersionNotFound       = errors.New("book version not found")
+	ErrBookHasVariants       = errors.New("book is sold in several formats; set their price and stock through /api/books/variants")
+	ErrDuplicateVariant      = errors.New("book is already sold in this format")
+)
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// Catalog export formats
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+	ExportFormatONIX  = "onix"
+)
+
+// CatalogExport is a validated export request, ready to be written
+type CatalogExport struct {
+	Format      string
+	ContentType string
+	FileName    string
+
+	filter repository.BookFilter
+}
+
+type ExportService interface {
+	PrepareExport(req model.ExportRequest) (*CatalogExport, error)
+	WriteExport(w io.Writer, export *CatalogExport) error
+}
+
+type exportService struct {
+	bookRepo      repository.BookRepository
+	uploadService UploadService
+}
+
+func NewExportService(bookRepo repository.BookRepository, uploadService UploadService) ExportService {
+	return &exportService{
+		bookRepo:      bookRepo,
+		uploadService: uploadService,
+	}
+}
+
+// PrepareExport validates the format and the book list filters. It is kept
+// apart from WriteExport so errors can still be reported before the
+// response is started. Paging parameters are ignored: every matching book
+// is exported.
+func (s *exportService) PrepareExport(req model.ExportRequest) (*CatalogExport, error) {
+	format := req.Format
+	if format == "" {
+		format = ExportFormatCSV
+	}
+
+	export := &CatalogExport{Format: format}
+	switch format {
+	case ExportFormatCSV:
+		export.ContentType = "text/csv; charset=utf-8"
+	case ExportFormatJSONL:
+		export.ContentType = "application/x-ndjson"
+	case ExportFormatONIX:
+		export.ContentType = "application/xml; charset=utf-8"
+	default:
+		return nil, fmt.Errorf("format must be one of: %s, %s, %s", ExportFormatCSV, ExportFormatJSONL, ExportFormatONIX)
+	}
+
+	list := req.BookListRequest
+	list.Page, list.Limit, list.Cursor = 0, 0, ""
+	filter, err := bookFilterFromRequest(&list)
+	if err != nil {
+		return nil, err
+	}
+	filter.Offset = 0
+	export.filter = filter
+
+	ext := format
+	if format == ExportFormatONIX {
+		ext = "xml"
+	}
+	export.FileName = fmt.Sprintf("catalog-%s.%s", time.Now().Format("20060102-150405"), ext)
+
+	return export, nil
+}
+
+// WriteExport streams the matching books to w one row at a time
+func (s *exportService) WriteExport(w io.Writer, export *CatalogExport) error {
+	bw := bufio.NewWriter(w)
+
+	var err error
+	switch export.Format {
+	case ExportFormatJSONL:
+		err = s.writeJSONL(bw, export.filter)
+	case ExportFormatONIX:
+		err = s.writeONIX(bw, export.filter)
+	default:
+		err = s.writeCSV(bw, export.filter)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export catalog: %v", err)
+	}
+	return bw.Flush()
+}
+
+// csvExportHeader uses the column names accepted by the CSV import, so an
+// export can be edited and imported again
+var csvExportHeader = []string{
+	"id", "isbn_13", "isbn_10", "nama_barang", "penulis", "penerbit", "bahasa",
+	"subjek", "kategori", "harga", "stok", "terjual", "gambar_buku",
+	"keterangan", "created_at", "updated_at",
+}
+
+func (s *exportService) writeCSV(w io.Writer, filter repository.BookFilter) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvExportHeader); err != nil {
+		return err
+	}
+
+	err := s.bookRepo.Stream(filter, func(book *entity.Book) error {
+		return cw.Write([]string{
+			strconv.Itoa(book.ID),
+			book.ISBN13,
+			book.ISBN10,
+			book.NamaBarang,
+			book.Penulis,
+			book.Penerbit,
+			book.Bahasa,
+			book.Subjek,
+			strings.Join(categoryNames(book.Categories), "|"),
+			strconv.Itoa(book.Harga),
+			strconv.Itoa(book.Stok),
+			strconv.Itoa(book.Terjual),
+			s.uploadService.GetImageURL(book.GambarBuku),
+			book.Keterangan,
+			book.CreatedAt.Format(time.RFC3339),
+			book.UpdatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *exportService) writeJSONL(w io.Writer, filter repository.BookFilter) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	return s.bookRepo.Stream(filter, func(book *entity.Book) error {
+		row := model.BookExportRow{
+			ID:           book.ID,
+			ISBN10:       book.ISBN10,
+			ISBN13:       book.ISBN13,
+			NamaBarang:   book.NamaBarang,
+			Penulis:      book.Penulis,
+			Penerbit:     book.Penerbit,
+			Bahasa:       book.Bahasa,
+			Subjek:       book.Subjek,
+			Keterangan:   book.Keterangan,
+			Harga:        book.Harga,
+			Stok:         book.Stok,
+			Terjual:      book.Terjual,
+			Categories:   categoryNames(book.Categories),
+			Contributors: book.Contributors,
+			ImageURL:     s.uploadService.GetImageURL(book.GambarBuku),
+			CreatedAt:    book.CreatedAt,
+			UpdatedAt:    book.UpdatedAt,
+		}
+		if row.Contributors == nil {
+			row.Contributors = []entity.BookContributor{}
+		}
+		return encoder.Encode(row)
+	})
+}
+
+// writeONIX writes an ONIX 3.0 message product by product, so the whole
+// message is never held in memory
+func (s *exportService) writeONIX(w io.Writer, filter repository.BookFilter) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	message := xml.StartElement{
+		Name: xml.Name{Local: "ONIXMessage"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "release"}, Value: "3.0"},
+			{Name: xml.Name{Local: "xmlns"}, Value: model.ONIXNamespace},
+		},
+	}
+	if err := encoder.EncodeToken(message); err != nil {
+		return err
+	}
+
+	header := model.ONIXHeader{
+		Sender:       model.ONIXSender{SenderName: onixSenderName},
+		SentDateTime: time.Now().UTC().Format("20060102T1504Z"),
+	}
+	if err := encoder.EncodeElement(header, xml.StartElement{Name: xml.Name{Local: "Header"}}); err != nil {
+		return err
+	}
+
+	product := xml.StartElement{Name: xml.Name{Local: "Product"}}
+	err := s.bookRepo.Stream(filter, func(book *entity.Book) error {
+		return encoder.EncodeElement(s.onixProduct(book), product)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := encoder.EncodeToken(message.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func categoryNames(categories []entity.Category) []string {
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Nama)
+	}
+	return names
+}
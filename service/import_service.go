@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// MaxImportFileSize is the largest catalog file an admin may upload (50MB)
+const MaxImportFileSize = 50 << 20
+
+const (
+	defaultImportChunkSize = 500
+	maxImportChunkSize     = 5000
+)
+
+type ImportService interface {
+	StartImport(userID int, file multipart.File, header *multipart.FileHeader, req model.ImportRequest) (*entity.ImportJob, error)
+	GetImportJobs() ([]entity.ImportJob, error)
+	GetImportJob(id int) (*entity.ImportJob, error)
+	ResumeImport(id int) (*entity.ImportJob, error)
+}
+
+type importService struct {
+	importRepo       repository.ImportRepository
+	notificationRepo repository.NotificationRepository
+	storeDir         string
+
+	mu      sync.Mutex
+	running map[int]bool
+}
+
+func NewImportService(importRepo repository.ImportRepository, notificationRepo repository.NotificationRepository, storeDir string) ImportService {
+	return &importService{
+		importRepo:       importRepo,
+		notificationRepo: notificationRepo,
+		storeDir:         storeDir,
+		running:          map[int]bool{},
+	}
+}
+
+// StartImport stores the uploaded file, parses it up front so malformed
+// files are rejected immediately, and applies the records in the background.
+// Progress and row errors are read back with GetImportJob.
+func (s *importService) StartImport(userID int, file multipart.File, header *multipart.FileHeader, req model.ImportRequest) (*entity.ImportJob, error) {
+	if header.Size > MaxImportFileSize {
+		return nil, fmt.Errorf("file size exceeds 50MB limit")
+	}
+
+	format := req.Format
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".csv":
+			format = entity.ImportFormatCSV
+		case ".xml", ".onix":
+			format = entity.ImportFormatONIX
+		default:
+			return nil, fmt.Errorf("cannot tell the format from the file name, set format to csv or onix")
+		}
+	}
+	if format != entity.ImportFormatCSV && format != entity.ImportFormatONIX {
+		return nil, fmt.Errorf("format must be csv or onix")
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = entity.ImportModeTransaction
+	}
+	if mode != entity.ImportModeTransaction && mode != entity.ImportModeChunked {
+		return nil, fmt.Errorf("mode must be transaction or chunked")
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultImportChunkSize
+	}
+	if chunkSize > maxImportChunkSize {
+		return nil, fmt.Errorf("chunk_size must be at most %d", maxImportChunkSize)
+	}
+
+	if err := os.MkdirAll(s.storeDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %v", err)
+	}
+
+	filename := fmt.Sprintf("%d_%s.%s", time.Now().UnixNano(), generateRandomString(8), format)
+	filePath := filepath.Join(s.storeDir, filename)
+	dst, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import file: %v", err)
+	}
+	_, err = io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to save import file: %v", err)
+	}
+
+	job := &entity.ImportJob{
+		UserID:       userID,
+		Format:       format,
+		FileName:     filename,
+		OriginalName: filepath.Base(header.Filename),
+		Mode:         mode,
+		ChunkSize:    chunkSize,
+		DryRun:       req.DryRun,
+		Status:       entity.ImportStatusPending,
+	}
+
+	records, err := s.readRecords(job)
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+	job.TotalRows = len(records)
+
+	if err := s.importRepo.CreateJob(job); err != nil {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to create import job: %v", err)
+	}
+
+	s.start(job, records)
+	return job, nil
+}
+
+func (s *importService) GetImportJobs() ([]entity.ImportJob, error) {
+	jobs, err := s.importRepo.FindJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import jobs: %v", err)
+	}
+
+	if jobs == nil {
+		jobs = []entity.ImportJob{}
+	}
+	return jobs, nil
+}
+
+func (s *importService) GetImportJob(id int) (*entity.ImportJob, error) {
+	return s.importRepo.FindJobByID(id)
+}
+
+// ResumeImport restarts a job that did not complete, for example after a
+// database error or a server restart. Chunked jobs continue after the last
+// committed chunk; transaction jobs start over.
+func (s *importService) ResumeImport(id int) (*entity.ImportJob, error) {
+	job, err := s.importRepo.FindJobByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == entity.ImportStatusCompleted {
+		return nil, fmt.Errorf("import job has already completed")
+	}
+
+	s.mu.Lock()
+	busy := s.running[job.ID]
+	s.mu.Unlock()
+	if busy {
+		return nil, fmt.Errorf("import job is still running")
+	}
+
+	records, err := s.readRecords(job)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Mode == entity.ImportModeTransaction || job.DryRun {
+		job.ProcessedRows = 0
+		job.CreatedCount = 0
+		job.UpdatedCount = 0
+		job.ErrorCount = 0
+		job.Errors = nil
+	}
+	job.Message = ""
+	job.FinishedAt = nil
+
+	s.start(job, records)
+	return job, nil
+}
+
+func (s *importService) readRecords(job *entity.ImportJob) ([]entity.ImportRecord, error) {
+	f, err := os.Open(filepath.Join(s.storeDir, job.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %v", err)
+	}
+	defer f.Close()
+
+	if job.Format == entity.ImportFormatONIX {
+		return parseONIXImport(f)
+	}
+	return parseCSVImport(f)
+}
+
+func (s *importService) start(job *entity.ImportJob, records []entity.ImportRecord) {
+	s.mu.Lock()
+	s.running[job.ID] = true
+	s.mu.Unlock()
+
+	job.Status = entity.ImportStatusRunning
+	if err := s.importRepo.UpdateJob(job); err != nil {
+		log.Printf("Import job %d: failed to update status: %v", job.ID, err)
+	}
+
+	// Run on a copy so the caller can serialise the job while it is applied
+	running := *job
+	running.Errors = append([]entity.ImportRowError(nil), job.Errors...)
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, running.ID)
+			s.mu.Unlock()
+		}()
+		s.run(&running, records)
+	}()
+}
+
+func (s *importService) run(job *entity.ImportJob, records []entity.ImportRecord) {
+	var err error
+	if job.Mode == entity.ImportModeChunked {
+		err = s.runChunked(job, records)
+	} else {
+		err = s.runTransaction(job, records)
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = entity.ImportStatusFailed
+		job.Message = err.Error()
+	} else {
+		job.Status = entity.ImportStatusCompleted
+	}
+
+	if err := s.importRepo.UpdateJob(job); err != nil {
+		log.Printf("Import job %d: failed to save result: %v", job.ID, err)
+	}
+}
+
+// runTransaction applies every record or none of them
+func (s *importService) runTransaction(job *entity.ImportJob, records []entity.ImportRecord) error {
+	changes, committed, err := s.importRepo.ApplyBatch(job, records, !job.DryRun, true)
+	if err != nil {
+		return fmt.Errorf("import failed, nothing was imported: %v", err)
+	}
+	s.notifyChanges(changes)
+
+	if job.ErrorCount > 0 {
+		if !job.DryRun {
+			job.CreatedCount = 0
+			job.UpdatedCount = 0
+		}
+		return fmt.Errorf("%d rows failed, nothing was imported", job.ErrorCount)
+	}
+	if !committed && !job.DryRun {
+		return fmt.Errorf("import was not committed")
+	}
+	return nil
+}
+
+// runChunked commits the records chunk by chunk, skipping rows that fail.
+// The job progress is committed with each chunk, so a failed job resumes
+// after the last committed chunk.
+func (s *importService) runChunked(job *entity.ImportJob, records []entity.ImportRecord) error {
+	for start := job.ProcessedRows; start < len(records); start += job.ChunkSize {
+		end := start + job.ChunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		before := *job
+		changes, _, err := s.importRepo.ApplyBatch(job, records[start:end], !job.DryRun, false)
+		if err != nil {
+			*job = before
+			return fmt.Errorf("import stopped after row %d of %d, resume to continue: %v", start, len(records), err)
+		}
+		s.notifyChanges(changes)
+
+		if job.DryRun {
+			if err := s.importRepo.UpdateJob(job); err != nil {
+				log.Printf("Import job %d: failed to save progress: %v", job.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// notifyChanges tells wishlisters about price drops and restocks made by a
+// committed batch
+func (s *importService) notifyChanges(changes []entity.BookChange) {
+	for _, change := range changes {
+		notifyWishlisters(s.notificationRepo, change.BookID, change.Before, change.After)
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizeISBN strips the hyphens and spaces ISBNs are usually printed with
+func normalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// validateISBN10 checks the format and mod 11 check digit of a normalized
+// ISBN-10. The check digit may be X (10).
+func validateISBN10(isbn string) error {
+	if len(isbn) != 10 {
+		return fmt.Errorf("%w: isbn_10 %q must have 10 characters, got %d", ErrInvalidISBN, isbn, len(isbn))
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		if isbn[i] < '0' || isbn[i] > '9' {
+			return fmt.Errorf("%w: isbn_10 %q has a non-digit at position %d", ErrInvalidISBN, isbn, i+1)
+		}
+		sum += int(isbn[i]-'0') * (10 - i)
+	}
+
+	expected := isbn10CheckDigit(sum)
+	if isbn[9] != expected {
+		return fmt.Errorf("%w: isbn_10 %q has check digit %c, expected %c", ErrInvalidISBN, isbn, isbn[9], expected)
+	}
+	return nil
+}
+
+// validateISBN13 checks the format, EAN prefix and mod 10 check digit of a
+// normalized ISBN-13
+func validateISBN13(isbn string) error {
+	if len(isbn) != 13 {
+		return fmt.Errorf("%w: isbn_13 %q must have 13 digits, got %d", ErrInvalidISBN, isbn, len(isbn))
+	}
+	for i := 0; i < 13; i++ {
+		if isbn[i] < '0' || isbn[i] > '9' {
+			return fmt.Errorf("%w: isbn_13 %q has a non-digit at position %d", ErrInvalidISBN, isbn, i+1)
+		}
+	}
+	if !strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979") {
+		return fmt.Errorf("%w: isbn_13 %q must start with 978 or 979", ErrInvalidISBN, isbn)
+	}
+
+	expected := isbn13CheckDigit(isbn[:12])
+	if isbn[12] != expected {
+		return fmt.Errorf("%w: isbn_13 %q has check digit %c, expected %c", ErrInvalidISBN, isbn, isbn[12], expected)
+	}
+	return nil
+}
+
+func isbn10CheckDigit(weightedSum int) byte {
+	check := (11 - weightedSum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+func isbn13CheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		digit := int(first12[i] - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// isbn10To13 converts a valid ISBN-10 to its 978-prefixed ISBN-13
+func isbn10To13(isbn10 string) string {
+	first12 := "978" + isbn10[:9]
+	return first12 + string(isbn13CheckDigit(first12))
+}
+
+// isbn13To10 converts a valid ISBN-13 to ISBN-10. Only 978-prefixed ISBNs
+// have an ISBN-10 form.
+func isbn13To10(isbn13 string) (string, bool) {
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+
+	first9 := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(first9[i]-'0') * (10 - i)
+	}
+	return first9 + string(isbn10CheckDigit(sum)), true
+}
+
+// resolveISBNs validates the ISBNs of a book and fills in whichever form is
+// missing. Both may be empty; when both are given they must be the same book.
+func resolveISBNs(isbn10, isbn13 string) (string, string, error) {
+	isbn10 = normalizeISBN(isbn10)
+	isbn13 = normalizeISBN(isbn13)
+
+	if isbn10 != "" {
+		if err := validateISBN10(isbn10); err != nil {
+			return "", "", err
+		}
+	}
+	if isbn13 != "" {
+		if err := validateISBN13(isbn13); err != nil {
+			return "", "", err
+		}
+	}
+
+	switch {
+	case isbn10 != "" && isbn13 == "":
+		isbn13 = isbn10To13(isbn10)
+	case isbn13 != "" && isbn10 == "":
+		isbn10, _ = isbn13To10(isbn13)
+	case isbn10 != "" && isbn13 != "":
+		if converted := isbn10To13(isbn10); converted != isbn13 {
+			return "", "", fmt.Errorf("%w: isbn_10 %q corresponds to isbn_13 %q, not %q", ErrInvalidISBN, isbn10, converted, isbn13)
+		}
+	}
+
+	return isbn10, isbn13, nil
+}
+
+// parseISBN validates an ISBN of either length and returns its ISBN-13 form,
+// which every book with an ISBN has
+func parseISBN(isbn string) (string, error) {
+	isbn = normalizeISBN(isbn)
+	switch len(isbn) {
+	case 10:
+		if err := validateISBN10(isbn); err != nil {
+			return "", err
+		}
+		return isbn10To13(isbn), nil
+	case 13:
+		if err := validateISBN13(isbn); err != nil {
+			return "", err
+		}
+		return isbn, nil
+	default:
+		return "", fmt.Errorf("%w: %q must have 10 or 13 characters, got %d", ErrInvalidISBN, isbn, len(isbn))
+	}
+}
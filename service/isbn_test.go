@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateISBN10(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn    string
+		wantErr bool
+	}{
+		{name: "valid", isbn: "0306406152"},
+		{name: "X check digit", isbn: "080442957X"},
+		{name: "wrong check digit", isbn: "0306406153", wantErr: true},
+		{name: "X where a digit is expected", isbn: "030640615X", wantErr: true},
+		{name: "digit where X is expected", isbn: "0804429570", wantErr: true},
+		{name: "X before the check digit", isbn: "08044295X7", wantErr: true},
+		{name: "too short", isbn: "030640615", wantErr: true},
+		{name: "not normalized", isbn: "0-306-40615-2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateISBN10(tt.isbn)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateISBN10(%q) error = %v, wantErr %v", tt.isbn, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidISBN) {
+				t.Errorf("validateISBN10(%q) error = %v, want ErrInvalidISBN", tt.isbn, err)
+			}
+		})
+	}
+}
+
+func TestValidateISBN13(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn    string
+		wantErr bool
+	}{
+		{name: "978 prefix", isbn: "9780306406157"},
+		{name: "979 prefix", isbn: "9791090636071"},
+		{name: "wrong check digit", isbn: "9780306406158", wantErr: true},
+		{name: "not a book EAN", isbn: "9770306406150", wantErr: true},
+		{name: "X check digit", isbn: "978030640615X", wantErr: true},
+		{name: "too long", isbn: "97803064061570", wantErr: true},
+		{name: "not normalized", isbn: "978-0-306-40615-7", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateISBN13(tt.isbn)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateISBN13(%q) error = %v, wantErr %v", tt.isbn, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidISBN) {
+				t.Errorf("validateISBN13(%q) error = %v, want ErrInvalidISBN", tt.isbn, err)
+			}
+		})
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	tests := []struct {
+		isbn10 string
+		isbn13 string
+	}{
+		{isbn10: "0306406152", isbn13: "9780306406157"},
+		{isbn10: "080442957X", isbn13: "9780804429573"},
+		{isbn10: "0198526636", isbn13: "9780198526636"},
+	}
+
+	for _, tt := range tests {
+		if got := isbn10To13(tt.isbn10); got != tt.isbn13 {
+			t.Errorf("isbn10To13(%q) = %q, want %q", tt.isbn10, got, tt.isbn13)
+		}
+		if got, ok := isbn13To10(tt.isbn13); !ok || got != tt.isbn10 {
+			t.Errorf("isbn13To10(%q) = %q, %v, want %q, true", tt.isbn13, got, ok, tt.isbn10)
+		}
+	}
+
+	if got, ok := isbn13To10("9791090636071"); ok {
+		t.Errorf("isbn13To10 of a 979 ISBN = %q, want no ISBN-10", got)
+	}
+}
+
+func TestResolveISBNs(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn10  string
+		isbn13  string
+		want10  string
+		want13  string
+		wantErr bool
+	}{
+		{name: "both empty"},
+		{name: "isbn_10 only", isbn10: "0306406152", want10: "0306406152", want13: "9780306406157"},
+		{name: "isbn_13 only", isbn13: "9780306406157", want10: "0306406152", want13: "9780306406157"},
+		{name: "matching pair", isbn10: "0306406152", isbn13: "9780306406157", want10: "0306406152", want13: "9780306406157"},
+		{name: "hyphens and spaces", isbn10: " 0-306-40615-2 ", isbn13: "978 0 306 40615 7", want10: "0306406152", want13: "9780306406157"},
+		{name: "lowercase x", isbn10: "0-8044-2957-x", want10: "080442957X", want13: "9780804429573"},
+		{name: "979 has no isbn_10", isbn13: "979-10-90636-07-1", want13: "9791090636071"},
+		{name: "conflicting pair", isbn10: "0306406152", isbn13: "9780804429573", wantErr: true},
+		{name: "isbn_10 with a 979 isbn_13", isbn10: "0306406152", isbn13: "9791090636071", wantErr: true},
+		{name: "invalid isbn_10", isbn10: "0306406153", isbn13: "9780306406157", wantErr: true},
+		{name: "invalid isbn_13", isbn10: "0306406152", isbn13: "9780306406158", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got10, got13, err := resolveISBNs(tt.isbn10, tt.isbn13)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Fatalf("resolveISBNs() error = %v, want ErrInvalidISBN", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveISBNs() error = %v", err)
+			}
+			if got10 != tt.want10 || got13 != tt.want13 {
+				t.Errorf("resolveISBNs() = %q, %q, want %q, %q", got10, got13, tt.want10, tt.want13)
+			}
+		})
+	}
+}
+
+func TestParseISBN(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn    string
+		want    string
+		wantErr bool
+	}{
+		{name: "isbn_10", isbn: "0306406152", want: "9780306406157"},
+		{name: "isbn_10 with X", isbn: "0-8044-2957-X", want: "9780804429573"},
+		{name: "isbn_13", isbn: "978-0-306-40615-7", want: "9780306406157"},
+		{name: "979 isbn_13", isbn: "979 10 90636 07 1", want: "9791090636071"},
+		{name: "invalid isbn_10", isbn: "0306406153", wantErr: true},
+		{name: "invalid isbn_13", isbn: "9780306406158", wantErr: true},
+		{name: "wrong length", isbn: "97803064061", wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseISBN(tt.isbn)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Fatalf("parseISBN(%q) error = %v, want ErrInvalidISBN", tt.isbn, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseISBN(%q) error = %v", tt.isbn, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseISBN(%q) = %q, want %q", tt.isbn, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type LibraryService interface {
+	GetLibrary(userID int) ([]entity.LibraryItem, error)
+}
+
+type libraryService struct {
+	libraryRepo repository.LibraryRepository
+	baseURL     string
+}
+
+func NewLibraryService(libraryRepo repository.LibraryRepository, baseURL string) LibraryService {
+	return &libraryService{
+		libraryRepo: libraryRepo,
+		baseURL:     baseURL,
+	}
+}
+
+func (s *libraryService) GetLibrary(userID int) ([]entity.LibraryItem, error) {
+	items, err := s.libraryRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get library: %v", err)
+	}
+
+	for i := range items {
+		item := &items[i]
+		files := []entity.LibraryFile{}
+		seen := map[string]bool{}
+		for _, file := range item.Files {
+			if !purchasedFile(item.PurchasedFormats, file.Format) {
+				continue
+			}
+			file.DownloadURL = fmt.Sprintf("%s/api/books/files/download?id=%d", s.baseURL, file.ID)
+			files = append(files, file)
+			if !seen[file.Format] {
+				seen[file.Format] = true
+				item.Formats = append(item.Formats, file.Format)
+			}
+		}
+		item.Files = files
+	}
+
+	if items == nil {
+		items = []entity.LibraryItem{}
+	}
+	return items, nil
+}
+
+// purchasedFile reports whether one of the purchased formats includes files
+// in fileFormat
+func purchasedFile(purchased []string, fileFormat string) bool {
+	for _, format := range purchased {
+		if unlocksBookFile(format, fileFormat) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// notificationLimit caps the number of notifications returned at once
+const notificationLimit = 100
+
+type NotificationService interface {
+	GetNotifications(userID int, unreadOnly bool) ([]entity.Notification, int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) error
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository) NotificationService {
+	return &notificationService{notificationRepo: notificationRepo}
+}
+
+// GetNotifications returns the user's newest notifications and the number
+// of unread ones
+func (s *notificationService) GetNotifications(userID int, unreadOnly bool) ([]entity.Notification, int, error) {
+	notifications, err := s.notificationRepo.FindByUserID(userID, unreadOnly, notificationLimit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get notifications: %v", err)
+	}
+
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %v", err)
+	}
+
+	if notifications == nil {
+		notifications = []entity.Notification{}
+	}
+	return notifications, unread, nil
+}
+
+func (s *notificationService) MarkRead(userID, id int) error {
+	return s.notificationRepo.MarkRead(userID, id)
+}
+
+func (s *notificationService) MarkAllRead(userID int) error {
+	if err := s.notificationRepo.MarkAllRead(userID); err != nil {
+		return fmt.Errorf("failed to mark notifications as read: %v", err)
+	}
+	return nil
+}
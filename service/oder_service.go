@@ -16,18 +16,20 @@ type OrderService interface {
 }
 
 type orderService struct {
-	orderRepo repository.OrderRepository
-	cartRepo  repository.CartRepository
-	bookRepo  repository.BookRepository
-	db        *sql.DB
+	orderRepo   repository.OrderRepository
+	cartRepo    repository.CartRepository
+	bookRepo    repository.BookRepository
+	variantRepo repository.BookVariantRepository
+	db          *sql.DB
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, bookRepo repository.BookRepository, db *sql.DB) OrderService {
+func NewOrderService(orderRepo repository.OrderRepository, cartRepo repository.CartRepository, bookRepo repository.BookRepository, variantRepo repository.BookVariantRepository, db *sql.DB) OrderService {
 	return &orderService{
-		orderRepo: orderRepo,
-		cartRepo:  cartRepo,
-		bookRepo:  bookRepo,
-		db:        db,
+		orderRepo:   orderRepo,
+		cartRepo:    cartRepo,
+		bookRepo:    bookRepo,
+		variantRepo: variantRepo,
+		db:          db,
 	}
 }
 
@@ -57,8 +59,13 @@ func (s *orderService) CreateOrder(userID int) (*entity.Order, error) {
 			return nil, fmt.Errorf("book not found: %v", err)
 		}
 
-		if book.Stok < item.Jumlah {
-			return nil, fmt.Errorf("insufficient stock for %s", book.NamaBarang)
+		variant, err := s.variantRepo.FindByID(item.VariantID)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s) is no longer sold", book.NamaBarang, item.Format)
+		}
+
+		if variant.Stok != nil && *variant.Stok < item.Jumlah {
+			return nil, fmt.Errorf("insufficient stock for %s (%s)", book.NamaBarang, variant.Format)
 		}
 
 		totalHarga += item.Subtotal
@@ -68,7 +75,7 @@ func (s *orderService) CreateOrder(userID int) (*entity.Order, error) {
 	order := &entity.Order{
 		UserID:     userID,
 		TotalHarga: totalHarga,
-		Status:     "pending",
+		Status:     entity.OrderStatusPending,
 	}
 
 	err = s.orderRepo.Create(order)
@@ -79,10 +86,11 @@ func (s *orderService) CreateOrder(userID int) (*entity.Order, error) {
 	// Create order items and update stock
 	for _, item := range cartItems {
 		orderItem := &entity.OrderItem{
-			OrderID: order.ID,
-			BookID:  item.BookID,
-			Jumlah:  item.Jumlah,
-			Harga:   item.Harga,
+			OrderID:   order.ID,
+			BookID:    item.BookID,
+			VariantID: item.VariantID,
+			Jumlah:    item.Jumlah,
+			Harga:     item.Harga,
 		}
 
 		err = s.orderRepo.CreateItem(orderItem)
@@ -90,8 +98,8 @@ func (s *orderService) CreateOrder(userID int) (*entity.Order, error) {
 			return nil, fmt.Errorf("failed to create order item: %v", err)
 		}
 
-		// Update book stock
-		err = s.bookRepo.UpdateStock(item.BookID, item.Jumlah)
+		// Update the stock of the bought format
+		err = s.variantRepo.RecordSale(item.VariantID, item.Jumlah)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update stock: %v", err)
 		}
@@ -136,5 +144,11 @@ func (s *orderService) GetOrderDetail(orderID, userID int) (*entity.OrderDetail,
 }
 
 func (s *orderService) UpdateOrderStatus(orderID int, status string) error {
+	switch status {
+	case entity.OrderStatusPending, entity.OrderStatusPaid,
+		entity.OrderStatusCompleted, entity.OrderStatusCancelled:
+	default:
+		return fmt.Errorf("invalid order status")
+	}
 	return s.orderRepo.UpdateStatus(orderID, status)
 }
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+const (
+	opdsNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	opds2Type           = "application/opds+json"
+	opdsFeedLimit       = 50
+)
+
+// OPDS feed kinds served under /opds and /opds/v2
+const (
+	OPDSFeedNew         = "new"
+	OPDSFeedBestsellers = "bestsellers"
+	OPDSFeedSearch      = "search"
+	OPDSFeedShelf       = "shelf"
+)
+
+// OPDSCatalog is a format-neutral acquisition feed rendered as OPDS 1.2 or 2.0
+type OPDSCatalog struct {
+	Kind         string
+	Title        string
+	Query        string
+	Publications []OPDSPublication
+}
+
+// OPDSPublication is a catalog entry. Owned publications carry direct
+// acquisition links; the others link to the store with their price.
+type OPDSPublication struct {
+	Book  entity.Book
+	Owned bool
+	Files []OPDSAcquisition
+}
+
+type OPDSAcquisition struct {
+	Href string
+	Type string
+}
+
+type OPDSService interface {
+	GetCatalog(kind string, userID int, query string) (*OPDSCatalog, error)
+	AtomNavigation() *model.OPDSFeed
+	AtomFeed(catalog *OPDSCatalog) *model.OPDSFeed
+	JSONNavigation() *model.OPDS2Feed
+	JSONFeed(catalog *OPDSCatalog) *model.OPDS2Feed
+	OpenSearch() *model.OpenSearchDescription
+}
+
+type opdsService struct {
+	bookRepo        repository.BookRepository
+	bookService     BookService
+	libraryService  LibraryService
+	bookFileService BookFileService
+	uploadService   UploadService
+	baseURL         string
+}
+
+func NewOPDSService(bookRepo repository.BookRepository, bookService BookService, libraryService LibraryService, bookFileService BookFileService, uploadService UploadService, baseURL string) OPDSService {
+	return &opdsService{
+		bookRepo:        bookRepo,
+		bookService:     bookService,
+		libraryService:  libraryService,
+		bookFileService: bookFileService,
+		uploadService:   uploadService,
+		baseURL:         baseURL,
+	}
+}
+
+func (s *opdsService) GetCatalog(kind string, userID int, query string) (*OPDSCatalog, error) {
+	catalog := &OPDSCatalog{Kind: kind, Query: query}
+
+	var books []entity.Book
+	var err error
+	switch kind {
+	case OPDSFeedNew:
+		catalog.Title = "New Releases"
+		books, err = s.bookRepo.FindAll(repository.BookFilter{
+			Sort: repository.BookSortCreatedAt, Desc: true, Limit: opdsFeedLimit,
+		})
+	case OPDSFeedBestsellers:
+		catalog.Title = "Bestsellers"
+		books, err = s.bookRepo.FindAll(repository.BookFilter{
+			Sort: repository.BookSortTerjual, Desc: true, Limit: opdsFeedLimit,
+		})
+	case OPDSFeedSearch:
+		catalog.Title = fmt.Sprintf("Search results for %q", query)
+		var results []entity.BookSearchResult
+		results, err = s.bookService.SearchBooks(query, "")
+		for i := range results {
+			if i == opdsFeedLimit {
+				break
+			}
+			books = append(books, results[i].Book)
+		}
+	case OPDSFeedShelf:
+		catalog.Title = "My Books"
+		return catalog, s.fillShelf(catalog, userID)
+	default:
+		return nil, fmt.Errorf("unknown feed")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get books: %v", err)
+	}
+
+	for _, book := range books {
+		catalog.Publications = append(catalog.Publications, OPDSPublication{Book: book})
+	}
+	return catalog, nil
+}
+
+// fillShelf lists the user's purchased books with signed acquisition links,
+// since most reader apps cannot attach a bearer token to downloads
+func (s *opdsService) fillShelf(catalog *OPDSCatalog, userID int) error {
+	items, err := s.libraryService.GetLibrary(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		publication := OPDSPublication{
+			Book: entity.Book{
+				ID:         item.BookID,
+				NamaBarang: item.NamaBarang,
+				Penulis:    item.Penulis,
+				GambarBuku: item.GambarBuku,
+				UpdatedAt:  item.PurchasedAt,
+			},
+			Owned: true,
+		}
+
+		for _, file := range item.Files {
+			link, err := s.bookFileService.CreateDownloadLink(userID, file.ID)
+			if err != nil {
+				continue
+			}
+			publication.Files = append(publication.Files, OPDSAcquisition{
+				Href: link.URL,
+				Type: bookFileContentTypes[file.Format],
+			})
+		}
+
+		catalog.Publications = append(catalog.Publications, publication)
+	}
+	return nil
+}
+
+func (s *opdsService) AtomNavigation() *model.OPDSFeed {
+	feed := s.newAtomFeed("urn:ebook-store:opds", "Ebook Store", "/opds", opdsNavigationType)
+	now := formatOPDSTime(time.Now())
+
+	entries := []struct {
+		kind, title, summary string
+	}{
+		{OPDSFeedNew, "New Releases", "The latest books in the store"},
+		{OPDSFeedBestsellers, "Bestsellers", "The most purchased books"},
+		{OPDSFeedShelf, "My Books", "Books you have purchased"},
+	}
+	for _, e := range entries {
+		feed.Entries = append(feed.Entries, model.OPDSEntry{
+			ID:      "urn:ebook-store:opds:" + e.kind,
+			Title:   e.title,
+			Updated: now,
+			Content: &model.OPDSText{Type: "text", Text: e.summary},
+			Links: []model.OPDSLink{{
+				Rel:  "subsection",
+				Href: s.baseURL + "/opds/" + e.kind,
+				Type: opdsAcquisitionType,
+			}},
+		})
+	}
+	return feed
+}
+
+func (s *opdsService) AtomFeed(catalog *OPDSCatalog) *model.OPDSFeed {
+	path := "/opds/" + catalog.Kind
+	if catalog.Kind == OPDSFeedSearch {
+		path += "?q=" + url.QueryEscape(catalog.Query)
+	}
+	feed := s.newAtomFeed("urn:ebook-store:opds:"+catalog.Kind, catalog.Title, path, opdsAcquisitionType)
+
+	for _, publication := range catalog.Publications {
+		book := publication.Book
+		entry := model.OPDSEntry{
+			ID:        fmt.Sprintf("urn:ebook-store:book:%d", book.ID),
+			Title:     book.NamaBarang,
+			Updated:   formatOPDSTime(book.UpdatedAt),
+			Language:  book.Bahasa,
+			Publisher: book.Penerbit,
+		}
+		for _, author := range splitAuthors(book.Penulis) {
+			entry.Authors = append(entry.Authors, model.OPDSAuthor{Name: author})
+		}
+		if book.Keterangan != "" {
+			entry.Summary = &model.OPDSText{Type: "text", Text: book.Keterangan}
+		}
+		if book.GambarBuku != "" {
+			imageURL := s.uploadService.GetImageURL(book.GambarBuku)
+			entry.Links = append(entry.Links,
+				model.OPDSLink{Rel: "http://opds-spec.org/image", Href: imageURL},
+				model.OPDSLink{Rel: "http://opds-spec.org/image/thumbnail", Href: imageURL},
+			)
+		}
+
+		if publication.Owned {
+			for _, file := range publication.Files {
+				entry.Links = append(entry.Links, model.OPDSLink{
+					Rel:  "http://opds-spec.org/acquisition",
+					Href: file.Href,
+					Type: file.Type,
+				})
+			}
+		} else {
+			entry.Links = append(entry.Links, model.OPDSLink{
+				Rel:   "http://opds-spec.org/acquisition/buy",
+				Href:  fmt.Sprintf("%s/api/books/detail?id=%d", s.baseURL, book.ID),
+				Type:  "application/json",
+				Price: &model.OPDSPrice{CurrencyCode: "IDR", Value: book.Harga},
+			})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+func (s *opdsService) JSONNavigation() *model.OPDS2Feed {
+	feed := s.newJSONFeed("Ebook Store", "/opds/v2")
+	feed.Navigation = []model.OPDS2Link{
+		{Href: s.baseURL + "/opds/v2/" + OPDSFeedNew, Title: "New Releases", Type: opds2Type},
+		{Href: s.baseURL + "/opds/v2/" + OPDSFeedBestsellers, Title: "Bestsellers", Type: opds2Type},
+		{Href: s.baseURL + "/opds/v2/" + OPDSFeedShelf, Title: "My Books", Type: opds2Type},
+	}
+	return feed
+}
+
+func (s *opdsService) JSONFeed(catalog *OPDSCatalog) *model.OPDS2Feed {
+	path := "/opds/v2/" + catalog.Kind
+	if catalog.Kind == OPDSFeedSearch {
+		path += "?query=" + url.QueryEscape(catalog.Query)
+	}
+	feed := s.newJSONFeed(catalog.Title, path)
+	feed.Metadata.NumberOfItems = len(catalog.Publications)
+	feed.Publications = []model.OPDS2Publication{}
+
+	for _, publication := range catalog.Publications {
+		book := publication.Book
+		pub := model.OPDS2Publication{
+			Metadata: model.OPDS2PublicationMetadata{
+				Type:        "http://schema.org/Book",
+				Identifier:  opdsIdentifier(book),
+				Title:       book.NamaBarang,
+				Author:      splitAuthors(book.Penulis),
+				Language:    book.Bahasa,
+				Publisher:   book.Penerbit,
+				Description: book.Keterangan,
+				Modified:    formatOPDSTime(book.UpdatedAt),
+			},
+			Links: []model.OPDS2Link{},
+		}
+		if book.GambarBuku != "" {
+			pub.Images = []model.OPDS2Link{{Href: s.uploadService.GetImageURL(book.GambarBuku)}}
+		}
+
+		if publication.Owned {
+			for _, file := range publication.Files {
+				pub.Links = append(pub.Links, model.OPDS2Link{
+					Rel:  "http://opds-spec.org/acquisition",
+					Href: file.Href,
+					Type: file.Type,
+				})
+			}
+		} else {
+			pub.Links = append(pub.Links, model.OPDS2Link{
+				Rel:  "http://opds-spec.org/acquisition/buy",
+				Href: fmt.Sprintf("%s/api/books/detail?id=%d", s.baseURL, book.ID),
+				Type: "application/json",
+				Properties: &model.OPDS2Properties{
+					Price: &model.OPDS2Price{Currency: "IDR", Value: book.Harga},
+				},
+			})
+		}
+
+		feed.Publications = append(feed.Publications, pub)
+	}
+	return feed
+}
+
+func (s *opdsService) OpenSearch() *model.OpenSearchDescription {
+	return &model.OpenSearchDescription{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   "Ebook Store",
+		Description: "Search the Ebook Store catalog",
+		URL: model.OpenSearchURL{
+			Type:     opdsAcquisitionType,
+			Template: s.baseURL + "/opds/search?q={searchTerms}",
+		},
+	}
+}
+
+func (s *opdsService) newAtomFeed(id, title, path, feedType string) *model.OPDSFeed {
+	return &model.OPDSFeed{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsDC:   "http://purl.org/dc/terms/",
+		XmlnsOPDS: "http://opds-spec.org/2010/catalog",
+		ID:        id,
+		Title:     title,
+		Updated:   formatOPDSTime(time.Now()),
+		Links: []model.OPDSLink{
+			{Rel: "self", Href: s.baseURL + path, Type: feedType},
+			{Rel: "start", Href: s.baseURL + "/opds", Type: opdsNavigationType},
+			{Rel: "search", Href: s.baseURL + "/opds/opensearch.xml", Type: "application/opensearchdescription+xml"},
+		},
+	}
+}
+
+func (s *opdsService) newJSONFeed(title, path string) *model.OPDS2Feed {
+	return &model.OPDS2Feed{
+		Metadata: model.OPDS2FeedMetadata{Title: title},
+		Links: []model.OPDS2Link{
+			{Rel: "self", Href: s.baseURL + path, Type: opds2Type},
+			{Rel: "start", Href: s.baseURL + "/opds/v2", Type: opds2Type},
+			{Rel: "search", Href: s.baseURL + "/opds/v2/search{?query}", Type: opds2Type, Templated: true},
+		},
+	}
+}
+
+// opdsIdentifier prefers the ISBN so readers can match editions across catalogs
+func opdsIdentifier(book entity.Book) string {
+	if book.ISBN13 != "" {
+		return "urn:isbn:" + book.ISBN13
+	}
+	return fmt.Sprintf("urn:ebook-store:book:%d", book.ID)
+}
+
+func formatOPDSTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func splitAuthors(penulis string) []string {
+	var authors []string
+	for _, author := range strings.Split(penulis, ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
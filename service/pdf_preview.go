@@ -0,0 +1,1155 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// PDF objects as read by the preview extractor. Only what is needed to copy
+// pages into a new document is modelled. Stream data is not loaded: a stream
+// records where its data lies in the master and is copied from there as-is.
+type (
+	pdfName    string
+	pdfNumber  string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []interface{}
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ Num, Gen int }
+	pdfStream  struct {
+		Dict   pdfDict
+		Offset int64
+		Length int64
+		src    io.ReaderAt
+		next   int64 // offset after endstream
+	}
+)
+
+// pdfIndirect is an indirect object and the file offset it was found at;
+// the later definition of an object wins, as in incremental updates
+type pdfIndirect struct {
+	Value  interface{}
+	Offset int64
+}
+
+const (
+	// pdfChunkSize is how much of the master is read at a time when
+	// scanning it, so a large book is never held in memory as a whole
+	pdfChunkSize = 1 << 20
+
+	// pdfWindowSize is the initial amount read to parse an object. Objects
+	// that do not fit are read again with a larger window, up to
+	// maxPDFObjectSize.
+	pdfWindowSize    = 64 << 10
+	maxPDFObjectSize = 16 << 20
+)
+
+// pdfInheritable are the page attributes a page may take from its parents
+var pdfInheritable = []pdfName{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// pdfResourceCategories are the resource dictionaries whose entries content
+// streams refer to by name
+var pdfResourceCategories = []pdfName{"ExtGState", "ColorSpace", "Pattern", "Shading", "XObject", "Font", "Properties"}
+
+// maxPDFDecodedSize caps the decompressed size of a stream the preview reads
+const maxPDFDecodedSize = 64 << 20
+
+var (
+	pdfObjectHeader  = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailerHeader = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// PreviewPDF writes a new PDF to w holding the first pages of the master and
+// returns how many pages were included. Only the objects the selected pages
+// use are copied, so content of later pages never ends up in the preview;
+// resources shared between pages are cut down to the entries each kept page
+// names.
+// Link annotations and the outline are left out as they point into the rest
+// of the book. A preview never contains the whole book: if it has no more
+// than the requested number of pages, the last one is left out.
+func PreviewPDF(w io.Writer, master io.ReaderAt, size int64, pages int) (int, error) {
+	header := make([]byte, 5)
+	if _, err := master.ReadAt(header, 0); err != nil || string(header) != "%PDF-" {
+		return 0, fmt.Errorf("invalid PDF: missing header")
+	}
+
+	doc, err := readPDF(master, size)
+	if err != nil {
+		return 0, err
+	}
+
+	all, err := doc.pages()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) < 2 {
+		return 0, fmt.Errorf("PDF has too few pages for a preview")
+	}
+	if pages > len(all)-1 {
+		pages = len(all) - 1
+	}
+
+	if err := doc.write(w, all[:pages]); err != nil {
+		return 0, err
+	}
+	return pages, nil
+}
+
+type pdfDocument struct {
+	src     io.ReaderAt
+	size    int64
+	objects map[int]pdfIndirect
+	trailer pdfDict
+}
+
+type pdfPage struct {
+	Ref  pdfRef
+	Dict pdfDict
+}
+
+// readPDF collects the indirect objects of the file by scanning for object
+// headers rather than trusting the cross-reference table, which is often
+// damaged in files produced by ebook tools
+func readPDF(src io.ReaderAt, size int64) (*pdfDocument, error) {
+	doc := &pdfDocument{src: src, size: size, objects: make(map[int]pdfIndirect)}
+	trailerAt := int64(-1)
+
+	headers, err := doc.findAll(pdfObjectHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	var pos int64
+	for _, header := range headers {
+		if header.Start < pos {
+			// Inside an object already read, for example text in a stream
+			continue
+		}
+		value, end, err := doc.parseAt(header.End, true)
+		if err != nil {
+			// Not a real object header
+			pos = header.End
+			continue
+		}
+		pos = end
+
+		num, err := strconv.Atoi(header.Num)
+		if err != nil {
+			continue
+		}
+		if prev, ok := doc.objects[num]; !ok || prev.Offset < header.Start {
+			doc.objects[num] = pdfIndirect{Value: value, Offset: header.Start}
+		}
+
+		if stream, ok := value.(*pdfStream); ok && stream.Dict["Type"] == pdfName("XRef") && header.Start > trailerAt {
+			doc.trailer, trailerAt = stream.Dict, header.Start
+		}
+	}
+
+	trailers, err := doc.findAll(pdfTrailerHeader)
+	if err != nil {
+		return nil, err
+	}
+	for _, trailer := range trailers {
+		if trailer.Start < trailerAt {
+			continue
+		}
+		if value, _, err := doc.parseAt(trailer.End-2, false); err == nil {
+			if dict, ok := value.(pdfDict); ok {
+				doc.trailer, trailerAt = dict, trailer.Start
+			}
+		}
+	}
+
+	if doc.trailer != nil {
+		if _, ok := doc.trailer["Encrypt"]; ok {
+			return nil, fmt.Errorf("encrypted PDFs are not supported")
+		}
+	}
+
+	if err := doc.unpackObjectStreams(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// unpackObjectStreams adds the objects stored in compressed object streams.
+// They take the offset of their stream, so a newer direct definition still
+// replaces them.
+func (d *pdfDocument) unpackObjectStreams() error {
+	for _, obj := range d.objects {
+		stream, ok := obj.Value.(*pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+
+		data, err := stream.decode()
+		if err != nil {
+			return err
+		}
+		count, ok1 := pdfInt(stream.Dict["N"])
+		first, ok2 := pdfInt(stream.Dict["First"])
+		if !ok1 || !ok2 || count < 0 || first < 0 || first > len(data) {
+			return fmt.Errorf("invalid PDF: malformed object stream")
+		}
+
+		header := &pdfParser{data: data[:first]}
+		for i := 0; i < count; i++ {
+			num, err1 := header.parseObject()
+			offset, err2 := header.parseObject()
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("invalid PDF: malformed object stream")
+			}
+			n, ok1 := pdfInt(num)
+			off, ok2 := pdfInt(offset)
+			if !ok1 || !ok2 || n < 0 || off < 0 || off >= len(data)-first {
+				return fmt.Errorf("invalid PDF: malformed object stream")
+			}
+
+			p := &pdfParser{data: data, pos: first + off}
+			value, err := p.parseValue()
+			if err != nil {
+				return fmt.Errorf("invalid PDF: %v", err)
+			}
+			if prev, ok := d.objects[n]; !ok || prev.Offset < obj.Offset {
+				d.objects[n] = pdfIndirect{Value: value, Offset: obj.Offset}
+			}
+		}
+	}
+	return nil
+}
+
+// pdfMatch is a match of a pattern in the master: its start and end offsets
+// and the first submatch, if any
+type pdfMatch struct {
+	Start, End int64
+	Num        string
+}
+
+// findAll returns the matches of re in the file, reading it a chunk at a
+// time. Consecutive chunks overlap so a match spanning two of them is still
+// found; a match is reported by the chunk it starts in.
+func (d *pdfDocument) findAll(re *regexp.Regexp) ([]pdfMatch, error) {
+	const overlap = 256
+
+	var matches []pdfMatch
+	buf := make([]byte, pdfChunkSize+overlap)
+	for base := int64(0); base < d.size; base += pdfChunkSize {
+		n, err := d.src.ReadAt(buf[:min(int64(len(buf)), d.size-base)], base)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		for _, loc := range re.FindAllSubmatchIndex(buf[:n], -1) {
+			if loc[0] >= pdfChunkSize {
+				break
+			}
+			match := pdfMatch{Start: base + int64(loc[0]), End: base + int64(loc[1])}
+			if len(loc) > 2 && loc[2] >= 0 {
+				match.Num = string(buf[loc[2]:loc[3]])
+			}
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+// index returns the offset of the first occurrence of sep at or after from,
+// or -1
+func (d *pdfDocument) index(from int64, sep []byte) (int64, error) {
+	buf := make([]byte, pdfChunkSize+len(sep)-1)
+	for base := from; base < d.size; base += pdfChunkSize {
+		n, err := d.src.ReadAt(buf[:min(int64(len(buf)), d.size-base)], base)
+		if err != nil && err != io.EOF {
+			return -1, err
+		}
+		if i := bytes.Index(buf[:n], sep); i >= 0 {
+			return base + int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+// parseAt parses the value at off, or with object set the body of an
+// indirect object, and returns it with the offset just after it. Only a
+// window of the file around the object is read, and read again larger when
+// the object runs up to its end.
+func (d *pdfDocument) parseAt(off int64, object bool) (interface{}, int64, error) {
+	if off < 0 || off >= d.size {
+		return nil, 0, fmt.Errorf("unexpected end of PDF data")
+	}
+
+	for window := int64(pdfWindowSize); ; window *= 4 {
+		data := make([]byte, min(window, d.size-off))
+		n, err := d.src.ReadAt(data, off)
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		data = data[:n]
+
+		p := &pdfParser{data: data, doc: d, base: off}
+		var value interface{}
+		if object {
+			value, err = p.parseObject()
+		} else {
+			value, err = p.parseValue()
+		}
+
+		// Tokens near the end of a partial window may have been cut short
+		truncated := off+int64(n) < d.size
+		if truncated && (err != nil || p.pos > len(data)-256) {
+			if window >= maxPDFObjectSize {
+				return nil, 0, fmt.Errorf("PDF object is too large")
+			}
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if stream, ok := value.(*pdfStream); ok {
+			return value, stream.next, nil
+		}
+		return value, off + int64(p.pos), nil
+	}
+}
+
+func (d *pdfDocument) resolve(v interface{}) interface{} {
+	if ref, ok := v.(pdfRef); ok {
+		return d.objects[ref.Num].Value
+	}
+	return v
+}
+
+func (d *pdfDocument) catalog() (pdfDict, error) {
+	if d.trailer != nil {
+		if catalog, ok := d.resolve(d.trailer["Root"]).(pdfDict); ok {
+			return catalog, nil
+		}
+	}
+
+	// Fall back to any catalog object when the trailer is missing
+	for _, obj := range d.objects {
+		if dict, ok := obj.Value.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			return dict, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid PDF: document catalog not found")
+}
+
+// pages returns the pages in reading order with inherited attributes copied
+// onto each page
+func (d *pdfDocument) pages() ([]pdfPage, error) {
+	catalog, err := d.catalog()
+	if err != nil {
+		return nil, err
+	}
+	root, ok := catalog["Pages"].(pdfRef)
+	if !ok {
+		return nil, fmt.Errorf("invalid PDF: page tree not found")
+	}
+
+	var pages []pdfPage
+	visited := make(map[int]bool)
+
+	var walk func(ref pdfRef, inherited pdfDict)
+	walk = func(ref pdfRef, inherited pdfDict) {
+		if visited[ref.Num] {
+			return
+		}
+		visited[ref.Num] = true
+
+		node, ok := d.resolve(ref).(pdfDict)
+		if !ok {
+			return
+		}
+
+		kids, isTree := d.resolve(node["Kids"]).(pdfArray)
+		if !isTree {
+			page := make(pdfDict, len(node))
+			for k, v := range node {
+				page[k] = v
+			}
+			for _, key := range pdfInheritable {
+				if _, ok := page[key]; !ok && inherited[key] != nil {
+					page[key] = inherited[key]
+				}
+			}
+			pages = append(pages, pdfPage{Ref: ref, Dict: page})
+			return
+		}
+
+		next := make(pdfDict, len(pdfInheritable))
+		for _, key := range pdfInheritable {
+			if v, ok := node[key]; ok {
+				next[key] = v
+			} else if inherited[key] != nil {
+				next[key] = inherited[key]
+			}
+		}
+		for _, kid := range kids {
+			if kidRef, ok := kid.(pdfRef); ok {
+				walk(kidRef, next)
+			}
+		}
+	}
+	walk(root, nil)
+
+	return pages, nil
+}
+
+// write builds a new document from the selected pages and every object they
+// reference. References to other pages, page tree nodes or the catalog are
+// replaced by null so the walk cannot reach the rest of the book, and
+// resource dictionaries are pruned to what the content using them names.
+func (d *pdfDocument) write(w io.Writer, pages []pdfPage) error {
+	const catalogNum, pagesNum = 1, 2
+
+	numbers := make(map[int]int)
+	var queue []int
+	var convertErr error
+	next := pagesNum + 1
+
+	for _, page := range pages {
+		numbers[page.Ref.Num] = next
+		next++
+	}
+
+	var convert func(v interface{}) interface{}
+	convert = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case pdfRef:
+			if n, ok := numbers[v.Num]; ok {
+				return pdfRef{Num: n}
+			}
+			target, ok := d.objects[v.Num]
+			if !ok || isPDFStructure(target.Value) {
+				return pdfKeyword("null")
+			}
+			numbers[v.Num] = next
+			next++
+			queue = append(queue, v.Num)
+			return pdfRef{Num: numbers[v.Num]}
+		case pdfArray:
+			out := make(pdfArray, len(v))
+			for i, item := range v {
+				out[i] = convert(item)
+			}
+			return out
+		case pdfDict:
+			if v["Subtype"] == pdfName("Type3") {
+				v = d.pruneOwnResources(v, &convertErr, d.type3Content)
+			}
+			out := make(pdfDict, len(v))
+			for k, item := range v {
+				out[k] = convert(item)
+			}
+			return out
+		case *pdfStream:
+			dict := v.Dict
+			if dict["Subtype"] == pdfName("Form") || dict["PatternType"] == pdfNumber("1") {
+				dict = d.pruneOwnResources(dict, &convertErr, func(pdfDict) ([]byte, error) {
+					return v.decode()
+				})
+			}
+			dict = convert(dict).(pdfDict)
+			dict["Length"] = pdfNumber(strconv.FormatInt(v.Length, 10))
+			return &pdfStream{Dict: dict, Offset: v.Offset, Length: v.Length, src: v.src}
+		default:
+			return v
+		}
+	}
+
+	out := map[int]interface{}{}
+	kids := make(pdfArray, len(pages))
+	for i, page := range pages {
+		dict := make(pdfDict, len(page.Dict))
+		for k, v := range page.Dict {
+			switch k {
+			case "Parent", "Annots", "B", "StructParents", "Thumb":
+				continue
+			}
+			dict[k] = v
+		}
+		dict = d.pruneOwnResources(dict, &convertErr, d.pageContent)
+		dict = convert(dict).(pdfDict)
+		dict["Parent"] = pdfRef{Num: pagesNum}
+
+		n := numbers[page.Ref.Num]
+		out[n] = dict
+		kids[i] = pdfRef{Num: n}
+	}
+	for len(queue) > 0 {
+		num := queue[0]
+		queue = queue[1:]
+		out[numbers[num]] = convert(d.objects[num].Value)
+	}
+	if convertErr != nil {
+		return convertErr
+	}
+
+	out[catalogNum] = pdfDict{"Type": pdfName("Catalog"), "Pages": pdfRef{Num: pagesNum}}
+	out[pagesNum] = pdfDict{
+		"Type":  pdfName("Pages"),
+		"Kids":  kids,
+		"Count": pdfNumber(strconv.Itoa(len(pages))),
+	}
+
+	buf := &pdfWriter{w: bufio.NewWriter(w)}
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int64, next)
+	for n := 1; n < next; n++ {
+		offsets[n] = buf.n
+		fmt.Fprintf(buf, "%d 0 obj\n", n)
+		writePDFValue(buf, out[n])
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.n
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", next)
+	for n := 1; n < next; n++ {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offsets[n])
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", next, catalogNum, xref)
+
+	if buf.err != nil {
+		return buf.err
+	}
+	return buf.w.Flush()
+}
+
+// pdfWriter writes the preview straight to its destination, counting the
+// bytes written for the cross-reference table. The first error is kept and
+// later writes are skipped.
+type pdfWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *pdfWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
+
+func (w *pdfWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *pdfWriter) WriteByte(c byte) error {
+	_, err := w.Write([]byte{c})
+	return err
+}
+
+// pruneOwnResources returns a copy of dict whose Resources only hold the
+// entries named in the content returned by contentOf. Books often share one
+// resource dictionary listing every page's images between all pages, which
+// would otherwise carry the whole book into the preview. The first error is
+// stored in errp.
+func (d *pdfDocument) pruneOwnResources(dict pdfDict, errp *error, contentOf func(pdfDict) ([]byte, error)) pdfDict {
+	resources, ok := d.resolve(dict["Resources"]).(pdfDict)
+	if !ok {
+		return dict
+	}
+
+	out := make(pdfDict, len(dict))
+	for k, v := range dict {
+		out[k] = v
+	}
+
+	content, err := contentOf(dict)
+	if err != nil {
+		if *errp == nil {
+			*errp = err
+		}
+		out["Resources"] = pdfDict{}
+		return out
+	}
+
+	used, err := d.resourceNames(content, resources, make(map[int]bool))
+	if err != nil && *errp == nil {
+		*errp = err
+	}
+
+	pruned := pdfDict{}
+	if procSet, ok := resources["ProcSet"]; ok {
+		pruned["ProcSet"] = procSet
+	}
+	for _, category := range pdfResourceCategories {
+		entries, ok := d.resolve(resources[category]).(pdfDict)
+		if !ok {
+			continue
+		}
+		kept := pdfDict{}
+		for name, v := range entries {
+			if used[name] {
+				kept[name] = v
+			}
+		}
+		if len(kept) > 0 {
+			pruned[category] = kept
+		}
+	}
+	out["Resources"] = pruned
+	return out
+}
+
+// resourceNames returns the resource names used by content, including those
+// used by form XObjects that have no resources of their own and so draw
+// from the same dictionary
+func (d *pdfDocument) resourceNames(content []byte, resources pdfDict, seen map[int]bool) (map[pdfName]bool, error) {
+	used := pdfContentNames(content)
+
+	xobjects, _ := d.resolve(resources["XObject"]).(pdfDict)
+	var forms []pdfRef
+	for name := range used {
+		if ref, ok := xobjects[name].(pdfRef); ok && !seen[ref.Num] {
+			seen[ref.Num] = true
+			forms = append(forms, ref)
+		}
+	}
+
+	for _, ref := range forms {
+		form, ok := d.resolve(ref).(*pdfStream)
+		if !ok || form.Dict["Subtype"] != pdfName("Form") {
+			continue
+		}
+		if _, own := form.Dict["Resources"]; own {
+			continue
+		}
+		formContent, err := form.decode()
+		if err != nil {
+			return used, err
+		}
+		nested, err := d.resourceNames(formContent, resources, seen)
+		for name := range nested {
+			used[name] = true
+		}
+		if err != nil {
+			return used, err
+		}
+	}
+	return used, nil
+}
+
+// pageContent returns the decoded content streams of a page joined together
+func (d *pdfDocument) pageContent(page pdfDict) ([]byte, error) {
+	var refs pdfArray
+	switch contents := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		return contents.decode()
+	case pdfArray:
+		refs = contents
+	}
+
+	var content []byte
+	for _, ref := range refs {
+		stream, ok := d.resolve(ref).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := stream.decode()
+		if err != nil {
+			return nil, err
+		}
+		content = append(append(content, data...), '\n')
+	}
+	return content, nil
+}
+
+// type3Content returns the glyph procedures of a Type 3 font joined together
+func (d *pdfDocument) type3Content(font pdfDict) ([]byte, error) {
+	procs, _ := d.resolve(font["CharProcs"]).(pdfDict)
+
+	var content []byte
+	for _, proc := range procs {
+		stream, ok := d.resolve(proc).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := stream.decode()
+		if err != nil {
+			return nil, err
+		}
+		content = append(append(content, data...), '\n')
+	}
+	return content, nil
+}
+
+// pdfContentNames returns every name operand in a content stream. Names are
+// collected regardless of the operator using them, which may keep an unused
+// resource that shares a name with a used one but never drops a used one.
+func pdfContentNames(content []byte) map[pdfName]bool {
+	names := make(map[pdfName]bool)
+	p := &pdfParser{data: content}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return names
+		}
+
+		switch c := p.data[p.pos]; c {
+		case '/':
+			p.pos++
+			names[pdfName(p.readRegular())] = true
+		case '(':
+			if _, err := p.readLiteralString(); err != nil {
+				return names
+			}
+		case '<':
+			if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+				p.pos += 2
+				continue
+			}
+			end := bytes.IndexByte(p.data[p.pos:], '>')
+			if end < 0 {
+				return names
+			}
+			p.pos += end + 1
+		default:
+			word := p.readRegular()
+			if word == "" {
+				p.pos++
+				continue
+			}
+			// Inline image data is binary and runs until EI
+			if word == "ID" {
+				p.skipInlineImage()
+			}
+		}
+	}
+}
+
+// skipInlineImage moves past the data of an inline image to its EI operator
+func (p *pdfParser) skipInlineImage() {
+	for i := p.pos + 1; i+2 <= len(p.data); i++ {
+		if p.data[i] == 'E' && p.data[i+1] == 'I' && isPDFSpace(p.data[i-1]) &&
+			(i+2 == len(p.data) || isPDFSpace(p.data[i+2]) || isPDFDelimiter(p.data[i+2])) {
+			p.pos = i + 2
+			return
+		}
+	}
+	p.pos = len(p.data)
+}
+
+// isPDFStructure reports whether v is part of the document structure rather
+// than page content
+func isPDFStructure(v interface{}) bool {
+	var dict pdfDict
+	switch v := v.(type) {
+	case pdfDict:
+		dict = v
+	case *pdfStream:
+		dict = v.Dict
+	default:
+		return false
+	}
+	switch dict["Type"] {
+	case pdfName("Page"), pdfName("Pages"), pdfName("Catalog"):
+		return true
+	}
+	return false
+}
+
+func writePDFValue(buf *pdfWriter, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case pdfName:
+		buf.WriteByte('/')
+		buf.WriteString(string(v))
+	case pdfNumber:
+		buf.WriteString(string(v))
+	case pdfKeyword:
+		buf.WriteString(string(v))
+	case pdfString:
+		buf.WriteByte('<')
+		buf.WriteString(hex.EncodeToString(v))
+		buf.WriteByte('>')
+	case pdfRef:
+		fmt.Fprintf(buf, "%d %d R", v.Num, v.Gen)
+	case pdfArray:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writePDFValue(buf, item)
+		}
+		buf.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, k := range keys {
+			buf.WriteString(" /")
+			buf.WriteString(k)
+			buf.WriteByte(' ')
+			writePDFValue(buf, v[pdfName(k)])
+		}
+		buf.WriteString(" >>")
+	case *pdfStream:
+		writePDFValue(buf, v.Dict)
+		buf.WriteString("\nstream\n")
+		if buf.err == nil {
+			if _, err := io.Copy(buf, io.NewSectionReader(v.src, v.Offset, v.Length)); err != nil && buf.err == nil {
+				buf.err = err
+			}
+		}
+		buf.WriteString("\nendstream")
+	}
+}
+
+// decode returns the decompressed data of a FlateDecode or unfiltered stream
+func (s *pdfStream) decode() ([]byte, error) {
+	filter := s.Dict["Filter"]
+	if filters, ok := filter.(pdfArray); ok && len(filters) == 1 {
+		filter = filters[0]
+	}
+	if filter != nil && filter != pdfName("FlateDecode") {
+		return nil, fmt.Errorf("unsupported PDF stream encoding")
+	}
+	if _, ok := s.Dict["DecodeParms"]; ok && filter != nil {
+		return nil, fmt.Errorf("unsupported PDF stream encoding")
+	}
+	if s.Length > maxPDFDecodedSize {
+		return nil, fmt.Errorf("PDF stream is too large")
+	}
+
+	raw := make([]byte, s.Length)
+	if n, err := s.src.ReadAt(raw, s.Offset); n < len(raw) {
+		return nil, fmt.Errorf("invalid PDF stream: %v", err)
+	}
+	if filter == nil {
+		return raw, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDF stream: %v", err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxPDFDecodedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PDF stream: %v", err)
+	}
+	if len(data) > maxPDFDecodedSize {
+		return nil, fmt.Errorf("PDF stream is too large")
+	}
+	return data, nil
+}
+
+func pdfInt(v interface{}) (int, bool) {
+	n, ok := v.(pdfNumber)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(string(n))
+	return i, err == nil
+}
+
+// pdfParser reads values from data. When parsing a window of the master, doc
+// is the document and base the file offset of data, so streams can be
+// located in the file.
+type pdfParser struct {
+	data []byte
+	pos  int
+	doc  *pdfDocument
+	base int64
+}
+
+// parseObject reads the body of an indirect object after its "obj" keyword
+func (p *pdfParser) parseObject() (interface{}, error) {
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := value.(pdfDict)
+	if !ok {
+		return value, nil
+	}
+
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return dict, nil
+	}
+	p.pos += len("stream")
+	if bytes.HasPrefix(p.data[p.pos:], []byte("\r\n")) {
+		p.pos += 2
+	} else if p.pos < len(p.data) && (p.data[p.pos] == '\n' || p.data[p.pos] == '\r') {
+		p.pos++
+	}
+	if p.doc == nil {
+		return nil, fmt.Errorf("unexpected stream")
+	}
+	start := p.base + int64(p.pos)
+
+	length, next, err := p.doc.streamExtent(start, dict)
+	if err != nil {
+		return nil, err
+	}
+	return &pdfStream{Dict: dict, Offset: start, Length: length, src: p.doc.src, next: next}, nil
+}
+
+// streamExtent returns the length of the stream data starting at start and
+// the offset after its endstream keyword. /Length is trusted when it points
+// at endstream, otherwise the keyword is searched for.
+func (d *pdfDocument) streamExtent(start int64, dict pdfDict) (int64, int64, error) {
+	if length, ok := pdfInt(dict["Length"]); ok && length >= 0 && int64(length) <= d.size-start {
+		tail := make([]byte, 64)
+		n, err := d.src.ReadAt(tail, start+int64(length))
+		if err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		after := &pdfParser{data: tail[:n]}
+		after.skipSpace()
+		if bytes.HasPrefix(after.data[after.pos:], []byte("endstream")) {
+			return int64(length), start + int64(length) + int64(after.pos) + int64(len("endstream")), nil
+		}
+	}
+
+	end, err := d.index(start, []byte("endstream"))
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < 0 {
+		return 0, 0, fmt.Errorf("unterminated stream")
+	}
+	next := end + int64(len("endstream"))
+
+	// Drop the end-of-line marker before endstream
+	eol := make([]byte, 2)
+	if end-start >= 2 {
+		if _, err := d.src.ReadAt(eol, end-2); err != nil {
+			return 0, 0, err
+		}
+		if eol[1] == '\n' {
+			end--
+			if eol[0] == '\r' {
+				end--
+			}
+		} else if eol[1] == '\r' {
+			end--
+		}
+	}
+	return end - start, next, nil
+}
+
+func (p *pdfParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of PDF data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return pdfName(p.readRegular()), nil
+	case c == '(':
+		return p.readLiteralString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict := pdfDict{}
+		for {
+			p.skipSpace()
+			if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+				p.pos += 2
+				return dict, nil
+			}
+			key, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key is not a name")
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = value
+		}
+	case c == '<':
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated hex string")
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if isPDFSpace(byte(r)) {
+				return -1
+			}
+			return r
+		}, p.data[p.pos+1:p.pos+end])
+		p.pos += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		s := make([]byte, hex.DecodedLen(len(digits)))
+		if _, err := hex.Decode(s, digits); err != nil {
+			return nil, fmt.Errorf("invalid hex string")
+		}
+		return pdfString(s), nil
+	case c == '[':
+		p.pos++
+		array := pdfArray{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return array, nil
+			}
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		number := p.readRegular()
+		if ref, ok := p.readRef(number); ok {
+			return ref, nil
+		}
+		return pdfNumber(number), nil
+	default:
+		word := p.readRegular()
+		switch word {
+		case "true", "false", "null":
+			return pdfKeyword(word), nil
+		}
+		return nil, fmt.Errorf("unexpected token %q", word)
+	}
+}
+
+// readRef completes "num gen R" after num was read, leaving the position
+// untouched when the following tokens are not a reference
+func (p *pdfParser) readRef(num string) (pdfRef, bool) {
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return pdfRef{}, false
+	}
+
+	saved := p.pos
+	p.skipSpace()
+	gen, err := strconv.Atoi(p.readRegular())
+	if err == nil {
+		p.skipSpace()
+		if p.readRegular() == "R" {
+			return pdfRef{Num: n, Gen: gen}, true
+		}
+	}
+	p.pos = saved
+	return pdfRef{}, false
+}
+
+func (p *pdfParser) readLiteralString() (interface{}, error) {
+	p.pos++
+	var s []byte
+	for depth := 1; ; {
+		if p.pos >= len(p.data) {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(s), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+}
+
+func (p *pdfParser) readRegular() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFSpace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case isPDFSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
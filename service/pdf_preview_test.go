@@ -0,0 +1,428 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a PDF from object bodies numbered from 1 and a trailer
+// pointing at object 1 as the catalog. No cross-reference table is written;
+// the preview finds objects by their headers.
+func testPDF(objects ...string) []byte {
+	return testPDFWithTrailer("<< /Root 1 0 R >>", objects...)
+}
+
+func testPDFWithTrailer(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	for i, body := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	fmt.Fprintf(&b, "trailer\n%s\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+// testStream returns the body of a stream object holding data
+func testStream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// testObjStm returns the body of a compressed object stream holding the
+// objects by number
+func testObjStm(objects map[int]string) string {
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var header, body bytes.Buffer
+	for _, num := range nums {
+		fmt.Fprintf(&header, "%d %d ", num, body.Len())
+		body.WriteString(objects[num])
+		body.WriteByte('\n')
+	}
+
+	var data bytes.Buffer
+	zw := zlib.NewWriter(&data)
+	zw.Write(header.Bytes())
+	zw.Write(body.Bytes())
+	zw.Close()
+
+	dict := fmt.Sprintf("/Type /ObjStm /N %d /First %d /Filter /FlateDecode", len(nums), header.Len())
+	return testStream(dict, data.String())
+}
+
+// testBook returns a three page book whose pages draw PAGE1, PAGE2 and
+// PAGE3 with a font of their own
+func testBook() []byte {
+	return testPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R /Resources << /Font << /F1 9 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R /Resources << /Font << /F1 9 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 8 0 R /Resources << /Font << /F1 9 0 R >> >> >>",
+		testStream("", "BT /F1 12 Tf (PAGE1) Tj ET"),
+		testStream("", "BT /F1 12 Tf (PAGE2) Tj ET"),
+		testStream("", "BT /F1 12 Tf (PAGE3) Tj ET"),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+}
+
+// testSharedResources returns a three page book whose pages inherit one
+// resource dictionary listing the images of all pages, as scanned books and
+// comics often do
+func testSharedResources() []byte {
+	return testPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /Resources 6 0 R /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 8 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 9 0 R >>",
+		"<< /XObject << /Im1 10 0 R /Im2 11 0 R /Im3 12 0 R /Fm1 13 0 R >> /ProcSet [/PDF /ImageC] >>",
+		testStream("", "q 100 0 0 100 0 0 cm /Im1 Do Q /Fm1 Do"),
+		testStream("", "q 100 0 0 100 0 0 cm /Im2 Do Q"),
+		testStream("", "q 100 0 0 100 0 0 cm /Im3 Do Q"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1", "IMAGE-OF-PAGE-1"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1", "IMAGE-OF-PAGE-2"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1", "IMAGE-OF-PAGE-3"),
+		// A form without resources of its own draws from the page's
+		testStream("/Type /XObject /Subtype /Form /BBox [0 0 1 1]", "/Im1 Do"),
+	)
+}
+
+// testObjectStreamBook returns a three page book whose catalog, page tree
+// and pages are stored in a compressed object stream
+func testObjectStreamBook() []byte {
+	return testPDFWithTrailer("<< /Root 5 0 R >>",
+		testObjStm(map[int]string{
+			5: "<< /Type /Catalog /Pages 6 0 R >>",
+			6: "<< /Type /Pages /Kids [7 0 R 8 0 R 9 0 R] /Count 3 /MediaBox [0 0 100 100] >>",
+			7: "<< /Type /Page /Parent 6 0 R /Contents 2 0 R >>",
+			8: "<< /Type /Page /Parent 6 0 R /Contents 3 0 R >>",
+			9: "<< /Type /Page /Parent 6 0 R /Contents 4 0 R >>",
+		}),
+		testStream("", "BT (PAGE1) Tj ET"),
+		testStream("", "BT (PAGE2) Tj ET"),
+		testStream("", "BT (PAGE3) Tj ET"),
+	)
+}
+
+// testLargeImageBook returns a two page book whose first page shows an
+// image larger than the window used to parse objects
+func testLargeImageBook() []byte {
+	image := strings.Repeat("0123456789abcdef", 32<<10) + "END-OF-IMAGE"
+	return testPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 100 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R /Resources << /XObject << /Im1 7 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		testStream("", "/Im1 Do"),
+		testStream("", "BT (PAGE2) Tj ET"),
+		testStream("/Type /XObject /Subtype /Image /Width 1 /Height 1", image),
+	)
+}
+
+// testLargePageTree returns a book with so many pages that its page tree is
+// larger than the window used to parse objects
+func testLargePageTree() []byte {
+	const pages = 20000
+
+	var kids strings.Builder
+	for i := 0; i < pages; i++ {
+		fmt.Fprintf(&kids, "%d 0 R ", i+4)
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 100 100] >>", kids.String(), pages),
+		testStream("", "BT (EVERY-PAGE) Tj ET"),
+	}
+	for i := 0; i < pages; i++ {
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /Contents 3 0 R >>")
+	}
+	return testPDF(objects...)
+}
+
+func TestPreviewPDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		master    []byte
+		pages     int
+		wantPages int
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "first pages",
+			master:    testBook(),
+			pages:     2,
+			wantPages: 2,
+			want:      []string{"PAGE1", "PAGE2", "Helvetica"},
+			notWant:   []string{"PAGE3"},
+		},
+		{
+			name:      "never the whole book",
+			master:    testBook(),
+			pages:     10,
+			wantPages: 2,
+			want:      []string{"PAGE1", "PAGE2"},
+			notWant:   []string{"PAGE3"},
+		},
+		{
+			name:      "shared inherited resources are pruned",
+			master:    testSharedResources(),
+			pages:     1,
+			wantPages: 1,
+			want:      []string{"IMAGE-OF-PAGE-1", "/Im1", "/Fm1", "/ProcSet"},
+			notWant:   []string{"IMAGE-OF-PAGE-2", "IMAGE-OF-PAGE-3", "/Im2", "/Im3"},
+		},
+		{
+			name:      "object streams",
+			master:    testObjectStreamBook(),
+			pages:     1,
+			wantPages: 1,
+			want:      []string{"PAGE1"},
+			notWant:   []string{"PAGE2", "PAGE3", "ObjStm"},
+		},
+		{
+			name:      "stream larger than the parse window",
+			master:    testLargeImageBook(),
+			pages:     1,
+			wantPages: 1,
+			want:      []string{"END-OF-IMAGE"},
+			notWant:   []string{"PAGE2"},
+		},
+		{
+			name:      "page tree larger than the parse window",
+			master:    testLargePageTree(),
+			pages:     3,
+			wantPages: 3,
+			want:      []string{"EVERY-PAGE"},
+		},
+		{
+			name: "wrong stream length",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+				"<< /Length 9223372036854775807 >>\nstream\nBT (PAGE1) Tj ET\nendstream",
+				"<< /Length 3 >>\nstream\nBT (PAGE2) Tj ET\nendstream",
+			),
+			pages:     1,
+			wantPages: 1,
+			want:      []string{"PAGE1"},
+			notWant:   []string{"PAGE2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			n, err := PreviewPDF(&out, bytes.NewReader(tt.master), int64(len(tt.master)), tt.pages)
+			if err != nil {
+				t.Fatalf("PreviewPDF() error = %v", err)
+			}
+			if n != tt.wantPages {
+				t.Errorf("PreviewPDF() = %d pages, want %d", n, tt.wantPages)
+			}
+
+			preview := out.String()
+			for _, s := range tt.want {
+				if !strings.Contains(preview, s) {
+					t.Errorf("preview does not contain %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(preview, s) {
+					t.Errorf("preview contains %q", s)
+				}
+			}
+
+			// The preview must be a PDF the extractor can read back
+			doc, err := readPDF(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("reading preview: %v", err)
+			}
+			pages, err := doc.pages()
+			if err != nil {
+				t.Fatalf("reading preview pages: %v", err)
+			}
+			if len(pages) != tt.wantPages {
+				t.Errorf("preview has %d pages, want %d", len(pages), tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestPreviewPDFMalformed(t *testing.T) {
+	book := testBook()
+	pageTree := bytes.Index(book, []byte("/Kids"))
+
+	tests := []struct {
+		name    string
+		master  []byte
+		wantErr string
+	}{
+		{
+			name:    "not a PDF",
+			master:  []byte("PK\x03\x04 not a pdf"),
+			wantErr: "missing header",
+		},
+		{
+			name:    "empty",
+			master:  []byte{},
+			wantErr: "missing header",
+		},
+		{
+			name:    "no catalog",
+			master:  testPDFWithTrailer("<< /Size 2 >>", "<< /Type /Font >>"),
+			wantErr: "catalog not found",
+		},
+		{
+			name: "single page",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R >>",
+			),
+			wantErr: "too few pages",
+		},
+		{
+			name: "encrypted",
+			master: testPDFWithTrailer("<< /Root 1 0 R /Encrypt 2 0 R >>",
+				"<< /Type /Catalog /Pages 3 0 R >>",
+				"<< /Filter /Standard >>",
+			),
+			wantErr: "encrypted",
+		},
+		{
+			name: "negative first offset in object stream",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				testStream("/Type /ObjStm /N 1 /First -5", "2 0 << /Type /Pages >>"),
+			),
+			wantErr: "malformed object stream",
+		},
+		{
+			name: "object offset past the end of object stream",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				testStream("/Type /ObjStm /N 1 /First 5", "2 99 << /Type /Pages >>"),
+			),
+			wantErr: "malformed object stream",
+		},
+		{
+			name: "negative object offset in object stream",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				testStream("/Type /ObjStm /N 1 /First 5", "2 -3 << /Type /Pages >>"),
+			),
+			wantErr: "malformed object stream",
+		},
+		{
+			name: "object stream with more objects than it holds",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				testStream("/Type /ObjStm /N 5 /First 4", "2 0 << /Type /Pages >>"),
+			),
+			wantErr: "malformed object stream",
+		},
+		{
+			name: "unsupported content encoding",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 6 0 R >> >> >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				testStream("/Filter /LZWDecode", "\x80\x0b\x60\x50"),
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+			),
+			wantErr: "unsupported PDF stream encoding",
+		},
+		{
+			name: "corrupt compressed content",
+			master: testPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 6 0 R >> >> >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				testStream("/Filter /FlateDecode", "not zlib data"),
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+			),
+			wantErr: "invalid PDF stream",
+		},
+		{
+			name:   "truncated in the page tree",
+			master: book[:pageTree+8],
+		},
+		{
+			name:   "unterminated dictionaries",
+			master: []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R\n2 0 obj\n<< [ ( <"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PreviewPDF(&bytes.Buffer{}, bytes.NewReader(tt.master), int64(len(tt.master)), 1)
+			if err == nil {
+				t.Fatal("PreviewPDF() succeeded, want an error")
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PreviewPDF() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPDFContentNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "operands of any operator",
+			content: "/GS1 gs /CS0 cs /P1 scn /F1 12 Tf /Im1 Do /Sh1 sh /OC /MC0 BDC EMC",
+			want:    []string{"GS1", "CS0", "P1", "F1", "Im1", "Sh1", "OC", "MC0"},
+		},
+		{
+			name:    "names inside strings are text",
+			content: "BT /F1 1 Tf (see /Im9 \\) and \\(/Im8) Tj <2F496D37> Tj ET",
+			want:    []string{"F1"},
+		},
+		{
+			name:    "inline image data is skipped",
+			content: "BI /W 1 /H 1 /CS /CS2 ID \x00/Im9 EIx\xff EI /Im1 Do",
+			want:    []string{"W", "H", "CS", "CS2", "Im1"},
+		},
+		{
+			name:    "dictionary operands",
+			content: "/Span << /ActualText (x) /MCID 0 >> BDC EMC",
+			want:    []string{"Span", "ActualText", "MCID"},
+		},
+		{
+			name:    "unterminated string",
+			content: "/F1 1 Tf (never closed /Im9",
+			want:    []string{"F1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pdfContentNames([]byte(tt.content))
+			if len(got) != len(tt.want) {
+				t.Errorf("pdfContentNames() = %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.want {
+				if !got[pdfName(name)] {
+					t.Errorf("pdfContentNames() is missing %q", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type PublisherService interface {
+	CreatePublisher(req model.PublisherRequest) (*entity.Publisher, error)
+	GetPublishers(keyword string) ([]entity.Publisher, error)
+	GetPublisher(id int, slug string) (*entity.Publisher, error)
+	UpdatePublisher(id int, req model.PublisherRequest) (*entity.Publisher, error)
+	DeletePublisher(id int) error
+	SetBookPublisher(bookID int, req model.SetBookPublisherRequest) (*entity.Publisher, error)
+}
+
+type publisherService struct {
+	publisherRepo repository.PublisherRepository
+	bookRepo      repository.BookRepository
+}
+
+func NewPublisherService(publisherRepo repository.PublisherRepository, bookRepo repository.BookRepository) PublisherService {
+	return &publisherService{
+		publisherRepo: publisherRepo,
+		bookRepo:      bookRepo,
+	}
+}
+
+func (s *publisherService) CreatePublisher(req model.PublisherRequest) (*entity.Publisher, error) {
+	publisher := &entity.Publisher{}
+	if err := applyPublisherRequest(publisher, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.publisherRepo.Create(publisher); err != nil {
+		return nil, fmt.Errorf("failed to create publisher: %v", err)
+	}
+	return publisher, nil
+}
+
+func (s *publisherService) GetPublishers(keyword string) ([]entity.Publisher, error) {
+	publishers, err := s.publisherRepo.FindAll(strings.TrimSpace(keyword))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get publishers: %v", err)
+	}
+
+	if publishers == nil {
+		publishers = []entity.Publisher{}
+	}
+	return publishers, nil
+}
+
+// GetPublisher returns the publisher, looked up by ID or slug, with its books
+func (s *publisherService) GetPublisher(id int, slug string) (*entity.Publisher, error) {
+	var publisher *entity.Publisher
+	var err error
+	if slug != "" {
+		publisher, err = s.publisherRepo.FindBySlug(slug)
+	} else {
+		publisher, err = s.publisherRepo.FindByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	publisher.Books, err = s.bookRepo.FindAll(repository.BookFilter{
+		Publisher: publisher.Slug,
+		Sort:      repository.BookSortCreatedAt,
+		Desc:      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get publisher books: %v", err)
+	}
+	if publisher.Books == nil {
+		publisher.Books = []entity.Book{}
+	}
+	return publisher, nil
+}
+
+func (s *publisherService) UpdatePublisher(id int, req model.PublisherRequest) (*entity.Publisher, error) {
+	publisher, err := s.publisherRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyPublisherRequest(publisher, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.publisherRepo.Update(publisher); err != nil {
+		return nil, fmt.Errorf("failed to update publisher: %v", err)
+	}
+	return publisher, nil
+}
+
+func (s *publisherService) DeletePublisher(id int) error {
+	return s.publisherRepo.Delete(id)
+}
+
+// SetBookPublisher links a book to a publisher, or unlinks it when
+// publisher_id is null
+func (s *publisherService) SetBookPublisher(bookID int, req model.SetBookPublisherRequest) (*entity.Publisher, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	var publisher *entity.Publisher
+	if req.PublisherID != nil {
+		var err error
+		publisher, err = s.publisherRepo.FindByID(*req.PublisherID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.publisherRepo.SetBookPublisher(bookID, req.PublisherID); err != nil {
+		return nil, fmt.Errorf("failed to set book publisher: %v", err)
+	}
+	return publisher, nil
+}
+
+func applyPublisherRequest(publisher *entity.Publisher, req model.PublisherRequest) error {
+	req.Nama = strings.TrimSpace(req.Nama)
+	if req.Nama == "" {
+		return fmt.Errorf("nama is required")
+	}
+
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = contributorSlug("publisher", req.Nama)
+	}
+	if !validSlug.MatchString(slug) {
+		return fmt.Errorf("slug may only contain lowercase letters, digits and dashes")
+	}
+
+	publisher.Nama = req.Nama
+	publisher.Slug = slug
+	publisher.Keterangan = strings.TrimSpace(req.Keterangan)
+	return nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+// maxClockSkew bounds how far in the future a device timestamp may be. A
+// device with a fast clock would otherwise win every later sync.
+const maxClockSkew = 5 * time.Minute
+
+type ReadingProgressService interface {
+	GetAllProgress(userID int) ([]entity.ReadingProgress, error)
+	GetProgress(userID, bookID int) (*entity.ReadingProgress, error)
+	SyncProgress(userID int, req model.UpdateReadingProgressRequest) (*entity.ReadingProgress, bool, error)
+}
+
+type readingProgressService struct {
+	progressRepo repository.ReadingProgressRepository
+	orderRepo    repository.OrderRepository
+}
+
+func NewReadingProgressService(progressRepo repository.ReadingProgressRepository, orderRepo repository.OrderRepository) ReadingProgressService {
+	return &readingProgressService{
+		progressRepo: progressRepo,
+		orderRepo:    orderRepo,
+	}
+}
+
+func (s *readingProgressService) GetAllProgress(userID int) ([]entity.ReadingProgress, error) {
+	progress, err := s.progressRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading progress: %v", err)
+	}
+
+	if progress == nil {
+		progress = []entity.ReadingProgress{}
+	}
+	return progress, nil
+}
+
+// GetProgress returns the saved position for an owned book, or nil if the
+// book has not been opened on any device yet
+func (s *readingProgressService) GetProgress(userID, bookID int) (*entity.ReadingProgress, error) {
+	if err := s.checkOwnership(userID, bookID); err != nil {
+		return nil, err
+	}
+
+	progress, err := s.progressRepo.FindByUserAndBook(userID, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reading progress: %v", err)
+	}
+	return progress, nil
+}
+
+// SyncProgress saves a position reported by a device. When another device
+// has already saved a newer position, the stored one is kept and returned
+// with applied set to false so the client can jump to it.
+func (s *readingProgressService) SyncProgress(userID int, req model.UpdateReadingProgressRequest) (*entity.ReadingProgress, bool, error) {
+	if err := validateProgress(&req); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.checkOwnership(userID, req.BookID); err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	updatedAt := now
+	if req.UpdatedAt != nil && req.UpdatedAt.Before(now.Add(maxClockSkew)) {
+		updatedAt = *req.UpdatedAt
+	}
+
+	progress := &entity.ReadingProgress{
+		UserID:       userID,
+		BookID:       req.BookID,
+		Position:     req.Position,
+		PositionType: req.PositionType,
+		Percent:      req.Percent,
+		Device:       req.Device,
+		UpdatedAt:    updatedAt.UTC(),
+	}
+
+	applied, err := s.progressRepo.Upsert(progress)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save reading progress: %v", err)
+	}
+
+	current, err := s.progressRepo.FindByUserAndBook(userID, req.BookID)
+	if err != nil || current == nil {
+		return nil, false, fmt.Errorf("failed to get reading progress: %v", err)
+	}
+	return current, applied, nil
+}
+
+func (s *readingProgressService) checkOwnership(userID, bookID int) error {
+	owned, err := s.orderRepo.HasPurchasedBook(userID, bookID)
+	if err != nil {
+		return fmt.Errorf("failed to check purchase: %v", err)
+	}
+	if !owned {
+		return ErrBookNotPurchased
+	}
+	return nil
+}
+
+func validateProgress(req *model.UpdateReadingProgressRequest) error {
+	req.Position = strings.TrimSpace(req.Position)
+	req.Device = strings.TrimSpace(req.Device)
+
+	if req.BookID <= 0 {
+		return fmt.Errorf("book_id is required")
+	}
+	if req.Position == "" {
+		return fmt.Errorf("position is required")
+	}
+
+	switch req.PositionType {
+	case entity.PositionTypeCFI:
+		if !isEPUBCFI(req.Position) {
+			return fmt.Errorf("position must be an EPUB CFI, e.g. epubcfi(/6/4!/4/2/1:0)")
+		}
+	case entity.PositionTypePage:
+		page, err := strconv.Atoi(req.Position)
+		if err != nil || page < 1 {
+			return fmt.Errorf("position must be a page number starting at 1")
+		}
+	default:
+		return fmt.Errorf("position_type must be one of: %s, %s", entity.PositionTypeCFI, entity.PositionTypePage)
+	}
+
+	if req.Percent < 0 || req.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	if len(req.Device) > 100 {
+		return fmt.Errorf("device must be at most 100 characters")
+	}
+	return nil
+}
+
+// isEPUBCFI does a shallow syntax check; the CFI is only interpreted by readers
+func isEPUBCFI(s string) bool {
+	return strings.HasPrefix(s, "epubcfi(") && strings.HasSuffix(s, ")")
+}
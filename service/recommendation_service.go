@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+const (
+	// recommendationsPerItem is how many recommendations are kept per book
+	// and per user, and the most a request can ask for
+	recommendationsPerItem = 20
+
+	defaultRecommendationLimit = 10
+)
+
+type RecommendationService interface {
+	GetRelatedBooks(bookID, limit int) ([]entity.Book, error)
+	GetRecommendations(userID, limit int) ([]entity.Book, error)
+	RefreshRecommendations() error
+	StartRefreshJob(interval time.Duration)
+}
+
+type recommendationService struct {
+	recommendationRepo repository.RecommendationRepository
+}
+
+func NewRecommendationService(recommendationRepo repository.RecommendationRepository) RecommendationService {
+	return &recommendationService{recommendationRepo: recommendationRepo}
+}
+
+// GetRelatedBooks returns the books customers bought together with the
+// book, as of the last refresh
+func (s *recommendationService) GetRelatedBooks(bookID, limit int) ([]entity.Book, error) {
+	limit, err := recommendationLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := s.recommendationRepo.FindRelated(bookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get related books: %v", err)
+	}
+	if books == nil {
+		books = []entity.Book{}
+	}
+	return books, nil
+}
+
+// GetRecommendations returns books bought together with the books in the
+// user's library that the user doesn't own yet
+func (s *recommendationService) GetRecommendations(userID, limit int) ([]entity.Book, error) {
+	limit, err := recommendationLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := s.recommendationRepo.FindForUser(userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendations: %v", err)
+	}
+	if books == nil {
+		books = []entity.Book{}
+	}
+	return books, nil
+}
+
+// RefreshRecommendations recomputes the co-purchase scores from the order
+// history
+func (s *recommendationService) RefreshRecommendations() error {
+	start := time.Now()
+	pairs, users, err := s.recommendationRepo.Rebuild(recommendationsPerItem)
+	if err != nil {
+		return fmt.Errorf("failed to refresh recommendations: %v", err)
+	}
+
+	log.Printf("Recommendations refreshed in %s: %d book pairs, %d user recommendations",
+		time.Since(start).Round(time.Millisecond), pairs, users)
+	return nil
+}
+
+// StartRefreshJob refreshes the recommendations now and then every
+// interval in the background
+func (s *recommendationService) StartRefreshJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RefreshRecommendations(); err != nil {
+				log.Println(err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func recommendationLimit(limit int) (int, error) {
+	if limit <= 0 {
+		return defaultRecommendationLimit, nil
+	}
+	if limit > recommendationsPerItem {
+		return 0, fmt.Errorf("limit must be at most %d", recommendationsPerItem)
+	}
+	return limit, nil
+}
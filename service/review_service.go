@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+const maxReviewTitle = 200
+
+type ReviewService interface {
+	GetBookReviews(req model.ReviewListRequest) (*model.PaginationResponse, error)
+	GetReviewsForModeration(req model.ReviewListRequest) (*model.PaginationResponse, error)
+	CreateReview(userID int, req model.CreateReviewRequest) (*entity.Review, error)
+	UpdateReview(userID, id int, req model.UpdateReviewRequest) (*entity.Review, error)
+	DeleteReview(user *entity.User, id int) error
+	ModerateReview(id int, req model.ModerateReviewRequest) (*entity.Review, error)
+	VoteHelpful(userID, id int) (*entity.Review, error)
+	RemoveHelpfulVote(userID, id int) (*entity.Review, error)
+}
+
+type reviewService struct {
+	reviewRepo repository.ReviewRepository
+	bookRepo   repository.BookRepository
+	orderRepo  repository.OrderRepository
+}
+
+func NewReviewService(reviewRepo repository.ReviewRepository, bookRepo repository.BookRepository, orderRepo repository.OrderRepository) ReviewService {
+	return &reviewService{
+		reviewRepo: reviewRepo,
+		bookRepo:   bookRepo,
+		orderRepo:  orderRepo,
+	}
+}
+
+// GetBookReviews lists the approved reviews of a book
+func (s *reviewService) GetBookReviews(req model.ReviewListRequest) (*model.PaginationResponse, error) {
+	if req.BookID <= 0 {
+		return nil, fmt.Errorf("book_id is required")
+	}
+	if _, err := s.bookRepo.FindByID(req.BookID); err != nil {
+		return nil, fmt.Errorf("book not found: %v", err)
+	}
+
+	req.Status = entity.ReviewStatusApproved
+	return s.listReviews(req)
+}
+
+// GetReviewsForModeration lists reviews of every status for admins,
+// optionally narrowed to one book or status
+func (s *reviewService) GetReviewsForModeration(req model.ReviewListRequest) (*model.PaginationResponse, error) {
+	if req.Status != "" && !isReviewStatus(req.Status) {
+		return nil, fmt.Errorf("status must be one of: %s, %s", entity.ReviewStatusApproved, entity.ReviewStatusHidden)
+	}
+	return s.listReviews(req)
+}
+
+func (s *reviewService) listReviews(req model.ReviewListRequest) (*model.PaginationResponse, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultPageLimit
+	}
+	if req.Limit > maxPageLimit {
+		return nil, fmt.Errorf("limit must be at most %d", maxPageLimit)
+	}
+	if req.Sort == "" {
+		req.Sort = repository.ReviewSortNewest
+	}
+	if !repository.IsReviewSort(req.Sort) {
+		return nil, fmt.Errorf("sort must be one of: newest, helpful, rating_high, rating_low")
+	}
+
+	filter := repository.ReviewFilter{
+		BookID: req.BookID,
+		Status: req.Status,
+		Sort:   req.Sort,
+		Limit:  req.Limit,
+		Offset: (req.Page - 1) * req.Limit,
+	}
+
+	total, err := s.reviewRepo.Count(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reviews: %v", err)
+	}
+
+	reviews, err := s.reviewRepo.FindAll(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %v", err)
+	}
+	if reviews == nil {
+		reviews = []entity.Review{}
+	}
+
+	return &model.PaginationResponse{
+		Page:       req.Page,
+		Limit:      req.Limit,
+		Total:      total,
+		TotalPages: (total + req.Limit - 1) / req.Limit,
+		Data:       reviews,
+	}, nil
+}
+
+// CreateReview posts the user's review of a book. Only customers with a
+// completed order containing the book may review it, once per book.
+func (s *reviewService) CreateReview(userID int, req model.CreateReviewRequest) (*entity.Review, error) {
+	if req.BookID <= 0 {
+		return nil, fmt.Errorf("book_id is required")
+	}
+
+	review := &entity.Review{
+		BookID: req.BookID,
+		UserID: userID,
+		Rating: req.Rating,
+		Title:  strings.TrimSpace(req.Title),
+		Body:   strings.TrimSpace(req.Body),
+		Status: entity.ReviewStatusApproved,
+	}
+	if err := validateReview(review); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.bookRepo.FindByID(req.BookID); err != nil {
+		return nil, fmt.Errorf("book not found: %v", err)
+	}
+
+	verified, err := s.orderRepo.HasCompletedOrder(userID, req.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check purchase: %v", err)
+	}
+	if !verified {
+		return nil, ErrNotVerifiedBuyer
+	}
+
+	existing, err := s.reviewRepo.FindByUserAndBook(userID, req.BookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing review: %v", err)
+	}
+	if existing != nil {
+		return nil, ErrReviewExists
+	}
+
+	err = s.reviewRepo.Create(review)
+	if repository.IsDuplicateReview(err) {
+		return nil, ErrReviewExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create review: %v", err)
+	}
+	return s.reviewRepo.FindByID(review.ID)
+}
+
+// UpdateReview edits the user's own review. A hidden review stays hidden.
+func (s *reviewService) UpdateReview(userID, id int, req model.UpdateReviewRequest) (*entity.Review, error) {
+	review, err := s.findOwnReview(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Rating != nil {
+		review.Rating = *req.Rating
+	}
+	if req.Title != nil {
+		review.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Body != nil {
+		review.Body = strings.TrimSpace(*req.Body)
+	}
+	if err := validateReview(review); err != nil {
+		return nil, err
+	}
+
+	if err := s.reviewRepo.Update(review); err != nil {
+		return nil, fmt.Errorf("failed to update review: %v", err)
+	}
+	return review, nil
+}
+
+// DeleteReview removes a review. Users may delete their own reviews and
+// admins any review.
+func (s *reviewService) DeleteReview(user *entity.User, id int) error {
+	if user.Role != "admin" {
+		if _, err := s.findOwnReview(user.ID, id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.reviewRepo.Delete(id); err != nil {
+		if err.Error() == "review not found" {
+			return ErrReviewNotFound
+		}
+		return fmt.Errorf("failed to delete review: %v", err)
+	}
+	return nil
+}
+
+// ModerateReview hides or approves a review. Hidden reviews are left out of
+// the public list and the book's rating.
+func (s *reviewService) ModerateReview(id int, req model.ModerateReviewRequest) (*entity.Review, error) {
+	if !isReviewStatus(req.Status) {
+		return nil, fmt.Errorf("status must be one of: %s, %s", entity.ReviewStatusApproved, entity.ReviewStatusHidden)
+	}
+
+	if err := s.reviewRepo.UpdateStatus(id, req.Status); err != nil {
+		if err.Error() == "review not found" {
+			return nil, ErrReviewNotFound
+		}
+		return nil, fmt.Errorf("failed to moderate review: %v", err)
+	}
+	return s.reviewRepo.FindByID(id)
+}
+
+// VoteHelpful marks an approved review as helpful. Voting again has no
+// effect.
+func (s *reviewService) VoteHelpful(userID, id int) (*entity.Review, error) {
+	review, err := s.findVotableReview(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reviewRepo.AddVote(review.ID, userID); err != nil {
+		return nil, fmt.Errorf("failed to vote: %v", err)
+	}
+	return s.reviewRepo.FindByID(id)
+}
+
+func (s *reviewService) RemoveHelpfulVote(userID, id int) (*entity.Review, error) {
+	review, err := s.findVotableReview(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reviewRepo.RemoveVote(review.ID, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove vote: %v", err)
+	}
+	return s.reviewRepo.FindByID(id)
+}
+
+func (s *reviewService) findOwnReview(userID, id int) (*entity.Review, error) {
+	review, err := s.reviewRepo.FindByID(id)
+	if err != nil || review.UserID != userID {
+		return nil, ErrReviewNotFound
+	}
+	return review, nil
+}
+
+func (s *reviewService) findVotableReview(userID, id int) (*entity.Review, error) {
+	review, err := s.reviewRepo.FindByID(id)
+	if err != nil || review.Status != entity.ReviewStatusApproved {
+		return nil, ErrReviewNotFound
+	}
+	if review.UserID == userID {
+		return nil, ErrOwnReviewVote
+	}
+	return review, nil
+}
+
+func isReviewStatus(status string) bool {
+	return status == entity.ReviewStatusApproved || status == entity.ReviewStatusHidden
+}
+
+func validateReview(review *entity.Review) error {
+	if review.Rating < 1 || review.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if len([]rune(review.Title)) > maxReviewTitle {
+		return fmt.Errorf("title must be at most %d characters", maxReviewTitle)
+	}
+	return nil
+}
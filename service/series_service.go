@@ -0,0 +1,215 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/model"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type SeriesService interface {
+	CreateSeries(req model.SeriesRequest) (*entity.Series, error)
+	GetSeriesList(keyword string) ([]entity.Series, error)
+	GetSeries(id int, slug string) (*entity.Series, error)
+	UpdateSeries(id int, req model.SeriesRequest) (*entity.Series, error)
+	DeleteSeries(id int) error
+	SetBookSeries(bookID int, req model.SetBookSeriesRequest) (*entity.BookSeries, error)
+	AddSeriesToCart(userID, id int, format string) (*model.SeriesCartResponse, error)
+}
+
+type seriesService struct {
+	seriesRepo  repository.SeriesRepository
+	bookRepo    repository.BookRepository
+	orderRepo   repository.OrderRepository
+	cartRepo    repository.CartRepository
+	cartService CartService
+}
+
+func NewSeriesService(seriesRepo repository.SeriesRepository, bookRepo repository.BookRepository, orderRepo repository.OrderRepository, cartRepo repository.CartRepository, cartService CartService) SeriesService {
+	return &seriesService{
+		seriesRepo:  seriesRepo,
+		bookRepo:    bookRepo,
+		orderRepo:   orderRepo,
+		cartRepo:    cartRepo,
+		cartService: cartService,
+	}
+}
+
+func (s *seriesService) CreateSeries(req model.SeriesRequest) (*entity.Series, error) {
+	series := &entity.Series{}
+	if err := applySeriesRequest(series, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.seriesRepo.Create(series); err != nil {
+		return nil, fmt.Errorf("failed to create series: %v", err)
+	}
+	return series, nil
+}
+
+func (s *seriesService) GetSeriesList(keyword string) ([]entity.Series, error) {
+	list, err := s.seriesRepo.FindAll(strings.TrimSpace(keyword))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series: %v", err)
+	}
+
+	if list == nil {
+		list = []entity.Series{}
+	}
+	return list, nil
+}
+
+// GetSeries returns the series, looked up by ID or slug, with its volumes
+// in order
+func (s *seriesService) GetSeries(id int, slug string) (*entity.Series, error) {
+	var series *entity.Series
+	var err error
+	if slug != "" {
+		series, err = s.seriesRepo.FindBySlug(slug)
+	} else {
+		series, err = s.seriesRepo.FindByID(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	series.Volumes, err = s.seriesRepo.FindVolumes(series.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series volumes: %v", err)
+	}
+	if series.Volumes == nil {
+		series.Volumes = []entity.SeriesVolume{}
+	}
+	return series, nil
+}
+
+func (s *seriesService) UpdateSeries(id int, req model.SeriesRequest) (*entity.Series, error) {
+	series, err := s.seriesRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applySeriesRequest(series, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.seriesRepo.Update(series); err != nil {
+		return nil, fmt.Errorf("failed to update series: %v", err)
+	}
+	return series, nil
+}
+
+func (s *seriesService) DeleteSeries(id int) error {
+	return s.seriesRepo.Delete(id)
+}
+
+// SetBookSeries places a book at a position in a series, or takes it out
+// of its series when series_id is null
+func (s *seriesService) SetBookSeries(bookID int, req model.SetBookSeriesRequest) (*entity.BookSeries, error) {
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		return nil, fmt.Errorf("book not found")
+	}
+
+	if req.SeriesID != nil {
+		if req.Position < 1 {
+			return nil, fmt.Errorf("position must be at least 1")
+		}
+		if _, err := s.seriesRepo.FindByID(*req.SeriesID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.seriesRepo.SetBookSeries(bookID, req.SeriesID, req.Position); err != nil {
+		return nil, fmt.Errorf("failed to set book series: %v", err)
+	}
+	return s.seriesRepo.FindByBookID(bookID)
+}
+
+// AddSeriesToCart adds every volume of the series to the user's cart,
+// skipping volumes the user already bought or has in the cart and volumes
+// that are out of stock. Volumes are added in format, which may be left empty
+// for volumes sold in a single format.
+func (s *seriesService) AddSeriesToCart(userID, id int, format string) (*model.SeriesCartResponse, error) {
+	volumes, err := s.seriesRepo.FindVolumes(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series volumes: %v", err)
+	}
+	if len(volumes) == 0 {
+		if _, err := s.seriesRepo.FindByID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &model.SeriesCartResponse{
+		Added:   []model.SeriesCartVolume{},
+		Skipped: []model.SeriesCartVolume{},
+	}
+	for _, volume := range volumes {
+		item := model.SeriesCartVolume{
+			BookID:     volume.ID,
+			NamaBarang: volume.NamaBarang,
+			Position:   volume.Position,
+		}
+
+		item.Reason, err = s.skipReason(userID, volume.ID)
+		if err != nil {
+			return nil, err
+		}
+		if item.Reason == "" {
+			err := s.cartService.AddToCart(userID, model.AddToCartRequest{BookID: volume.ID, Jumlah: 1, Format: format})
+			if err != nil {
+				item.Reason = err.Error()
+			}
+		}
+
+		if item.Reason != "" {
+			resp.Skipped = append(resp.Skipped, item)
+		} else {
+			resp.Added = append(resp.Added, item)
+		}
+	}
+	return resp, nil
+}
+
+// skipReason explains why a volume should not be added to the cart, or
+// returns "" if it should
+func (s *seriesService) skipReason(userID, bookID int) (string, error) {
+	owned, err := s.orderRepo.HasPurchasedBook(userID, bookID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check purchase: %v", err)
+	}
+	if owned {
+		return "already purchased", nil
+	}
+
+	inCart, err := s.cartRepo.FindByUserAndBook(userID, bookID)
+	if err != nil {
+		return "", fmt.Errorf("failed to check cart: %v", err)
+	}
+	if inCart != nil {
+		return "already in cart", nil
+	}
+	return "", nil
+}
+
+func applySeriesRequest(series *entity.Series, req model.SeriesRequest) error {
+	req.Nama = strings.TrimSpace(req.Nama)
+	if req.Nama == "" {
+		return fmt.Errorf("nama is required")
+	}
+
+	slug := strings.TrimSpace(req.Slug)
+	if slug == "" {
+		slug = contributorSlug("series", req.Nama)
+	}
+	if !validSlug.MatchString(slug) {
+		return fmt.Errorf("slug may only contain lowercase letters, digits and dashes")
+	}
+
+	series.Nama = req.Nama
+	series.Slug = slug
+	series.Keterangan = strings.TrimSpace(req.Keterangan)
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 
 type UploadService interface {
 	UploadImage(file multipart.File, header *multipart.FileHeader) (string, error)
+	SaveImage(data []byte, contentType string) (string, error)
 	DeleteImage(filename string) error
 	GetImageURL(filename string) string
 }
@@ -66,6 +67,37 @@ func (s *uploadService) UploadImage(file multipart.File, header *multipart.FileH
 	return filename, nil
 }
 
+// SaveImage stores image bytes that did not come from a form upload, such as
+// a cover extracted from an EPUB
+func (s *uploadService) SaveImage(data []byte, contentType string) (string, error) {
+	if len(data) > 5*1024*1024 {
+		return "", fmt.Errorf("file size exceeds 5MB limit")
+	}
+
+	if !s.isValidImageType(contentType) {
+		return "", fmt.Errorf("invalid file type. Only JPEG, PNG, GIF, and WebP are allowed")
+	}
+
+	if err := os.MkdirAll(s.uploadDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %v", err)
+	}
+
+	extensions := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/jpg":  ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+	}
+	filename := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), generateRandomString(8), extensions[contentType])
+
+	if err := os.WriteFile(filepath.Join(s.uploadDir, filename), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save file: %v", err)
+	}
+
+	return filename, nil
+}
+
 func (s *uploadService) DeleteImage(filename string) error {
 	if filename == "" {
 		return nil
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/LanangDepok/ebook-store/entity"
+	"github.com/LanangDepok/ebook-store/repository"
+)
+
+type WishlistService interface {
+	AddToWishlist(userID, bookID int) error
+	RemoveFromWishlist(userID, bookID int) error
+	GetWishlist(userID int) ([]entity.WishlistItem, error)
+}
+
+type wishlistService struct {
+	wishlistRepo repository.WishlistRepository
+	bookRepo     repository.BookRepository
+}
+
+func NewWishlistService(wishlistRepo repository.WishlistRepository, bookRepo repository.BookRepository) WishlistService {
+	return &wishlistService{
+		wishlistRepo: wishlistRepo,
+		bookRepo:     bookRepo,
+	}
+}
+
+// AddToWishlist saves the book for later. Unlike the cart, books that are
+// out of stock can be wishlisted so the user hears when they return.
+func (s *wishlistService) AddToWishlist(userID, bookID int) error {
+	book, err := s.bookRepo.FindByID(bookID)
+	if err != nil {
+		return fmt.Errorf("book not found")
+	}
+
+	if err := s.wishlistRepo.Add(userID, book.ID, book.Harga); err != nil {
+		return fmt.Errorf("failed to add to wishlist: %v", err)
+	}
+	return nil
+}
+
+func (s *wishlistService) RemoveFromWishlist(userID, bookID int) error {
+	return s.wishlistRepo.Remove(userID, bookID)
+}
+
+func (s *wishlistService) GetWishlist(userID int) ([]entity.WishlistItem, error) {
+	items, err := s.wishlistRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wishlist: %v", err)
+	}
+
+	if items == nil {
+		items = []entity.WishlistItem{}
+	}
+	return items, nil
+}